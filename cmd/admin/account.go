@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"gopherpay/internal/billing"
+)
+
+// runAccountClass sets the class of an account. Transfers out of treasury
+// and payroll accounts are queued in the high priority lane by default.
+func runAccountClass() {
+
+	classCmd := flag.NewFlagSet("account-class", flag.ExitOnError)
+	accountFlag := classCmd.Uint64("account", 0, "Account ID (required)")
+	classFlag := classCmd.String("class", "", "Class: standard, treasury or payroll (required)")
+
+	if err := classCmd.Parse(os.Args[2:]); err != nil {
+		log.Println("[ERROR] Failed to parse flags:", err)
+		os.Exit(1)
+	}
+
+	if *accountFlag == 0 {
+		log.Println("[ERROR] --account flag is required")
+		os.Exit(1)
+	}
+
+	class := billing.AccountClass(*classFlag)
+	switch class {
+	case billing.ClassStandard, billing.ClassTreasury, billing.ClassPayroll:
+	default:
+		log.Println("[ERROR] --class must be standard, treasury or payroll")
+		os.Exit(1)
+	}
+
+	db, backend := connectDB()
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var repo billing.Store = billing.NewMySQLRepository(db)
+	switch backend {
+	case "postgres":
+		repo = billing.NewPostgresRepository(db)
+	case "sqlite":
+		repo = billing.NewSQLiteRepository(db)
+	}
+
+	if err := repo.SetAccountClass(ctx, *accountFlag, class); err != nil {
+		log.Println("[ERROR] Failed to set account class:", err)
+		os.Exit(1)
+	}
+
+	log.Printf("[SUCCESS] Account %d is now %s\n", *accountFlag, class)
+}
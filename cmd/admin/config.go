@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"gopherpay/internal/config"
+)
+
+// runConfig prints the effective configuration, after the config file,
+// environment and any flags given after "print" are applied, with secrets
+// redacted:
+//
+//	admin config print [--config file.yaml] [--pool.workers=20 ...]
+func runConfig() {
+
+	if len(os.Args) < 3 || os.Args[2] != "print" {
+		log.Println("[ERROR] Expected: config print [flags]")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(os.Args[3:])
+	if err != nil {
+		log.Println("[ERROR] Config load failed:", err)
+		os.Exit(1)
+	}
+
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		log.Println("[ERROR] Failed to render config:", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(out)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/config"
+	"gopherpay/internal/conformance"
+	"gopherpay/internal/memstore"
+)
+
+// runConformance checks a storage backend against the behaviour
+// billing.Service relies on. It writes accounts and transactions, so run
+// it against a scratch, migrated database:
+//
+//	admin conformance [--storage=sqlite|mysql|postgres|memory] [config flags]
+func runConformance() {
+
+	cfg, err := config.Load(os.Args[2:])
+	if err != nil {
+		log.Println("[ERROR] Config load failed:", err)
+		os.Exit(1)
+	}
+
+	var backend conformance.Backend
+	switch cfg.Storage.Backend {
+	case "memory":
+		backend = memstore.New()
+
+	default:
+		db, err := cfg.OpenDB()
+		if err != nil {
+			log.Println("[ERROR] Database connection failed:", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		switch cfg.Storage.Backend {
+		case "postgres":
+			repo := audit.NewPostgresRepository(db)
+			backend = sqlBackend{billing.NewPostgresRepository(db), repo, repo}
+		case "sqlite":
+			repo := audit.NewSQLiteRepository(db)
+			backend = sqlBackend{billing.NewSQLiteRepository(db), repo, repo}
+		default:
+			repo := audit.NewMySQLRepository(db)
+			backend = sqlBackend{billing.NewMySQLRepository(db), repo, repo}
+		}
+	}
+
+	log.Printf("[INFO] Running conformance cases against %s storage\n", cfg.Storage.Backend)
+
+	results := conformance.Run(context.Background(), backend, time.Minute)
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, r := range results {
+		state, detail := "PASS", ""
+		if r.Err != nil {
+			state, detail = "FAIL", r.Err.Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", state, r.Name, r.Duration.Round(time.Millisecond), detail)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		log.Printf("[ERROR] %d of %d case(s) failed\n", failed, len(results))
+		os.Exit(1)
+	}
+	log.Printf("[SUCCESS] All %d cases passed\n", len(results))
+}
+
+// accountStore is a SQL wallet repository with its account fixture.
+type accountStore interface {
+	billing.Store
+	CreateAccount(ctx context.Context, balance int64) (uint64, error)
+}
+
+type sqlBackend struct {
+	accountStore
+	audit.Repository
+	audit.Reader
+}
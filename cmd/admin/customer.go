@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopherpay/internal/auth"
+	"gopherpay/internal/config"
+)
+
+// connectDB connects to the database of the configured storage backend
+// and returns it with the backend's name: "mysql", "postgres" or "sqlite".
+func connectDB() (*sql.DB, string) {
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Println("[ERROR] Config load failed:", err)
+		os.Exit(1)
+	}
+
+	db, err := cfg.OpenDB()
+	if err != nil {
+		log.Println("[ERROR] Database connection failed:", err)
+		os.Exit(1)
+	}
+
+	return db, cfg.Storage.Backend
+}
+
+// customerStore is what the customer and credential commands write to.
+type customerStore interface {
+	CreateCustomer(ctx context.Context, name string) (uint64, error)
+	AssignAccount(ctx context.Context, customerID, accountID uint64) error
+	CreateCredential(ctx context.Context, cred *auth.Credential) (uint64, error)
+}
+
+func newCustomerStore(db *sql.DB, backend string) customerStore {
+	switch backend {
+	case "postgres":
+		return auth.NewPostgresRepository(db)
+	case "sqlite":
+		return auth.NewSQLiteRepository(db)
+	}
+	return auth.NewMySQLRepository(db)
+}
+
+// runCustomer creates a customer and assigns existing accounts to it.
+func runCustomer() {
+
+	customerCmd := flag.NewFlagSet("customer", flag.ExitOnError)
+	nameFlag := customerCmd.String("name", "", "Customer name (required)")
+	accountsFlag := customerCmd.String("accounts", "", "Comma separated account IDs owned by the customer")
+
+	if err := customerCmd.Parse(os.Args[2:]); err != nil {
+		log.Println("[ERROR] Failed to parse flags:", err)
+		os.Exit(1)
+	}
+
+	if *nameFlag == "" {
+		log.Println("[ERROR] --name flag is required")
+		os.Exit(1)
+	}
+
+	var accountIDs []uint64
+	if *accountsFlag != "" {
+		for _, raw := range strings.Split(*accountsFlag, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+			if err != nil {
+				log.Println("[ERROR] Invalid account ID:", err)
+				os.Exit(1)
+			}
+			accountIDs = append(accountIDs, id)
+		}
+	}
+
+	db, backend := connectDB()
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	repo := newCustomerStore(db, backend)
+
+	customerID, err := repo.CreateCustomer(ctx, *nameFlag)
+	if err != nil {
+		log.Println("[ERROR] Failed to create customer:", err)
+		os.Exit(1)
+	}
+
+	for _, accountID := range accountIDs {
+		if err := repo.AssignAccount(ctx, customerID, accountID); err != nil {
+			log.Println("[ERROR] Failed to assign account:", err)
+			os.Exit(1)
+		}
+	}
+
+	log.Printf("[SUCCESS] Created customer %d with %d account(s)\n", customerID, len(accountIDs))
+}
+
+// runCredential issues an API key, or binds a JWT subject, for a customer or
+// operator. Generated API keys are printed once and only their hash is stored.
+func runCredential() {
+
+	credCmd := flag.NewFlagSet("credential", flag.ExitOnError)
+	customerFlag := credCmd.Uint64("customer", 0, "Customer ID the credential acts for")
+	roleFlag := credCmd.String("role", "customer", "Role: customer or operator")
+	subjectFlag := credCmd.String("subject", "", "Bind a JWT subject instead of generating an API key")
+
+	if err := credCmd.Parse(os.Args[2:]); err != nil {
+		log.Println("[ERROR] Failed to parse flags:", err)
+		os.Exit(1)
+	}
+
+	cred := &auth.Credential{}
+
+	switch *roleFlag {
+	case "customer":
+		cred.Role = auth.RoleCustomer
+	case "operator":
+		cred.Role = auth.RoleOperator
+	default:
+		log.Println("[ERROR] --role must be customer or operator")
+		os.Exit(1)
+	}
+
+	if *customerFlag != 0 {
+		cred.CustomerID = customerFlag
+	} else if cred.Role == auth.RoleCustomer {
+		log.Println("[ERROR] --customer flag is required for customer credentials")
+		os.Exit(1)
+	}
+
+	var apiKey string
+	if *subjectFlag != "" {
+		cred.Kind = auth.CredentialJWTSubject
+		cred.Identifier = *subjectFlag
+	} else {
+		key, err := auth.GenerateAPIKey()
+		if err != nil {
+			log.Println("[ERROR]", err)
+			os.Exit(1)
+		}
+		apiKey = key
+		cred.Kind = auth.CredentialAPIKey
+		cred.Identifier = auth.HashAPIKey(key)
+	}
+
+	db, backend := connectDB()
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := newCustomerStore(db, backend).CreateCredential(ctx, cred)
+	if err != nil {
+		log.Println("[ERROR] Failed to create credential:", err)
+		os.Exit(1)
+	}
+
+	log.Printf("[SUCCESS] Created %s credential %d\n", cred.Kind, id)
+	if apiKey != "" {
+		log.Println("[INFO] API key (shown once):", apiKey)
+	}
+}
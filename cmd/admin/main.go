@@ -1,18 +1,13 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"encoding/csv"
-	"flag"
-	"fmt"
+	"database/sql"
 	"log"
 	"os"
-	"strconv"
-	"time"
 
 	"gopherpay/internal/config"
-	"gopherpay/internal/reporting"
+	"gopherpay/internal/replica"
 )
 
 func main() {
@@ -21,7 +16,7 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
 	if len(os.Args) < 2 {
-		log.Println("[ERROR] Expected subcommand: report")
+		log.Println("[ERROR] Expected subcommand: report, statement, customer, credential, account-class, migrate, config, conformance")
 		os.Exit(1)
 	}
 
@@ -30,199 +25,60 @@ func main() {
 	case "report":
 		runReport()
 
-	default:
-		log.Println("[ERROR] Unknown command")
-		os.Exit(1)
-	}
-}
+	case "statement":
+		runStatement()
 
-func runReport() {
+	case "customer":
+		runCustomer()
 
-	reportCmd := flag.NewFlagSet("report", flag.ExitOnError)
-	userIDFlag := reportCmd.String("user", "", "User account ID (required)")
+	case "credential":
+		runCredential()
 
-	if err := reportCmd.Parse(os.Args[2:]); err != nil {
-		log.Println("[ERROR] Failed to parse flags:", err)
-		os.Exit(1)
-	}
+	case "account-class":
+		runAccountClass()
 
-	if *userIDFlag == "" {
-		log.Println("[ERROR] --user flag is required")
-		os.Exit(1)
-	}
-
-	userID, err := strconv.ParseUint(*userIDFlag, 10, 64)
-	if err != nil {
-		log.Println("[ERROR] Invalid user ID:", err)
-		os.Exit(1)
-	}
+	case "migrate":
+		runMigrate()
 
-	log.Printf("[INFO] Generating comprehensive report for User ID: %d\n", userID)
+	case "config":
+		runConfig()
 
-	cfg, err := config.LoadDBConfig()
-	if err != nil {
-		log.Println("[ERROR] Config load failed:", err)
-		os.Exit(1)
-	}
-
-	db, err := config.ConnectDB(cfg)
-	if err != nil {
-		log.Println("[ERROR] Database connection failed:", err)
-		os.Exit(1)
-	}
-
-	defer db.Close()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Use the new multi-table query
-	details, err := reporting.GetUserTransactionsWithAudit(ctx, db, userID)
-	if err != nil {
-		log.Println("[ERROR] Failed to fetch transactions:", err)
-		os.Exit(1)
-	}
+	case "conformance":
+		runConformance()
 
-	fileName := fmt.Sprintf("user_%d_report.csv", userID)
-	file, err := os.Create(fileName)
-	if err != nil {
-		log.Println("[ERROR] Failed to create file:", err)
+	default:
+		log.Println("[ERROR] Unknown command")
 		os.Exit(1)
 	}
-	defer file.Close()
-
-	bufferedWriter := bufio.NewWriter(file)
-	csvWriter := csv.NewWriter(bufferedWriter)
+}
 
-	// ===== SECTION 1: Transaction Overview =====
-	err = csvWriter.Write([]string{
-		"=== TRANSACTION OVERVIEW ===",
-	})
-	if err != nil {
-		log.Println("[ERROR] Failed to write header:", err)
-		os.Exit(1)
+// reportDB picks the database a report reads from: a replica that passes
+// a health check now, or primary.
+func reportDB(ctx context.Context, primary *sql.DB, backend string) *sql.DB {
+	cfg, err := config.Load(nil)
+	if err != nil || len(cfg.Database.Replicas) == 0 || replica.PrimaryOnly(ctx) {
+		return primary
 	}
 
-	csvWriter.Write([]string{})
-
-	err = csvWriter.Write([]string{
-		"TransactionID",
-		"RequestID",
-		"FromAccountID",
-		"ToAccountID",
-		"Amount(Cents)",
-		"Status",
-		"ErrorMessage",
-		"FromAccountBalance",
-		"ToAccountBalance",
-		"CreatedAt",
-	})
+	set, err := config.OpenReplicas(backend, cfg.Database, primary)
 	if err != nil {
-		log.Println("[ERROR] Failed to write transaction header:", err)
+		log.Println("[ERROR] Failed to open replicas:", err)
 		os.Exit(1)
 	}
 
-	for _, detail := range details {
-		errorMsg := ""
-		if detail.ErrorMessage != nil {
-			errorMsg = *detail.ErrorMessage
-		}
-
-		record := []string{
-			strconv.FormatUint(detail.TransactionID, 10),
-			detail.RequestID,
-			strconv.FormatUint(detail.FromAccountID, 10),
-			strconv.FormatUint(detail.ToAccountID, 10),
-			strconv.FormatInt(detail.Amount, 10),
-			detail.Status,
-			errorMsg,
-			strconv.FormatInt(detail.FromBalance, 10),
-			strconv.FormatInt(detail.ToBalance, 10),
-			detail.CreatedAt,
-		}
+	checkCtx, cancel := context.WithTimeout(ctx, cfg.Database.ReplicaCheckInterval)
+	set.Check(checkCtx)
+	cancel()
 
-		if err := csvWriter.Write(record); err != nil {
-			log.Println("[ERROR] Failed to write transaction row:", err)
-			os.Exit(1)
+	for _, st := range set.Statuses() {
+		if st.Err != nil {
+			log.Printf("[INFO] Skipping replica %s: %v\n", st.Name, st.Err)
 		}
 	}
 
-	csvWriter.Write([]string{})
-	csvWriter.Write([]string{"=== AUDIT TRAIL ===", "", "", "", "", "", "", "", "", ""})
-	csvWriter.Write([]string{})
-
-	// ===== SECTION 2: Audit Trail =====
-	csvWriter.Write([]string{
-		"RequestID",
-		"Action",
-		"Status",
-		"Message",
-		"Timestamp",
-	})
-
-	for _, detail := range details {
-		for _, audit := range detail.AuditLogs {
-			auditMsg := ""
-			if audit.Message != nil {
-				auditMsg = *audit.Message
-			}
-
-			auditRecord := []string{
-				detail.RequestID,
-				audit.Action,
-				audit.Status,
-				auditMsg,
-				audit.Timestamp,
-			}
-
-			if err := csvWriter.Write(auditRecord); err != nil {
-				log.Println("[ERROR] Failed to write audit row:", err)
-				os.Exit(1)
-			}
-		}
+	db := set.DB(ctx)
+	if db == primary {
+		log.Println("[INFO] Reading from the primary")
 	}
-
-	// ===== SECTION 3: Summary Stats (Optional) =====
-	csvWriter.Write([]string{})
-	csvWriter.Write([]string{"=== SUMMARY STATS ===", "", "", "", "", ""})
-	csvWriter.Write([]string{})
-
-	// Fetch summary (lenient - continue if it fails)
-	summary, err := reporting.GetUserSummary(ctx, db, userID)
-	if err != nil {
-		log.Printf("[WARN] Summary generation failed: %v, continuing without summary\n", err)
-		csvWriter.Write([]string{"Summary: Data unavailable"})
-	} else {
-		// Write summary rows
-		summaryRows := [][]string{
-			{"Total Transactions:", fmt.Sprintf("%d", summary.TotalTransactions)},
-			{"Successful:", fmt.Sprintf("%d", summary.SuccessfulCount)},
-			{"Failed:", fmt.Sprintf("%d", summary.FailedCount)},
-			{"Success Rate:", fmt.Sprintf("%.2f%%", summary.SuccessRate)},
-			{"Total Amount Sent :", fmt.Sprintf("%d", summary.TotalSent)},
-			{"Total Amount Received :", fmt.Sprintf("%d", summary.TotalReceived)},
-			{"Net Change :", fmt.Sprintf("%d", summary.NetChange)},
-			{"Account Created:", summary.AccountCreatedAt},
-			{"Last Activity:", summary.LastActivityAt},
-		}
-
-		for _, row := range summaryRows {
-			if err := csvWriter.Write(row); err != nil {
-				log.Printf("[WARN] Failed to write summary row: %v\n", err)
-			}
-		}
-	}
-
-	csvWriter.Flush()
-	bufferedWriter.Flush()
-
-	if err := csvWriter.Error(); err != nil {
-		log.Println("[ERROR] CSV writer error:", err)
-		os.Exit(1)
-	}
-
-	log.Printf("[SUCCESS] Report generated successfully with %d transactions\n", len(details))
-	log.Printf("[INFO] Output file: %s\n", fileName)
-
-	os.Exit(0)
+	return db
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"gopherpay/internal/migrate"
+	"gopherpay/migrations"
+)
+
+// runMigrate applies or reverts the embedded schema migrations:
+//
+//	admin migrate up          apply every pending migration
+//	admin migrate down        revert the latest applied migration
+//	admin migrate to N        migrate up or down to version N (0 reverts all)
+//	admin migrate status      list migrations and whether each is applied
+//	admin migrate baseline N  record 1..N as applied without running them,
+//	                          for databases migrated by hand
+func runMigrate() {
+
+	if len(os.Args) < 3 {
+		log.Println("[ERROR] Expected: migrate up|down|status|to N|baseline N")
+		os.Exit(1)
+	}
+
+	db, backend := connectDB()
+	defer db.Close()
+
+	m, err := migrations.NewMigrator(db, backend)
+	if err != nil {
+		log.Println("[ERROR] Failed to load migrations:", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	var ran []migrate.Migration
+	switch os.Args[2] {
+
+	case "up":
+		ran, err = m.Up(ctx)
+
+	case "down":
+		ran, err = m.Down(ctx)
+
+	case "to":
+		ran, err = m.To(ctx, versionArg())
+
+	case "baseline":
+		version := versionArg()
+		if err := m.Baseline(ctx, version); err != nil {
+			log.Println("[ERROR] Baseline failed:", err)
+			os.Exit(1)
+		}
+		log.Printf("[SUCCESS] Recorded migrations up to %d as applied\n", version)
+		return
+
+	case "status":
+		printMigrationStatus(ctx, m)
+		return
+
+	default:
+		log.Println("[ERROR] Unknown migrate command:", os.Args[2])
+		os.Exit(1)
+	}
+
+	for _, mig := range ran {
+		log.Printf("[INFO] %03d_%s\n", mig.Version, mig.Name)
+	}
+	if err != nil {
+		log.Println("[ERROR] Migration failed:", err)
+		os.Exit(1)
+	}
+	if len(ran) == 0 {
+		log.Println("[SUCCESS] Schema already up to date")
+		return
+	}
+	log.Printf("[SUCCESS] Ran %d migration(s)\n", len(ran))
+}
+
+func versionArg() int {
+	if len(os.Args) < 4 {
+		log.Println("[ERROR] Expected a version number")
+		os.Exit(1)
+	}
+	version, err := strconv.Atoi(os.Args[3])
+	if err != nil || version < 0 {
+		log.Println("[ERROR] Invalid version:", os.Args[3])
+		os.Exit(1)
+	}
+	return version
+}
+
+func printMigrationStatus(ctx context.Context, m *migrate.Migrator) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		log.Println("[ERROR] Failed to read migration status:", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state, at := "pending", ""
+		if s.Applied {
+			state, at = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Drift {
+			state = "CHANGED SINCE APPLIED"
+		}
+		fmt.Fprintf(w, "%03d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+	}
+	w.Flush()
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/replica"
+	"gopherpay/internal/reporting"
+	"gopherpay/internal/statement"
+)
+
+// runReport writes a CSV report of a user's transactions, their audit trail
+// and summary stats. Rows are streamed from the database to the file, so
+// memory use does not grow with the number of transactions.
+func runReport() {
+
+	reportCmd := flag.NewFlagSet("report", flag.ExitOnError)
+	userIDFlag := reportCmd.String("user", "", "User account ID (required)")
+	fromFlag := reportCmd.String("from", "", "Only transactions created from this YYYY-MM-DD date or RFC 3339 time")
+	toFlag := reportCmd.String("to", "", "Only transactions created before this RFC 3339 time, or up to and including this YYYY-MM-DD date")
+	statusFlag := reportCmd.String("status", "", "Comma separated statuses to include: SUCCESS, FAILED, PENDING (default: all)")
+	outputFlag := reportCmd.String("output", "", "File to write, or - for stdout (default: user_<id>_report.csv)")
+	timeoutFlag := reportCmd.Duration("timeout", 10*time.Minute, "Give up if the report takes longer than this")
+	primaryFlag := reportCmd.Bool("primary", false, "Read from the primary even if database.replicas are configured")
+
+	if err := reportCmd.Parse(os.Args[2:]); err != nil {
+		log.Println("[ERROR] Failed to parse flags:", err)
+		os.Exit(1)
+	}
+
+	if *userIDFlag == "" {
+		log.Println("[ERROR] --user flag is required")
+		os.Exit(1)
+	}
+
+	userID, err := strconv.ParseUint(*userIDFlag, 10, 64)
+	if err != nil {
+		log.Println("[ERROR] Invalid user ID:", err)
+		os.Exit(1)
+	}
+
+	filter := reporting.Filter{AccountID: userID}
+
+	from, to, err := statement.ParsePeriod(*fromFlag, *toFlag, time.Now().UTC())
+	if err != nil {
+		log.Println("[ERROR]", err)
+		os.Exit(1)
+	}
+	if *fromFlag != "" {
+		filter.From = from
+	}
+	if *toFlag != "" {
+		filter.To = to
+	}
+
+	if filter.Statuses, err = parseStatuses(*statusFlag); err != nil {
+		log.Println("[ERROR]", err)
+		os.Exit(1)
+	}
+
+	fileName := *outputFlag
+	if fileName == "" {
+		fileName = fmt.Sprintf("user_%d_report.csv", userID)
+	}
+
+	log.Printf("[INFO] Generating comprehensive report for User ID: %d\n", userID)
+
+	db, backend := connectDB()
+	defer db.Close()
+
+	dialect := reporting.MySQL
+	switch backend {
+	case "postgres":
+		dialect = reporting.Postgres
+	case "sqlite":
+		dialect = reporting.SQLite
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+	defer cancel()
+
+	// The report reads from one healthy replica, the primary if none is
+	// healthy or --primary is set, inside one read-only transaction so
+	// every section sees the same point in time.
+	if *primaryFlag {
+		ctx = replica.WithPrimary(ctx)
+	}
+	db = reportDB(ctx, db, backend)
+
+	snapshot, end, err := beginSnapshot(ctx, db, backend)
+	if err != nil {
+		log.Println("[ERROR] Failed to begin transaction:", err)
+		os.Exit(1)
+	}
+	defer end()
+
+	var out io.Writer = os.Stdout
+	if fileName != "-" {
+		file, err := os.Create(fileName)
+		if err != nil {
+			log.Println("[ERROR] Failed to create file:", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	count, err := writeReport(ctx, snapshot, dialect, filter, out)
+	if err != nil {
+		log.Println("[ERROR] Failed to write report:", err)
+		if fileName != "-" {
+			os.Remove(fileName)
+		}
+		os.Exit(1)
+	}
+
+	log.Printf("[SUCCESS] Report generated successfully with %d transactions\n", count)
+	if fileName != "-" {
+		log.Printf("[INFO] Output file: %s\n", fileName)
+	}
+}
+
+// beginSnapshot starts the read-only transaction the report runs in and
+// returns it with the function that ends it.
+func beginSnapshot(ctx context.Context, db *sql.DB, backend string) (replica.Querier, func(), error) {
+
+	// Every SQLite transaction from database/sql is BEGIN IMMEDIATE, which
+	// would hold off the server's transfers for as long as the report runs.
+	// A deferred one on its own connection reads a WAL snapshot instead.
+	if backend == "sqlite" {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := conn.ExecContext(ctx, "BEGIN DEFERRED"); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		return conn, func() {
+			conn.ExecContext(context.Background(), "ROLLBACK")
+			conn.Close()
+		}, nil
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, func() { tx.Rollback() }, nil
+}
+
+func parseStatuses(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var statuses []string
+	for _, raw := range strings.Split(s, ",") {
+		status := billing.TransactionStatus(strings.ToUpper(strings.TrimSpace(raw)))
+		switch status {
+		case billing.StatusSuccess, billing.StatusFailed, billing.StatusPending:
+			statuses = append(statuses, string(status))
+		default:
+			return nil, fmt.Errorf("unknown status %q (want SUCCESS, FAILED or PENDING)", raw)
+		}
+	}
+	return statuses, nil
+}
+
+// writeReport writes the report's three sections to out as rows arrive and
+// returns the number of transactions in it.
+func writeReport(ctx context.Context, db replica.Querier, d reporting.Dialect, f reporting.Filter, out io.Writer) (int64, error) {
+
+	bufferedWriter := bufio.NewWriter(out)
+	csvWriter := csv.NewWriter(bufferedWriter)
+
+	// ===== SECTION 1: Transaction Overview =====
+	csvWriter.Write([]string{"=== TRANSACTION OVERVIEW ==="})
+	csvWriter.Write([]string{})
+	csvWriter.Write([]string{
+		"TransactionID",
+		"RequestID",
+		"FromAccountID",
+		"ToAccountID",
+		"Amount(Cents)",
+		"Status",
+		"ErrorMessage",
+		"FromAccountBalance",
+		"ToAccountBalance",
+		"CreatedAt",
+	})
+
+	var count int64
+	err := reporting.ForEachTransaction(ctx, db, d, f, func(detail reporting.TransactionDetail) error {
+		errorMsg := ""
+		if detail.ErrorMessage != nil {
+			errorMsg = *detail.ErrorMessage
+		}
+
+		count++
+		return csvWriter.Write([]string{
+			strconv.FormatUint(detail.TransactionID, 10),
+			detail.RequestID,
+			strconv.FormatUint(detail.FromAccountID, 10),
+			strconv.FormatUint(detail.ToAccountID, 10),
+			strconv.FormatInt(detail.Amount, 10),
+			detail.Status,
+			errorMsg,
+			strconv.FormatInt(detail.FromBalance, 10),
+			strconv.FormatInt(detail.ToBalance, 10),
+			detail.CreatedAt,
+		})
+	})
+	if err != nil {
+		return count, err
+	}
+
+	// ===== SECTION 2: Audit Trail =====
+	csvWriter.Write([]string{})
+	csvWriter.Write([]string{"=== AUDIT TRAIL ===", "", "", "", "", "", "", "", "", ""})
+	csvWriter.Write([]string{})
+	csvWriter.Write([]string{
+		"RequestID",
+		"Action",
+		"Status",
+		"Message",
+		"Timestamp",
+	})
+
+	err = reporting.ForEachAuditEntry(ctx, db, d, f, func(audit reporting.AuditEntry) error {
+		auditMsg := ""
+		if audit.Message != nil {
+			auditMsg = *audit.Message
+		}
+
+		return csvWriter.Write([]string{
+			audit.RequestID,
+			audit.Action,
+			audit.Status,
+			auditMsg,
+			audit.Timestamp,
+		})
+	})
+	if err != nil {
+		return count, err
+	}
+
+	// ===== SECTION 3: Summary Stats (Optional) =====
+	csvWriter.Write([]string{})
+	csvWriter.Write([]string{"=== SUMMARY STATS ===", "", "", "", "", ""})
+	csvWriter.Write([]string{})
+
+	// Fetch summary (lenient - continue if it fails)
+	summary, err := reporting.GetUserSummary(ctx, db, d, f)
+	if err != nil {
+		log.Printf("[WARN] Summary generation failed: %v, continuing without summary\n", err)
+		csvWriter.Write([]string{"Summary: Data unavailable"})
+	} else {
+		summaryRows := [][]string{
+			{"Total Transactions:", fmt.Sprintf("%d", summary.TotalTransactions)},
+			{"Successful:", fmt.Sprintf("%d", summary.SuccessfulCount)},
+			{"Failed:", fmt.Sprintf("%d", summary.FailedCount)},
+			{"Success Rate:", fmt.Sprintf("%.2f%%", summary.SuccessRate)},
+			{"Total Amount Sent :", fmt.Sprintf("%d", summary.TotalSent)},
+			{"Total Amount Received :", fmt.Sprintf("%d", summary.TotalReceived)},
+			{"Net Change :", fmt.Sprintf("%d", summary.NetChange)},
+			{"Account Created:", summary.AccountCreatedAt},
+			{"Last Activity:", summary.LastActivityAt},
+		}
+
+		for _, row := range summaryRows {
+			csvWriter.Write(row)
+		}
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return count, fmt.Errorf("CSV writer error: %w", err)
+	}
+	return count, bufferedWriter.Flush()
+}
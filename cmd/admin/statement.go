@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/replica"
+	"gopherpay/internal/statement"
+)
+
+// runStatement writes an account statement as CSV, JSON or plain text.
+// Lines are streamed from the database to the output, so the period can
+// be as long as needed.
+func runStatement() {
+
+	statementCmd := flag.NewFlagSet("statement", flag.ExitOnError)
+	accountFlag := statementCmd.Uint64("account", 0, "Account ID (required)")
+	fromFlag := statementCmd.String("from", "", "Start of the period, inclusive: YYYY-MM-DD or RFC 3339 (default: 1970-01-01)")
+	toFlag := statementCmd.String("to", "", "End of the period: YYYY-MM-DD includes that day, RFC 3339 is exclusive (default: now)")
+	formatFlag := statementCmd.String("format", "csv", "Output format: csv, json or text")
+	outputFlag := statementCmd.String("output", "-", "File to write, or - for stdout")
+	primaryFlag := statementCmd.Bool("primary", false, "Read from the primary even if database.replicas are configured")
+
+	if err := statementCmd.Parse(os.Args[2:]); err != nil {
+		log.Println("[ERROR] Failed to parse flags:", err)
+		os.Exit(1)
+	}
+
+	if *accountFlag == 0 {
+		log.Println("[ERROR] --account flag is required")
+		os.Exit(1)
+	}
+
+	if !slices.Contains(statement.Formats, *formatFlag) {
+		log.Println("[ERROR] --format must be csv, json or text")
+		os.Exit(1)
+	}
+
+	from, to, err := statement.ParsePeriod(*fromFlag, *toFlag, time.Now().UTC())
+	if err != nil {
+		log.Println("[ERROR]", err)
+		os.Exit(1)
+	}
+
+	db, backend := connectDB()
+	defer db.Close()
+
+	ctx := context.Background()
+	if *primaryFlag {
+		ctx = replica.WithPrimary(ctx)
+	}
+	db = reportDB(ctx, db, backend)
+
+	var repo billing.AccountReader = billing.NewMySQLRepository(db)
+	switch backend {
+	case "postgres":
+		repo = billing.NewPostgresRepository(db)
+	case "sqlite":
+		repo = billing.NewSQLiteRepository(db)
+	}
+
+	if _, err := repo.GetAccount(ctx, *accountFlag); err != nil {
+		log.Println("[ERROR] Failed to fetch account:", err)
+		os.Exit(1)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputFlag != "-" {
+		file, err := os.Create(*outputFlag)
+		if err != nil {
+			log.Println("[ERROR] Failed to create file:", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	w, err := statement.NewWriter(*formatFlag, out)
+	if err != nil {
+		log.Println("[ERROR]", err)
+		os.Exit(1)
+	}
+
+	sum, err := statement.Generate(ctx, repo, *accountFlag, from, to, w)
+	if err != nil {
+		log.Println("[ERROR] Failed to generate statement:", err)
+		if *outputFlag != "-" {
+			os.Remove(*outputFlag)
+		}
+		os.Exit(1)
+	}
+
+	log.Printf("[SUCCESS] Statement for account %d: %d line(s), closing balance %d\n",
+		*accountFlag, sum.Lines, sum.ClosingBalance)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/config"
+	"gopherpay/internal/memstore"
+
+	"github.com/google/uuid"
+)
+
+// benchStore is a storage backend with the account fixture the benchmark
+// seeds from.
+type benchStore interface {
+	billing.WalletRepository
+	audit.Repository
+	CreateAccount(ctx context.Context, balance int64) (uint64, error)
+}
+
+type billingStore interface {
+	billing.WalletRepository
+	CreateAccount(ctx context.Context, balance int64) (uint64, error)
+}
+
+type sqlStore struct {
+	billingStore
+	audit.Repository
+}
+
+// runConcurrency compares pessimistic and optimistic transfers at several
+// contention levels. Each level spreads the same number of transfers over
+// fewer or more accounts: the fewer accounts, the more transfers collide.
+// It runs on the configured storage backend (STORAGE), including memory.
+func runConcurrency() {
+
+	concurrencyCmd := flag.NewFlagSet("concurrency", flag.ExitOnError)
+	transfersFlag := concurrencyCmd.Int("transfers", 2000, "Transfers per run")
+	workersFlag := concurrencyCmd.Int("workers", 16, "Concurrent transfers")
+	accountsFlag := concurrencyCmd.String("accounts", "2,8,32,256", "Comma-separated account counts, one contention level each")
+	attemptsFlag := concurrencyCmd.Int("attempts", billing.DefaultRetryPolicy.MaxAttempts, "Attempts per transfer before giving up")
+
+	if err := concurrencyCmd.Parse(os.Args[2:]); err != nil {
+		log.Println("[ERROR] Failed to parse flags:", err)
+		os.Exit(1)
+	}
+
+	levels, err := parseLevels(*accountsFlag)
+	if err != nil || *transfersFlag < 1 || *workersFlag < 1 || *attemptsFlag < 1 {
+		log.Println("[ERROR] Need --accounts as counts >= 2, --transfers >= 1, --workers >= 1 and --attempts >= 1")
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Println("[ERROR] Config load failed:", err)
+		os.Exit(1)
+	}
+	store, closeStore := openBenchStore(cfg)
+	defer closeStore()
+
+	retry := billing.DefaultRetryPolicy
+	retry.MaxAttempts = *attemptsFlag
+
+	log.Printf("[INFO] Running on %s storage\n", cfg.Storage.Backend)
+	fmt.Printf("%-9s %-12s %10s %10s %10s %9s %12s %8s\n",
+		"accounts", "mode", "xfers/s", "p50", "p99", "retries", "retries/xfer", "gave up")
+	for _, n := range levels {
+		accounts := make([]uint64, n)
+		for i := range accounts {
+			id, err := store.CreateAccount(context.Background(), int64(1)<<40)
+			if err != nil {
+				log.Println("[ERROR] Failed to create account:", err)
+				os.Exit(1)
+			}
+			accounts[i] = id
+		}
+		reqs := uniformJobs(accounts, *transfersFlag)
+
+		for _, mode := range []billing.Concurrency{billing.Pessimistic, billing.Optimistic} {
+			r := runTransfers(store, mode, retry, *workersFlag, reqs)
+			fmt.Printf("%-9d %-12s %10.1f %10s %10s %9d %12.2f %8d\n",
+				n, mode, r.throughput, r.p50.Round(time.Microsecond), r.p99.Round(time.Microsecond),
+				r.retries, float64(r.retries)/float64(len(reqs)), r.gaveUp)
+		}
+	}
+}
+
+func parseLevels(s string) ([]int, error) {
+	var levels []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if n < 2 {
+			return nil, fmt.Errorf("need at least 2 accounts, got %d", n)
+		}
+		levels = append(levels, n)
+	}
+	return levels, nil
+}
+
+func openBenchStore(cfg config.Config) (benchStore, func()) {
+	if cfg.Storage.Backend == "memory" {
+		return memstore.New(), func() {}
+	}
+
+	db, err := cfg.OpenDB()
+	if err != nil {
+		log.Println("[ERROR] Database connection failed:", err)
+		os.Exit(1)
+	}
+
+	var store benchStore
+	switch cfg.Storage.Backend {
+	case "postgres":
+		store = sqlStore{billing.NewPostgresRepository(db), audit.NewPostgresRepository(db)}
+	case "sqlite":
+		store = sqlStore{billing.NewSQLiteRepository(db), audit.NewSQLiteRepository(db)}
+	default:
+		store = sqlStore{billing.NewMySQLRepository(db), audit.NewMySQLRepository(db)}
+	}
+	return store, func() { db.Close() }
+}
+
+// uniformJobs builds the workload once so both modes see identical
+// transfers between random pairs of accounts. The request IDs are
+// regenerated per run.
+func uniformJobs(accounts []uint64, n int) []billing.TransferRequest {
+	reqs := make([]billing.TransferRequest, n)
+	for i := range reqs {
+		from := accounts[rand.IntN(len(accounts))]
+		to := accounts[rand.IntN(len(accounts))]
+		for to == from {
+			to = accounts[rand.IntN(len(accounts))]
+		}
+		reqs[i] = billing.TransferRequest{FromID: from, ToID: to, Amount: 1, Subject: "bench"}
+	}
+	return reqs
+}
+
+type transferResult struct {
+	throughput float64
+	p50, p99   time.Duration
+	retries    int64
+	gaveUp     int64
+}
+
+// retryCounter is a billing.Observer that only counts retries.
+type retryCounter struct {
+	retries atomic.Int64
+}
+
+func (c *retryCounter) ObserveStep(string, time.Duration) {}
+func (c *retryCounter) ObserveRetry()                     { c.retries.Add(1) }
+
+// runTransfers calls Service.Transfer directly from workers goroutines, so
+// the numbers measure the transaction itself rather than queueing.
+func runTransfers(store benchStore, mode billing.Concurrency, retry billing.RetryPolicy, workers int, reqs []billing.TransferRequest) transferResult {
+	service := billing.NewService(store, store, slog.New(slog.DiscardHandler))
+	service.SetConcurrency(mode)
+	service.SetRetryPolicy(retry)
+	counter := &retryCounter{}
+	service.SetObserver(counter)
+
+	jobs := make(chan billing.TransferRequest)
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		gaveUp    atomic.Int64
+		latencies = make([]time.Duration, 0, len(reqs))
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range jobs {
+				start := time.Now()
+				err := service.Transfer(context.Background(), req)
+				d := time.Since(start)
+				if err != nil {
+					gaveUp.Add(1)
+				}
+				mu.Lock()
+				latencies = append(latencies, d)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	start := time.Now()
+	for _, req := range reqs {
+		req.RequestID = uuid.NewString()
+		jobs <- req
+	}
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	return transferResult{
+		throughput: float64(len(reqs)) / elapsed.Seconds(),
+		p50:        percentile(latencies, 0.50),
+		p99:        percentile(latencies, 0.99),
+		retries:    counter.retries.Load(),
+		gaveUp:     gaveUp.Load(),
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/worker"
+
+	"github.com/google/uuid"
+)
+
+// runDispatch compares the shared and per-account dispatch modes on the same
+// skewed workload: a share of all transfers leaves one hot account, the rest
+// are spread over the remaining accounts.
+func runDispatch() {
+
+	dispatchCmd := flag.NewFlagSet("dispatch", flag.ExitOnError)
+	jobsFlag := dispatchCmd.Int("jobs", 2000, "Transfers per run")
+	accountsFlag := dispatchCmd.Int("accounts", 50, "Accounts to create")
+	hotFlag := dispatchCmd.Float64("hot", 0.8, "Share of transfers sent from the hot account")
+	workersFlag := dispatchCmd.Int("workers", 10, "Workers (or lanes) per pool")
+
+	if err := dispatchCmd.Parse(os.Args[2:]); err != nil {
+		log.Println("[ERROR] Failed to parse flags:", err)
+		os.Exit(1)
+	}
+
+	if *accountsFlag < 3 || *jobsFlag < 1 || *workersFlag < 1 || *hotFlag < 0 || *hotFlag > 1 {
+		log.Println("[ERROR] Need --accounts >= 3, --jobs >= 1, --workers >= 1 and 0 <= --hot <= 1")
+		os.Exit(1)
+	}
+
+	db := connectDB()
+	defer db.Close()
+
+	accounts := seedAccounts(db, *accountsFlag)
+	jobs := skewedJobs(accounts, *jobsFlag, *hotFlag)
+
+	fmt.Printf("%-8s %10s %10s %10s %12s %14s\n", "mode", "jobs/s", "p50", "p99", "lock waits", "lock wait ms")
+	for _, mode := range []struct {
+		name     string
+		dispatch worker.Dispatch
+	}{
+		{"shared", worker.DispatchShared},
+		{"account", worker.DispatchByAccount},
+	} {
+		r := runPool(db, mode.dispatch, *workersFlag, jobs)
+		fmt.Printf("%-8s %10.1f %10s %10s %12d %14d\n",
+			mode.name, r.throughput, r.p50.Round(time.Millisecond), r.p99.Round(time.Millisecond), r.lockWaits, r.lockWaitMillis)
+	}
+}
+
+func seedAccounts(db *sql.DB, n int) []uint64 {
+	ids := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		result, err := db.Exec(`INSERT INTO accounts (balance) VALUES (?)`, int64(1)<<40)
+		if err != nil {
+			log.Println("[ERROR] Failed to create account:", err)
+			os.Exit(1)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			log.Println("[ERROR] Failed to create account:", err)
+			os.Exit(1)
+		}
+		ids = append(ids, uint64(id))
+	}
+	return ids
+}
+
+// skewedJobs builds the workload once so both modes see identical jobs. The
+// request IDs are regenerated per run.
+func skewedJobs(accounts []uint64, n int, hot float64) []billing.TransferRequest {
+	hotID, rest := accounts[0], accounts[1:]
+
+	reqs := make([]billing.TransferRequest, n)
+	for i := range reqs {
+		from := hotID
+		if rand.Float64() >= hot {
+			from = rest[rand.IntN(len(rest))]
+		}
+		to := rest[rand.IntN(len(rest))]
+		for to == from {
+			to = rest[rand.IntN(len(rest))]
+		}
+		reqs[i] = billing.TransferRequest{FromID: from, ToID: to, Amount: 1, Subject: "bench"}
+	}
+	return reqs
+}
+
+type runResult struct {
+	throughput     float64
+	p50, p99       time.Duration
+	lockWaits      int64
+	lockWaitMillis int64
+}
+
+func runPool(db *sql.DB, dispatch worker.Dispatch, workers int, reqs []billing.TransferRequest) runResult {
+	logr := slog.New(slog.DiscardHandler)
+	service := billing.NewService(billing.NewMySQLRepository(db), audit.NewMySQLRepository(db), logr)
+
+	queue := worker.NewMemoryQueue(len(reqs))
+	pool := worker.NewPool(queue, service, logr)
+	pool.SetDispatch(dispatch)
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		submitted = make(map[string]time.Time, len(reqs))
+		latencies = make([]time.Duration, 0, len(reqs))
+	)
+	pool.OnComplete(func(job worker.TransferJob, err error) {
+		mu.Lock()
+		latencies = append(latencies, time.Since(submitted[job.Request.RequestID]))
+		mu.Unlock()
+		wg.Done()
+	})
+
+	waitsBefore, millisBefore := lockWaits(db)
+	start := time.Now()
+	pool.Start(workers)
+
+	for _, req := range reqs {
+		req.RequestID = uuid.NewString()
+
+		mu.Lock()
+		submitted[req.RequestID] = time.Now()
+		mu.Unlock()
+
+		wg.Add(1)
+		if err := pool.Submit(context.Background(), worker.TransferJob{Request: req}); err != nil {
+			log.Println("[ERROR] Failed to submit job:", err)
+			os.Exit(1)
+		}
+	}
+
+	wg.Wait()
+	elapsed := time.Since(start)
+	pool.Shutdown(context.Background())
+	waitsAfter, millisAfter := lockWaits(db)
+
+	return runResult{
+		throughput:     float64(len(reqs)) / elapsed.Seconds(),
+		p50:            percentile(latencies, 0.50),
+		p99:            percentile(latencies, 0.99),
+		lockWaits:      waitsAfter - waitsBefore,
+		lockWaitMillis: millisAfter - millisBefore,
+	}
+}
@@ -0,0 +1,88 @@
+// Command bench runs load experiments. It creates its own accounts and
+// transactions, so point it at a scratch schema. dispatch needs MySQL;
+// concurrency runs on any storage backend.
+package main
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"gopherpay/internal/config"
+)
+
+func main() {
+
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	if len(os.Args) < 2 {
+		log.Println("[ERROR] Expected subcommand: dispatch or concurrency")
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+
+	case "dispatch":
+		runDispatch()
+
+	case "concurrency":
+		runConcurrency()
+
+	default:
+		log.Println("[ERROR] Unknown command")
+		os.Exit(1)
+	}
+}
+
+func connectDB() *sql.DB {
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Println("[ERROR] Config load failed:", err)
+		os.Exit(1)
+	}
+
+	// The experiments read InnoDB counters, so they only run on MySQL
+	db, err := config.ConnectDB("mysql", cfg.Database)
+	if err != nil {
+		log.Println("[ERROR] Database connection failed:", err)
+		os.Exit(1)
+	}
+
+	return db
+}
+
+// lockWaits reads InnoDB's cumulative row lock wait counters.
+func lockWaits(db *sql.DB) (waits, waitMillis int64) {
+	rows, err := db.Query(`SHOW GLOBAL STATUS WHERE Variable_name IN ('Innodb_row_lock_waits', 'Innodb_row_lock_time')`)
+	if err != nil {
+		log.Println("[ERROR] Failed to read lock stats:", err)
+		os.Exit(1)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var value int64
+		if err := rows.Scan(&name, &value); err != nil {
+			log.Println("[ERROR] Failed to read lock stats:", err)
+			os.Exit(1)
+		}
+		if name == "Innodb_row_lock_waits" {
+			waits = value
+		} else {
+			waitMillis = value
+		}
+	}
+	return waits, waitMillis
+}
+
+// percentile returns the p-th percentile of samples, sorting them in place.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[int(float64(len(samples)-1)*p)]
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,59 +11,287 @@ import (
 	"time"
 
 	"gopherpay/internal/audit"
+	"gopherpay/internal/auth"
 	"gopherpay/internal/billing"
 	"gopherpay/internal/config"
+	"gopherpay/internal/events"
+	appgrpc "gopherpay/internal/grpc"
+	pb "gopherpay/internal/grpc/gopherpayv1"
+	"gopherpay/internal/health"
 	apphttp "gopherpay/internal/http"
+	"gopherpay/internal/metrics"
 	"gopherpay/internal/middleware"
+	"gopherpay/internal/migrate"
+	"gopherpay/internal/ratelimit"
+	"gopherpay/internal/tracing"
 	"gopherpay/internal/worker"
+	"gopherpay/migrations"
 	"gopherpay/pkg/logger"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
 
-	// cfg := config.LoadDBConfig()
+	// Settings come from defaults, --config / GOPHERPAY_CONFIG, the
+	// environment and flags, in that order of precedence.
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// if err != nil {
-	//  log.Fatal(err)
-	// }
-	cfg, err := config.LoadDBConfig()
-	db, err := config.ConnectDB(cfg)
+	// storage.backend is sqlite (the default, a local file at
+	// storage.path), mysql, postgres or memory (--storage=memory), which
+	// runs without a database from seeded accounts lost on exit.
+	st, err := openStorage(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
+	db := st.db
 
 	logr := logger.NewLogger()
 
-	repo := billing.NewMySQLRepository(db)
-	auditRepo := audit.NewMySQLRepository(db)
-	service := billing.NewService(repo, auditRepo, logr)
-	accountsHandler := apphttp.NewAccountsHandler(repo)
-	transactionsHandler := apphttp.NewTransactionsHandler(repo)
-	auditHandler := apphttp.NewAuditHandler(auditRepo)
-
-	pool := worker.NewPool(100, service, logr) //lower buffer size to test backpressure (429)
-	pool.Start(10)
-
-	// handler := apphttp.NewTransferHandler(pool)
-	handler := apphttp.NewTransferHandler(pool, auditRepo)
-	healthHandler := apphttp.NewHealthHandler(db)
-
-	mux := http.NewServeMux()
-	mux.Handle("/transfer", middleware.RequestID(handler))
-	mux.Handle("/health", healthHandler)
-	mux.Handle("/accounts", accountsHandler)
-	mux.Handle("/transactions", transactionsHandler)
-	mux.Handle("/audit", auditHandler)
-
-	fs := http.FileServer(http.Dir("./web"))
-	mux.Handle("/", fs)
+	var migrator *migrate.Migrator
+	if db != nil {
+		migrator, err = migrations.NewMigrator(db, cfg.Storage.Backend)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	// With server.auto_migrate, pending migrations are applied before
+	// serving and the server refuses to start if an applied one has changed.
+	if migrator != nil && cfg.Server.AutoMigrate {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		ran, err := migrator.Up(ctx)
+		cancel()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, mig := range ran {
+			log.Printf("Applied migration %03d_%s", mig.Version, mig.Name)
+		}
+	}
+
+	// OTEL_TRACES_EXPORTER=otlp|stdout|file turns on tracing
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	repo := st.wallet
+	auditTracked := audit.NewInFlightRepository(st.audit)
+
+	// Prometheus metrics, served at /metrics
+	m := metrics.New(db)
+	auditWriter := m.Audit(auditTracked)
+
+	service := billing.NewService(tracing.Wallet(repo), tracing.Audit(auditWriter), logr)
+	service.SetObserver(m)
+	// Validate has already checked the mode.
+	concurrency, _ := billing.ParseConcurrency(cfg.Transfer.Concurrency)
+	service.SetConcurrency(concurrency)
+	retry := billing.DefaultRetryPolicy
+	retry.MaxAttempts = cfg.Transfer.MaxAttempts
+	service.SetRetryPolicy(retry)
+
+	// Transfer outcomes are fanned out to gRPC event streams
+	broker := events.NewBroker()
+
+	// Accepted transfers are queued in the storage database by default so
+	// they survive a crash; queue.backend memory keeps them in process
+	// instead, as does memory storage.
+	// Each priority has its own bounded lane, so a flood of low priority
+	// transfers is rejected with 429 without crowding out treasury and
+	// payroll transfers.
+	capacities := map[worker.Priority]int{
+		worker.PriorityHigh:   cfg.Queue.CapacityHigh,
+		worker.PriorityNormal: cfg.Queue.Capacity,
+		worker.PriorityLow:    cfg.Queue.CapacityLow,
+	}
+	weights := map[worker.Priority]int{
+		worker.PriorityHigh:   6,
+		worker.PriorityNormal: 3,
+		worker.PriorityLow:    1,
+	}
+
+	lane := func(p worker.Priority) worker.Queue {
+		return worker.NewMemoryQueue(capacities[p])
+	}
+	if cfg.Queue.Backend != "memory" {
+		normal := capacities[worker.PriorityNormal]
+		switch cfg.Storage.Backend {
+		case "mysql":
+			q := worker.NewMySQLQueue(db, normal, cfg.Queue.Lease)
+			lane = func(p worker.Priority) worker.Queue { return q.Lane(p, capacities[p]) }
+		case "postgres":
+			q := worker.NewPostgresQueue(db, normal, cfg.Queue.Lease)
+			lane = func(p worker.Priority) worker.Queue { return q.Lane(p, capacities[p]) }
+		case "sqlite":
+			q := worker.NewSQLiteQueue(db, normal, cfg.Queue.Lease)
+			lane = func(p worker.Priority) worker.Queue { return q.Lane(p, capacities[p]) }
+		}
+	}
+	var lanes []worker.Lane
+	for _, p := range worker.Priorities {
+		lanes = append(lanes, worker.Lane{Priority: p, Queue: lane(p), Weight: weights[p]})
+	}
+	queue := worker.NewPriorityQueue(lanes...)
+
+	pool := worker.NewPool(queue, service, logr)
+	pool.AddPropagator(middleware.RequestIDPropagator{})
+	pool.AddPropagator(tracing.Propagator{})
+	pool.OnComplete(m.ObserveTransfer)
+	pool.OnReject(m.ObserveRejection)
+	m.WatchPool(pool)
+	if cfg.Pool.Dispatch == "account" {
+		pool.SetDispatch(worker.DispatchByAccount)
+	}
+	pool.OnComplete(func(job worker.TransferJob, err error) {
+		broker.Publish(events.TransferEvent{
+			RequestID:  job.Request.RequestID,
+			Subject:    job.Request.Subject,
+			FromID:     job.Request.FromID,
+			ToID:       job.Request.ToID,
+			Amount:     job.Request.Amount,
+			Err:        err,
+			OccurredAt: time.Now(),
+		})
+	})
+	pool.Start(cfg.Pool.Workers)
+
+	// pool.max_workers enables autoscaling between pool.min_workers and it
+	autoscaleCtx, stopAutoscale := context.WithCancel(context.Background())
+	defer stopAutoscale()
+	if cfg.Pool.MaxWorkers > 0 {
+		go pool.Autoscale(autoscaleCtx, worker.AutoscaleConfig{
+			Min:        cfg.Pool.MinWorkers,
+			Max:        cfg.Pool.MaxWorkers,
+			Interval:   5 * time.Second,
+			MaxLatency: 2 * time.Second,
+		})
+	}
+
+	// Read endpoints use database.replicas while they pass their checks
+	replicaCtx, stopReplicaChecks := context.WithCancel(context.Background())
+	defer stopReplicaChecks()
+	if st.replicas != nil {
+		go st.replicas.Run(replicaCtx, cfg.Database.ReplicaCheckInterval)
+		log.Printf("Routing reads to %d replica(s)", st.replicas.Len())
+	}
+
+	// Transfers still queued after transfer.max_wait are failed as expired
+	// rather than executed late.
+	maxWait := cfg.Transfer.MaxWait
+	transferHandler := apphttp.NewTransferHandler(pool, auditWriter, repo)
+	transferHandler.SetMaxWait(maxWait)
+
+	// /readyz fails while any of these do, and while shutting down
+	checker := health.NewChecker(2 * time.Second)
+	if db != nil {
+		checker.Add("database", health.Database(db, 500*time.Millisecond))
+		checker.Add("connection_pool", health.ConnectionPool(db, 0.9))
+	}
+	checker.Add("queue", health.Queue(pool))
+	checker.Add("audit_backlog", health.AuditBacklog(auditTracked, int64(cfg.Health.AuditMaxInFlight)))
+	if st.replicas != nil {
+		checker.Add("replicas", health.Replicas(st.replicas))
+	}
+	if migrator != nil {
+		checker.Add("schema_version", health.SchemaVersion(db, migrator.Latest()))
+	}
+
+	// Manual resizes stay within the default ceiling, raised to pool.workers
+	// if that is higher. While autoscaling they are rejected with 409.
+	poolHandler := apphttp.NewPoolHandler(pool)
+	poolHandler.SetMaxWorkers(max(cfg.Pool.Workers, apphttp.DefaultMaxWorkers))
+
+	handlers := apphttp.Handlers{
+		Transfer:       transferHandler,
+		TransferStatus: apphttp.NewTransferStatusHandler(st.reader),
+		Accounts:       apphttp.NewAccountsHandler(st.reader),
+		Account:        apphttp.NewAccountHandler(st.reader),
+		Statement:      apphttp.NewStatementHandler(st.reader),
+		Transactions:   apphttp.NewTransactionsHandler(st.reader),
+		Audit:          apphttp.NewAuditHandler(st.auditReader),
+		Pool:           poolHandler,
+		Health:         apphttp.NewHealthHandler(st.pinger),
+		Live:           apphttp.NewLivenessHandler(),
+		Ready:          apphttp.NewReadinessHandler(checker),
+		Metrics:        m.Handler(),
+		Static:         http.FileServer(http.Dir("./web")),
+	}
+
+	// JWTs are accepted alongside API keys when a JWKS source is configured
+	var verifier *auth.JWTVerifier
+	if jwks := cfg.Auth.JWKS; jwks != "" {
+		keys := auth.NewKeySet(jwks, cfg.Auth.JWKSRefresh)
+		if err := keys.Refresh(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+		verifier = auth.NewJWTVerifier(keys, cfg.Auth.Audience, cfg.Auth.Issuer)
+	}
+
+	authenticator := auth.NewAuthenticator(st.auth, verifier)
+	authenticate := middleware.Authenticate(authenticator)
+	logRequests := middleware.Logging(logr)
+
+	// Per-client limits, applied before requests reach the worker pool so a
+	// single noisy client cannot fill its queue for everyone else.
+	limiter := ratelimit.NewMemoryStore(10 * time.Minute)
+	transferLimit := ratelimit.Limit{Rate: 5, Burst: 20}
+	readLimit := ratelimit.Limit{Rate: 10, Burst: 30}
+	// Shared by every route and by everyone behind one address, so it is
+	// looser than the per-subject limits; it bounds unauthenticated traffic.
+	ipLimit := middleware.RateLimitIP(limiter, ratelimit.Limit{Rate: 50, Burst: 100}, logr)
+
+	// Every API route is traced and gets a request ID first, then is rate
+	// limited per IP, then the caller is authenticated, then the request is
+	// logged with both and rate limited per subject and route.
+	// X-Read-Consistency: primary keeps its reads off the replicas.
+	mux, err := apphttp.NewRouter(handlers, func(route apphttp.Route) http.Handler {
+		limit := readLimit
+		if route.Name == "transfers.create" {
+			limit = transferLimit
+		}
+		limited := middleware.RateLimit(limiter, route.Name, limit, logr)(middleware.ReadConsistency(route.Handler))
+		traced := middleware.Trace(route.Name)(middleware.RequestID(ipLimit(authenticate(logRequests(limited)))))
+		return m.InstrumentRoute(route.Name, traced)
+	}, apphttp.RouterOptions{
+		ValidateResponses: cfg.Server.ValidateResponses,
+		Logger:            logr,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	server := &http.Server{
-		Addr:    ":8080",
+		Addr:    cfg.Server.HTTPAddr,
 		Handler: mux,
 	}
 
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(appgrpc.UnaryInterceptor(authenticator, logr)),
+		grpc.StreamInterceptor(appgrpc.StreamInterceptor(authenticator, logr)),
+	)
+	grpcAPI := appgrpc.NewServer(pool, repo, broker)
+	grpcAPI.SetMaxWait(maxWait)
+	pb.RegisterGopherPayServer(grpcServer, grpcAPI)
+
+	grpcAddr := cfg.Server.GRPCAddr
+
+	go func() {
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("gRPC server running on", grpcAddr)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
 	go func() {
-		log.Println("Server running on :8080")
+		log.Println("Server running on", cfg.Server.HTTPAddr)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
@@ -75,11 +304,44 @@ func main() {
 
 	log.Println("Shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Report not ready first and give load balancers server.shutdown_grace
+	// (default 0) to notice before new connections are refused.
+	checker.Drain()
+	time.Sleep(cfg.Server.ShutdownGrace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	server.Shutdown(ctx)
-	pool.Shutdown()
+
+	// Event streams only end when their subscription does, so end them all
+	// first; anything gRPC still has open when the shutdown timeout runs
+	// out is cut off.
+	broker.Close()
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		log.Println("gRPC server did not stop in time; closing open streams")
+		grpcServer.Stop()
+	}
+	stopAutoscale()
+	stopReplicaChecks()
+
+	// Queued transfers get pool.drain_timeout to finish; after that they
+	// stay in the database queue for the next start (or fail, in memory).
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Pool.DrainTimeout)
+	defer cancelDrain()
+	if err := pool.Shutdown(drainCtx); err != nil {
+		log.Println("Worker pool did not drain in time:", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Println("Failed to flush traces:", err)
+	}
 
 	log.Println("Server stopped gracefully")
 }
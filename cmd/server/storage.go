@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/auth"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/config"
+	apphttp "gopherpay/internal/http"
+	"gopherpay/internal/memstore"
+	"gopherpay/internal/replica"
+	"gopherpay/migrations"
+)
+
+// storage holds the repositories of the configured storage backend.
+type storage struct {
+	db       *sql.DB      // nil for memory storage
+	replicas *replica.Set // nil unless database.replicas is set
+
+	wallet billing.Store
+	audit  audit.Repository
+	auth   auth.Repository
+	pinger apphttp.Pinger
+
+	// reader and auditReader serve the read endpoints, from a replica
+	// when there is a healthy one. Checks that authorise a write, such as
+	// ownership of the account a transfer debits, use wallet instead.
+	reader      billing.AccountReader
+	auditReader audit.Reader
+}
+
+// readRepository is a SQL repository whose reads can go to replicas.
+type readRepository interface {
+	SetReader(q replica.Querier)
+}
+
+func openStorage(cfg config.Config) (*storage, error) {
+	switch cfg.Storage.Backend {
+	case "memory":
+		return openMemoryStorage(cfg.Storage)
+	case "sqlite":
+		return openSQLiteStorage(cfg.Storage)
+	}
+
+	db, err := config.ConnectDB(cfg.Storage.Backend, cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &storage{db: db, pinger: db}
+	var readers []readRepository
+	if cfg.Storage.Backend == "postgres" {
+		st.wallet, st.audit, st.auth = billing.NewPostgresRepository(db), audit.NewPostgresRepository(db), auth.NewPostgresRepository(db)
+		reader, auditReader := billing.NewPostgresRepository(db), audit.NewPostgresRepository(db)
+		st.reader, st.auditReader = reader, auditReader
+		readers = []readRepository{reader, auditReader}
+	} else {
+		st.wallet, st.audit, st.auth = billing.NewMySQLRepository(db), audit.NewMySQLRepository(db), auth.NewMySQLRepository(db)
+		reader, auditReader := billing.NewMySQLRepository(db), audit.NewMySQLRepository(db)
+		st.reader, st.auditReader = reader, auditReader
+		readers = []readRepository{reader, auditReader}
+	}
+
+	if len(cfg.Database.Replicas) > 0 {
+		st.replicas, err = config.OpenReplicas(cfg.Storage.Backend, cfg.Database, db)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		for _, r := range readers {
+			r.SetReader(st.replicas)
+		}
+	}
+
+	return st, nil
+}
+
+// openSQLiteStorage opens the file at storage.path, creating it if needed.
+// The file belongs to this server alone, so its migrations are applied on
+// every start regardless of server.auto_migrate, and a database with no
+// accounts yet is seeded like memory storage.
+func openSQLiteStorage(cfg config.StorageConfig) (*storage, error) {
+	db, err := config.OpenSQLite(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := migrations.NewMigrator(db, "sqlite")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	ran, err := migrator.Up(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, mig := range ran {
+		log.Printf("Applied migration %03d_%s", mig.Version, mig.Name)
+	}
+
+	wallet := billing.NewSQLiteRepository(db)
+	authRepo := auth.NewSQLiteRepository(db)
+
+	var seeded bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM accounts)`).Scan(&seeded); err != nil {
+		return nil, fmt.Errorf("failed to check for accounts: %w", err)
+	}
+	if !seeded {
+		key, err := seed(ctx, wallet, authRepo, cfg)
+		if err != nil {
+			return nil, err
+		}
+		keyFile := cfg.OperatorKeyFile()
+		if err := writeOperatorKey(keyFile, key); err != nil {
+			return nil, err
+		}
+		log.Printf("SQLite storage: created %s with %d account(s) of %d paise each", cfg.Path, cfg.SeedAccounts, cfg.SeedBalance)
+		log.Printf("SQLite storage: operator API key written to %s", keyFile)
+	}
+
+	auditRepo := audit.NewSQLiteRepository(db)
+	return &storage{
+		db:          db,
+		wallet:      wallet,
+		audit:       auditRepo,
+		auth:        authRepo,
+		pinger:      db,
+		reader:      wallet,
+		auditReader: auditRepo,
+	}, nil
+}
+
+// openMemoryStorage seeds an empty store with storage.seed_accounts
+// accounts and an operator API key, which is written to
+// storage.key_file since there is no admin CLI to issue one against a
+// store in another process.
+func openMemoryStorage(cfg config.StorageConfig) (*storage, error) {
+	store := memstore.New()
+
+	key, err := seed(context.Background(), store, store, cfg)
+	if err != nil {
+		return nil, err
+	}
+	keyFile := cfg.OperatorKeyFile()
+	if err := writeOperatorKey(keyFile, key); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Memory storage: %d account(s) with %d paise each; nothing is persisted", cfg.SeedAccounts, cfg.SeedBalance)
+	log.Printf("Memory storage: operator API key written to %s", keyFile)
+
+	return &storage{
+		wallet:      store,
+		audit:       store,
+		auth:        store,
+		pinger:      store,
+		reader:      store,
+		auditReader: store,
+	}, nil
+}
+
+type accountCreator interface {
+	CreateAccount(ctx context.Context, balance int64) (uint64, error)
+}
+
+type credentialCreator interface {
+	CreateCredential(ctx context.Context, cred *auth.Credential) (uint64, error)
+}
+
+// seed creates storage.seed_accounts accounts and an operator credential,
+// returning the credential's API key.
+func seed(ctx context.Context, accounts accountCreator, creds credentialCreator, cfg config.StorageConfig) (string, error) {
+	for i := 0; i < cfg.SeedAccounts; i++ {
+		if _, err := accounts.CreateAccount(ctx, cfg.SeedBalance); err != nil {
+			return "", err
+		}
+	}
+
+	key, err := auth.GenerateAPIKey()
+	if err != nil {
+		return "", err
+	}
+	_, err = creds.CreateCredential(ctx, &auth.Credential{
+		Kind:       auth.CredentialAPIKey,
+		Identifier: auth.HashAPIKey(key),
+		Role:       auth.RoleOperator,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to seed operator credential: %w", err)
+	}
+	return key, nil
+}
+
+// writeOperatorKey saves a seeded operator API key to path, readable only
+// by the server's user, so the key never reaches the logs.
+func writeOperatorKey(path, key string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write operator key: %w", err)
+	}
+	defer f.Close()
+
+	// OpenFile keeps the mode of a file that already exists
+	if err := f.Chmod(0o600); err != nil {
+		return fmt.Errorf("failed to write operator key: %w", err)
+	}
+	if _, err := f.WriteString(key + "\n"); err != nil {
+		return fmt.Errorf("failed to write operator key: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,77 @@
+// Package apierror defines the JSON error envelope returned by every
+// versioned GopherPay endpoint and the stable codes clients can match on.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"gopherpay/internal/billing"
+)
+
+type Code string
+
+const (
+	CodeInvalidRequest    Code = "invalid_request"
+	CodeInvalidAmount     Code = "invalid_amount"
+	CodeSameAccount       Code = "same_account"
+	CodeInsufficientFunds Code = "insufficient_funds"
+	CodeUnauthorized      Code = "unauthorized"
+	CodeForbidden         Code = "forbidden"
+	CodeNotFound          Code = "not_found"
+	CodeConflict          Code = "conflict"
+	CodeRateLimited       Code = "rate_limited"
+	CodeQueueFull         Code = "queue_full"
+	CodeExpired           Code = "expired"
+	CodeUnavailable       Code = "unavailable"
+	CodeInternal          Code = "internal_error"
+)
+
+type Envelope struct {
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// Write sends the envelope with the given HTTP status.
+func Write(w http.ResponseWriter, requestID string, status int, code Code, message string, details any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Envelope{
+		Code:      code,
+		Message:   message,
+		RequestID: requestID,
+		Details:   details,
+	})
+}
+
+// serviceErrors maps billing sentinel errors to stable API error codes.
+var serviceErrors = []struct {
+	err    error
+	status int
+	code   Code
+}{
+	{billing.ErrInvalidAmount, http.StatusBadRequest, CodeInvalidAmount},
+	{billing.ErrSameAccount, http.StatusBadRequest, CodeSameAccount},
+	{billing.ErrInsufficientFunds, http.StatusUnprocessableEntity, CodeInsufficientFunds},
+	{billing.ErrAccountNotFound, http.StatusNotFound, CodeNotFound},
+	{billing.ErrTransactionNotFound, http.StatusNotFound, CodeNotFound},
+	{billing.ErrTransferExpired, http.StatusGatewayTimeout, CodeExpired},
+	{billing.ErrTransferAborted, http.StatusServiceUnavailable, CodeUnavailable},
+	{billing.ErrRequestConflict, http.StatusConflict, CodeConflict},
+}
+
+// FromServiceError returns the HTTP status, code and client-safe message
+// for a billing error. Unrecognised errors map to 500 internal_error with an
+// empty message, since their text may carry driver details.
+func FromServiceError(err error) (status int, code Code, message string) {
+	for _, m := range serviceErrors {
+		if errors.Is(err, m.err) {
+			return m.status, m.code, m.err.Error()
+		}
+	}
+	return http.StatusInternalServerError, CodeInternal, ""
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// InFlightRepository counts audit writes that have started but not yet
+// finished. Transfers wait on their audit entries, so a growing count is an
+// early sign the audit table is holding everything up.
+type InFlightRepository struct {
+	Repository
+	inFlight atomic.Int64
+}
+
+func NewInFlightRepository(repo Repository) *InFlightRepository {
+	return &InFlightRepository{Repository: repo}
+}
+
+func (r *InFlightRepository) Log(ctx context.Context, entry *AuditLog) error {
+	r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	return r.Repository.Log(ctx, entry)
+}
+
+// InFlight returns the number of writes currently outstanding.
+func (r *InFlightRepository) InFlight() int64 {
+	return r.inFlight.Load()
+}
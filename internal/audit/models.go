@@ -5,6 +5,7 @@ import "time"
 type AuditLog struct {
 	ID        uint64
 	RequestID string
+	Subject   string // authenticated caller, empty for internal events
 	Action    string
 	Status    string
 	Message   *string
@@ -1,26 +1,63 @@
 package audit
  
-import "context"
+import (
+    "context"
+    "database/sql"
+)
  
 type Repository interface {
     Log(ctx context.Context, entry *AuditLog) error
 }
  
+// Reader serves the audit log API.
+type Reader interface {
+    GetRecentAuditLogs(ctx context.Context) ([]AuditLog, error)
+    GetRecentAuditLogsByCustomer(ctx context.Context, customerID uint64) ([]AuditLog, error)
+}
+ 
 func (r *MySQLRepository) GetRecentAuditLogs(ctx context.Context) ([]AuditLog, error) {
  
     query := `
-        SELECT id, request_id, action, status, message, created_at
+        SELECT id, request_id, subject, action, status, message, created_at
         FROM audit_logs
         ORDER BY created_at DESC
         LIMIT 50
     `
  
-    rows, err := r.db.QueryContext(ctx, query)
+    rows, err := r.read.QueryContext(ctx, query)
     if err != nil {
         return nil, err
     }
     defer rows.Close()
  
+    return scanAuditLogs(rows)
+}
+ 
+// GetRecentAuditLogsByCustomer returns audit entries for transfers that
+// touched an account owned by the customer.
+func (r *MySQLRepository) GetRecentAuditLogsByCustomer(ctx context.Context, customerID uint64) ([]AuditLog, error) {
+ 
+    query := `
+        SELECT a.id, a.request_id, a.subject, a.action, a.status, a.message, a.created_at
+        FROM audit_logs a
+        JOIN transactions t ON t.request_id = a.request_id
+        WHERE t.from_account_id IN (SELECT id FROM accounts WHERE customer_id = ?)
+           OR t.to_account_id IN (SELECT id FROM accounts WHERE customer_id = ?)
+        ORDER BY a.created_at DESC
+        LIMIT 50
+    `
+ 
+    rows, err := r.read.QueryContext(ctx, query, customerID, customerID)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+ 
+    return scanAuditLogs(rows)
+}
+ 
+func scanAuditLogs(rows *sql.Rows) ([]AuditLog, error) {
+ 
     var logs []AuditLog
  
     for rows.Next() {
@@ -28,6 +65,7 @@ func (r *MySQLRepository) GetRecentAuditLogs(ctx context.Context) ([]AuditLog, e
         if err := rows.Scan(
             &logEntry.ID,
             &logEntry.RequestID,
+            &logEntry.Subject,
             &logEntry.Action,
             &logEntry.Status,
             &logEntry.Message,
@@ -38,6 +76,6 @@ func (r *MySQLRepository) GetRecentAuditLogs(ctx context.Context) ([]AuditLog, e
         logs = append(logs, logEntry)
     }
  
-    return logs, nil
+    return logs, rows.Err()
 }
  
\ No newline at end of file
@@ -4,14 +4,23 @@ import (
     "context"
     "database/sql"
     "fmt"
+ 
+    "gopherpay/internal/replica"
 )
  
 type MySQLRepository struct {
-    db *sql.DB
+    db   *sql.DB
+    read replica.Querier
 }
  
 func NewMySQLRepository(db *sql.DB) *MySQLRepository {
-    return &MySQLRepository{db: db}
+    return &MySQLRepository{db: db, read: db}
+}
+ 
+// SetReader sends the Reader queries to q, typically a replica.Set,
+// instead of the primary.
+func (r *MySQLRepository) SetReader(q replica.Querier) {
+    r.read = q
 }
  
 // func (r *MySQLRepository) Log(ctx context.Context, entry *AuditLog) error {
@@ -36,11 +45,12 @@ func NewMySQLRepository(db *sql.DB) *MySQLRepository {
 //  }
 func (r *MySQLRepository) Log(ctx context.Context, entry *AuditLog) error {
  
-    query := `INSERT INTO audit_logs (request_id, action, status, message)
-              VALUES (?, ?, ?, ?)`
+    query := `INSERT INTO audit_logs (request_id, subject, action, status, message)
+              VALUES (?, ?, ?, ?, ?)`
  
     result, err := r.db.ExecContext(ctx, query,
         entry.RequestID,
+        entry.Subject,
         entry.Action,
         entry.Status,
         entry.Message,
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+func (r *SQLiteRepository) Log(ctx context.Context, entry *AuditLog) error {
+
+	query := `INSERT INTO audit_logs (request_id, subject, action, status, message)
+              VALUES (?1, ?2, ?3, ?4, ?5)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		entry.RequestID,
+		entry.Subject,
+		entry.Action,
+		entry.Status,
+		entry.Message,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit log: %w", err)
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) GetRecentAuditLogs(ctx context.Context) ([]AuditLog, error) {
+
+	query := `
+        SELECT id, request_id, subject, action, status, message, created_at
+        FROM audit_logs
+        ORDER BY created_at DESC
+        LIMIT 50
+    `
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditLogs(rows)
+}
+
+// GetRecentAuditLogsByCustomer returns audit entries for transfers that
+// touched an account owned by the customer.
+func (r *SQLiteRepository) GetRecentAuditLogsByCustomer(ctx context.Context, customerID uint64) ([]AuditLog, error) {
+
+	query := `
+        SELECT a.id, a.request_id, a.subject, a.action, a.status, a.message, a.created_at
+        FROM audit_logs a
+        JOIN transactions t ON t.request_id = a.request_id
+        WHERE t.from_account_id IN (SELECT id FROM accounts WHERE customer_id = ?1)
+           OR t.to_account_id IN (SELECT id FROM accounts WHERE customer_id = ?1)
+        ORDER BY a.created_at DESC
+        LIMIT 50
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAuditLogs(rows)
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUnboundCredential  = errors.New("credential is not bound to a customer")
+)
+
+// Authenticator resolves API keys and bearer JWTs to principals. It is
+// shared by the HTTP middleware and the gRPC interceptors so both transports
+// accept the same credentials.
+type Authenticator struct {
+	repo     Repository
+	verifier *JWTVerifier
+}
+
+// NewAuthenticator returns an Authenticator. A nil verifier disables JWTs.
+func NewAuthenticator(repo Repository, verifier *JWTVerifier) *Authenticator {
+	return &Authenticator{repo: repo, verifier: verifier}
+}
+
+// Authenticate prefers the bearer token when one is given and JWTs are
+// enabled, and otherwise uses the API key. Errors other than the sentinels
+// above mean the credential store is unavailable.
+func (a *Authenticator) Authenticate(ctx context.Context, apiKey, bearerToken string) (*Principal, error) {
+	if bearerToken != "" && a.verifier != nil {
+		return a.authenticateJWT(ctx, bearerToken)
+	}
+	if apiKey != "" {
+		return a.authenticateAPIKey(ctx, apiKey)
+	}
+	return nil, ErrMissingCredentials
+}
+
+func (a *Authenticator) authenticateAPIKey(ctx context.Context, key string) (*Principal, error) {
+	cred, err := a.repo.FindCredential(ctx, CredentialAPIKey, HashAPIKey(key))
+	if errors.Is(err, ErrCredentialNotFound) {
+		return nil, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return principalFromCredential(cred, "apikey:"+strconv.FormatUint(cred.ID, 10))
+}
+
+func (a *Authenticator) authenticateJWT(ctx context.Context, token string) (*Principal, error) {
+	claims, err := a.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	cred, err := a.repo.FindCredential(ctx, CredentialJWTSubject, claims.Subject)
+	if errors.Is(err, ErrCredentialNotFound) {
+		return nil, ErrUnboundCredential
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return principalFromCredential(cred, claims.Subject)
+}
+
+func principalFromCredential(cred *Credential, subject string) (*Principal, error) {
+	p := &Principal{
+		Subject: subject,
+		Role:    cred.Role,
+	}
+
+	if cred.CustomerID != nil {
+		p.CustomerID = *cred.CustomerID
+	} else if cred.Role != RoleOperator {
+		return nil, ErrUnboundCredential
+	}
+
+	return p, nil
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok && p != nil
+}
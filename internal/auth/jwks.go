@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKey = errors.New("no matching signing key")
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+}
+
+type verificationKey struct {
+	alg string // optional algorithm pinned by the JWK
+	key crypto.PublicKey
+}
+
+// KeySet caches the public keys of a JWKS document loaded from a file path
+// or an http(s) URL. Keys are reloaded after the TTL expires, and early when
+// a token references an unknown kid (key rotation). Reloads triggered by
+// tokens happen at most once per minRefresh interval, and while the source
+// is failing the cached keys keep being served.
+type KeySet struct {
+	source     string
+	client     *http.Client
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]verificationKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+func NewKeySet(source string, ttl time.Duration) *KeySet {
+	return &KeySet{
+		source:     source,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		ttl:        ttl,
+		minRefresh: 30 * time.Second,
+	}
+}
+
+// Key returns the verification key for kid, refreshing the set if needed.
+func (k *KeySet) Key(ctx context.Context, kid string) (verificationKey, error) {
+	k.mu.RLock()
+	key, found := k.lookup(kid)
+	stale := time.Since(k.fetchedAt) > k.ttl
+	k.mu.RUnlock()
+
+	if found && !stale {
+		return key, nil
+	}
+
+	if k.claimRefresh() {
+		if err := k.refresh(ctx); err != nil && !found {
+			return verificationKey{}, err
+		}
+
+		k.mu.RLock()
+		key, found = k.lookup(kid)
+		k.mu.RUnlock()
+	}
+
+	if !found {
+		return verificationKey{}, fmt.Errorf("%w: kid %q", ErrUnknownKey, kid)
+	}
+
+	return key, nil
+}
+
+// claimRefresh reports whether the caller may reload the set now, and if
+// so records the attempt, so concurrent requests trigger one reload.
+func (k *KeySet) claimRefresh() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if time.Since(k.lastAttempt) < k.minRefresh {
+		return false
+	}
+	k.lastAttempt = time.Now()
+	return true
+}
+
+// lookup must be called with k.mu held. A token without a kid is accepted
+// only when the set contains exactly one key.
+func (k *KeySet) lookup(kid string) (verificationKey, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// Refresh reloads the key set from its source. On failure the previously
+// cached keys are kept.
+func (k *KeySet) Refresh(ctx context.Context) error {
+	k.mu.Lock()
+	k.lastAttempt = time.Now()
+	k.mu.Unlock()
+
+	return k.refresh(ctx)
+}
+
+func (k *KeySet) refresh(ctx context.Context) error {
+	raw, err := k.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load jwks: %w", err)
+	}
+
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+func (k *KeySet) load(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(k.source, "http://") && !strings.HasPrefix(k.source, "https://") {
+		return os.ReadFile(k.source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func parseJWKS(raw []byte) (map[string]verificationKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("invalid jwks document: %w", err)
+	}
+
+	keys := make(map[string]verificationKey, len(doc.Keys))
+	for _, j := range doc.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+
+		pub, err := j.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk %q: %w", j.Kid, err)
+		}
+		keys[j.Kid] = verificationKey{alg: j.Alg, key: pub}
+	}
+
+	return keys, nil
+}
+
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, fmt.Errorf("bad modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, fmt.Errorf("bad exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+}
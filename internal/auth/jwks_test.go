@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer serves a JWKS document whose keys and health the test
+// controls, counting requests.
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    map[string]ed25519.PublicKey
+	failing bool
+	hits    atomic.Int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{keys: make(map[string]ed25519.PublicKey)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var doc struct {
+			Keys []jwk `json:"keys"`
+		}
+		for kid, pub := range s.keys {
+			doc.Keys = append(doc.Keys, jwk{
+				Kty: "OKP",
+				Crv: "Ed25519",
+				Kid: kid,
+				Alg: "EdDSA",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+		json.NewEncoder(w).Encode(doc)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// addKey publishes a new key under kid and returns its private half.
+func (s *jwksServer) addKey(t *testing.T, kid string) ed25519.PrivateKey {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.keys[kid] = pub
+	s.mu.Unlock()
+	return priv
+}
+
+func (s *jwksServer) setFailing(failing bool) {
+	s.mu.Lock()
+	s.failing = failing
+	s.mu.Unlock()
+}
+
+func signToken(t *testing.T, priv ed25519.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(jwtHeader{Alg: "EdDSA", Kid: kid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig := ed25519.Sign(priv, []byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestKeySetServesCachedKeysWhileSourceFails(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.addKey(t, "k1")
+
+	keys := NewKeySet(srv.URL, 10*time.Millisecond)
+	keys.minRefresh = time.Hour
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.setFailing(true)
+	time.Sleep(20 * time.Millisecond) // past the TTL
+	keys.lastAttempt = time.Time{}    // as if minRefresh had passed
+
+	for range 50 {
+		if _, err := keys.Key(context.Background(), "k1"); err != nil {
+			t.Fatalf("cached key not served: %v", err)
+		}
+	}
+
+	// The initial load plus one failed reload; the other lookups waited
+	// for minRefresh instead of fetching again.
+	if got := srv.hits.Load(); got != 2 {
+		t.Fatalf("jwks fetched %d times, want 2", got)
+	}
+}
+
+func TestKeySetRefreshesOnceForUnknownKid(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.addKey(t, "k1")
+
+	keys := NewKeySet(srv.URL, time.Hour)
+	keys.minRefresh = time.Hour
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	keys.lastAttempt = time.Time{}
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key(context.Background(), "forged")
+			if !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("err = %v, want ErrUnknownKey", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := srv.hits.Load(); got != 2 {
+		t.Fatalf("jwks fetched %d times, want 2", got)
+	}
+}
+
+func TestKeySetFailsWithoutCachedKey(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.setFailing(true)
+
+	keys := NewKeySet(srv.URL, time.Hour)
+	if _, err := keys.Key(context.Background(), "k1"); err == nil || !strings.Contains(err.Error(), "failed to load jwks") {
+		t.Fatalf("err = %v, want a load failure", err)
+	}
+}
+
+func TestJWTVerifierFollowsKeyRotation(t *testing.T) {
+	srv := newJWKSServer(t)
+	old := srv.addKey(t, "old")
+
+	keys := NewKeySet(srv.URL, time.Hour)
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewJWTVerifier(keys, "gopherpay", "https://issuer.example")
+
+	claims := map[string]any{
+		"sub": "alice",
+		"iss": "https://issuer.example",
+		"aud": "gopherpay",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+
+	if _, err := verifier.Verify(context.Background(), signToken(t, old, "old", claims)); err != nil {
+		t.Fatalf("token signed with the published key: %v", err)
+	}
+
+	rotated := srv.addKey(t, "new")
+	keys.lastAttempt = time.Time{} // as if minRefresh had passed
+
+	got, err := verifier.Verify(context.Background(), signToken(t, rotated, "new", claims))
+	if err != nil {
+		t.Fatalf("token signed with the rotated key: %v", err)
+	}
+	if got.Subject != "alice" {
+		t.Fatalf("subject = %q, want alice", got.Subject)
+	}
+
+	claims["aud"] = "someone-else"
+	if _, err := verifier.Verify(context.Background(), signToken(t, rotated, "new", claims)); !errors.Is(err, ErrWrongAudience) {
+		t.Fatalf("err = %v, want ErrWrongAudience", err)
+	}
+}
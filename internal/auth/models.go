@@ -0,0 +1,46 @@
+package auth
+
+import "time"
+
+type Role string
+
+const (
+	RoleCustomer Role = "CUSTOMER"
+	RoleOperator Role = "OPERATOR"
+)
+
+type CredentialKind string
+
+const (
+	CredentialAPIKey     CredentialKind = "API_KEY"
+	CredentialJWTSubject CredentialKind = "JWT_SUBJECT"
+)
+
+type Customer struct {
+	ID        uint64
+	Name      string
+	CreatedAt time.Time
+}
+
+// Credential binds an API key hash or a JWT subject to a customer.
+// Operator credentials may have no customer.
+type Credential struct {
+	ID         uint64
+	CustomerID *uint64
+	Kind       CredentialKind
+	Identifier string // sha256 hex of the API key, or the JWT subject
+	Role       Role
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+}
+
+// Principal is the authenticated caller attached to a request context.
+type Principal struct {
+	Subject    string
+	CustomerID uint64
+	Role       Role
+}
+
+func (p *Principal) IsOperator() bool {
+	return p != nil && p.Role == RoleOperator
+}
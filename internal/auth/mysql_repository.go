@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+type MySQLRepository struct {
+	db *sql.DB
+}
+
+func NewMySQLRepository(db *sql.DB) *MySQLRepository {
+	return &MySQLRepository{db: db}
+}
+
+func (r *MySQLRepository) FindCredential(ctx context.Context, kind CredentialKind, identifier string) (*Credential, error) {
+	query := `
+        SELECT id, customer_id, kind, identifier, role, created_at, revoked_at
+        FROM credentials
+        WHERE kind = ? AND identifier = ? AND revoked_at IS NULL
+    `
+
+	var cred Credential
+	err := r.db.QueryRowContext(ctx, query, kind, identifier).Scan(
+		&cred.ID,
+		&cred.CustomerID,
+		&cred.Kind,
+		&cred.Identifier,
+		&cred.Role,
+		&cred.CreatedAt,
+		&cred.RevokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch credential: %w", err)
+	}
+
+	return &cred, nil
+}
+
+func (r *MySQLRepository) CreateCustomer(ctx context.Context, name string) (uint64, error) {
+	query := `INSERT INTO customers (name) VALUES (?)`
+
+	result, err := r.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert customer: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted customer id: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
+func (r *MySQLRepository) AssignAccount(ctx context.Context, customerID, accountID uint64) error {
+	query := `UPDATE accounts SET customer_id = ? WHERE id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, customerID, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to assign account %d: %w", accountID, err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("account %d not found", accountID)
+	}
+
+	return nil
+}
+
+func (r *MySQLRepository) CreateCredential(ctx context.Context, cred *Credential) (uint64, error) {
+	query := `
+        INSERT INTO credentials (customer_id, kind, identifier, role)
+        VALUES (?, ?, ?, ?)
+    `
+
+	result, err := r.db.ExecContext(ctx, query, cred.CustomerID, cred.Kind, cred.Identifier, cred.Role)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert credential: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted credential id: %w", err)
+	}
+
+	return uint64(id), nil
+}
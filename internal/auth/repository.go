@@ -0,0 +1,14 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrCredentialNotFound = errors.New("credential not found")
+
+type Repository interface {
+	// FindCredential returns the active credential of the given kind, or
+	// ErrCredentialNotFound if it does not exist or has been revoked.
+	FindCredential(ctx context.Context, kind CredentialKind, identifier string) (*Credential, error)
+}
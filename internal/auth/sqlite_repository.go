@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
+	return &SQLiteRepository{db: db}
+}
+
+func (r *SQLiteRepository) FindCredential(ctx context.Context, kind CredentialKind, identifier string) (*Credential, error) {
+	query := `
+        SELECT id, customer_id, kind, identifier, role, created_at, revoked_at
+        FROM credentials
+        WHERE kind = ?1 AND identifier = ?2 AND revoked_at IS NULL
+    `
+
+	var cred Credential
+	err := r.db.QueryRowContext(ctx, query, kind, identifier).Scan(
+		&cred.ID,
+		&cred.CustomerID,
+		&cred.Kind,
+		&cred.Identifier,
+		&cred.Role,
+		&cred.CreatedAt,
+		&cred.RevokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch credential: %w", err)
+	}
+
+	return &cred, nil
+}
+
+func (r *SQLiteRepository) CreateCustomer(ctx context.Context, name string) (uint64, error) {
+	var id uint64
+	err := r.db.QueryRowContext(ctx, `INSERT INTO customers (name) VALUES (?1) RETURNING id`, name).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert customer: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r *SQLiteRepository) AssignAccount(ctx context.Context, customerID, accountID uint64) error {
+	query := `UPDATE accounts SET customer_id = ?1, updated_at = CURRENT_TIMESTAMP WHERE id = ?2`
+
+	result, err := r.db.ExecContext(ctx, query, customerID, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to assign account %d: %w", accountID, err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("account %d not found", accountID)
+	}
+
+	return nil
+}
+
+func (r *SQLiteRepository) CreateCredential(ctx context.Context, cred *Credential) (uint64, error) {
+	query := `
+        INSERT INTO credentials (customer_id, kind, identifier, role)
+        VALUES (?1, ?2, ?3, ?4)
+        RETURNING id
+    `
+
+	var id uint64
+	err := r.db.QueryRowContext(ctx, query, cred.CustomerID, cred.Kind, cred.Identifier, cred.Role).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert credential: %w", err)
+	}
+
+	return id, nil
+}
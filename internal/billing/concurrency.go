@@ -0,0 +1,30 @@
+package billing
+
+import "fmt"
+
+// Concurrency is how Transfer keeps concurrent transfers on the same
+// account from overwriting each other's balance updates.
+type Concurrency string
+
+const (
+	// Pessimistic locks both accounts with SELECT ... FOR UPDATE before
+	// reading them, so concurrent transfers on an account queue behind
+	// each other.
+	Pessimistic Concurrency = "pessimistic"
+
+	// Optimistic reads both accounts without locks and updates each one
+	// only if its version is unchanged. A transfer that loses the race is
+	// rolled back and retried under the RetryPolicy. It avoids holding
+	// locks across the read, which pays off while contention is low; on
+	// hot accounts the retries cost more than waiting would.
+	Optimistic Concurrency = "optimistic"
+)
+
+// ParseConcurrency accepts "pessimistic" or "optimistic".
+func ParseConcurrency(s string) (Concurrency, error) {
+	switch c := Concurrency(s); c {
+	case Pessimistic, Optimistic:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown concurrency mode %q (want pessimistic or optimistic)", s)
+}
@@ -3,12 +3,25 @@ package billing
 import "time"
 
 type Account struct {
-	ID        uint64
-	Balance   int64 // stored in cents
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID         uint64
+	CustomerID *uint64 // owning customer, nil for unowned accounts
+	Class      AccountClass
+	Balance    int64  // stored in cents
+	Version    uint64 // bumped by every balance update
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
+// AccountClass marks accounts whose outgoing transfers are business
+// critical and get scheduled ahead of ordinary ones.
+type AccountClass string
+
+const (
+	ClassStandard AccountClass = "standard"
+	ClassTreasury AccountClass = "treasury"
+	ClassPayroll  AccountClass = "payroll"
+)
+
 type TransactionStatus string
 
 const (
@@ -33,7 +46,19 @@ type Transaction struct {
 
 type TransferRequest struct {
 	RequestID string
+	Subject   string // authenticated caller that submitted the transfer
 	FromID    uint64
 	ToID      uint64
 	Amount    int64
 }
+
+// Validate checks the parts of a request that do not need the database.
+func (r TransferRequest) Validate() error {
+	if r.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if r.FromID == r.ToID {
+		return ErrSameAccount
+	}
+	return nil
+}
@@ -3,33 +3,64 @@ package billing
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
+
+	"gopherpay/internal/replica"
 )
 
 type MySQLRepository struct {
-	db *sql.DB
+	db   *sql.DB
+	read replica.Querier
 }
 
 func NewMySQLRepository(db *sql.DB) *MySQLRepository {
-	return &MySQLRepository{db: db}
+	return &MySQLRepository{db: db, read: db}
+}
+
+// SetReader sends the AccountReader queries to q, typically a replica.Set,
+// instead of the primary. Transactions always run on the primary.
+func (r *MySQLRepository) SetReader(q replica.Querier) {
+	r.read = q
 }
 
-func (r *MySQLRepository) BeginTx(ctx context.Context) (*sql.Tx, error) {
+func (r *MySQLRepository) BeginTx(ctx context.Context) (Tx, error) {
 	return r.db.BeginTx(ctx, nil)
 }
 
-func (r *MySQLRepository) GetAccountForUpdate(ctx context.Context, tx *sql.Tx, accountID uint64) (*Account, error) {
+// ErrForeignTx is returned when a repository is handed a Tx that another
+// backend created.
+var ErrForeignTx = errors.New("transaction was not started by this repository")
+
+func sqlTx(tx Tx) (*sql.Tx, error) {
+	stx, ok := tx.(*sql.Tx)
+	if !ok {
+		return nil, ErrForeignTx
+	}
+	return stx, nil
+}
+
+func (r *MySQLRepository) GetAccountForUpdate(ctx context.Context, tx Tx, accountID uint64) (*Account, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-        SELECT id, balance, created_at, updated_at
+        SELECT id, balance, version, created_at, updated_at
         FROM accounts
         WHERE id = ?
         FOR UPDATE
     `
 
-	row := tx.QueryRowContext(ctx, query, accountID)
+	row := stx.QueryRowContext(ctx, query, accountID)
 
 	var acc Account
-	err := row.Scan(&acc.ID, &acc.Balance, &acc.CreatedAt, &acc.UpdatedAt)
+	err = row.Scan(&acc.ID, &acc.Balance, &acc.Version, &acc.CreatedAt, &acc.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrAccountNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, err)
 	}
@@ -37,26 +68,40 @@ func (r *MySQLRepository) GetAccountForUpdate(ctx context.Context, tx *sql.Tx, a
 	return &acc, nil
 }
 
-func (r *MySQLRepository) GetAccountBalance(ctx context.Context, tx *sql.Tx, accountID uint64) (int64, error) {
+func (r *MySQLRepository) GetAccountBalance(ctx context.Context, tx Tx, accountID uint64) (int64, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return 0, err
+	}
+
 	query := `
         SELECT balance FROM accounts WHERE id = ?
     `
 	var bal int64
-	row := tx.QueryRowContext(ctx, query, accountID)
-	if err := row.Scan(&bal); err != nil {
+	row := stx.QueryRowContext(ctx, query, accountID)
+	err = row.Scan(&bal)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrAccountNotFound
+	}
+	if err != nil {
 		return 0, fmt.Errorf("failed to fetch balance for account %d: %w", accountID, err)
 	}
 	return bal, nil
 }
 
-func (r *MySQLRepository) UpdateAccountBalance(ctx context.Context, tx *sql.Tx, accountID uint64, newBalance int64) error {
+func (r *MySQLRepository) UpdateAccountBalance(ctx context.Context, tx Tx, accountID uint64, newBalance int64) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
 	query := `
         UPDATE accounts
-        SET balance = ?, updated_at = NOW()
+        SET balance = ?, version = version + 1, updated_at = NOW()
         WHERE id = ?
     `
 
-	_, err := tx.ExecContext(ctx, query, newBalance, accountID)
+	_, err = stx.ExecContext(ctx, query, newBalance, accountID)
 	if err != nil {
 		return fmt.Errorf("failed to update balance for account %d: %w", accountID, err)
 	}
@@ -64,19 +109,109 @@ func (r *MySQLRepository) UpdateAccountBalance(ctx context.Context, tx *sql.Tx,
 	return nil
 }
 
-func (r *MySQLRepository) InsertTransaction(ctx context.Context, tx *sql.Tx, txn *Transaction) (uint64, error) {
+// ReadAccount reads accountID inside tx without locking it.
+func (r *MySQLRepository) ReadAccount(ctx context.Context, tx Tx, accountID uint64) (*Account, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, balance, version, created_at, updated_at
+        FROM accounts
+        WHERE id = ?
+    `
+
+	var acc Account
+	err = stx.QueryRowContext(ctx, query, accountID).Scan(&acc.ID, &acc.Balance, &acc.Version, &acc.CreatedAt, &acc.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, err)
+	}
+
+	return &acc, nil
+}
+
+func (r *MySQLRepository) UpdateAccountBalanceAtVersion(ctx context.Context, tx Tx, accountID uint64, newBalance int64, version uint64) (bool, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+        UPDATE accounts
+        SET balance = ?, version = version + 1, updated_at = NOW()
+        WHERE id = ? AND version = ?
+    `
+
+	res, err := stx.ExecContext(ctx, query, newBalance, accountID, version)
+	if err != nil {
+		return false, fmt.Errorf("failed to update balance for account %d: %w", accountID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update balance for account %d: %w", accountID, err)
+	}
+
+	return n == 1, nil
+}
+
+// FindTransactionByRequestID looks up the row for requestID inside tx,
+// returning ErrTransactionNotFound if the transfer has not been recorded yet.
+func (r *MySQLRepository) FindTransactionByRequestID(ctx context.Context, tx Tx, requestID string) (*Transaction, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, request_id, from_account_id, to_account_id,
+               amount, status, error_message,
+               from_balance, to_balance,
+               created_at, updated_at
+        FROM transactions
+        WHERE request_id = ?
+        FOR UPDATE
+    `
+
+	rows, err := stx.QueryContext(ctx, query, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find transaction: %w", err)
+	}
+	defer rows.Close()
+
+	txns, err := scanTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(txns) == 0 {
+		return nil, ErrTransactionNotFound
+	}
+
+	return &txns[0], nil
+}
+
+func (r *MySQLRepository) InsertTransaction(ctx context.Context, tx Tx, txn *Transaction) (uint64, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return 0, err
+	}
+
 	query := `
         INSERT INTO transactions (request_id, from_account_id, to_account_id, amount,
-        status, from_balance, to_balance, created_at, updated_at)
-        VALUES (?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
+        status, error_message, from_balance, to_balance, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())
     `
 
-	result, err := tx.ExecContext(ctx, query,
+	result, err := stx.ExecContext(ctx, query,
 		txn.RequestID,
 		txn.FromAccountID,
 		txn.ToAccountID,
 		txn.Amount,
 		txn.Status,
+		txn.ErrorMessage,
 		txn.FromBalance,
 		txn.ToBalance,
 	)
@@ -92,14 +227,19 @@ func (r *MySQLRepository) InsertTransaction(ctx context.Context, tx *sql.Tx, txn
 	return uint64(id), nil
 }
 
-func (r *MySQLRepository) UpdateTransactionStatus(ctx context.Context, tx *sql.Tx, txnID uint64, status TransactionStatus, errMsg *string) error {
+func (r *MySQLRepository) UpdateTransactionStatus(ctx context.Context, tx Tx, txnID uint64, status TransactionStatus, errMsg *string) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
 	query := `
         UPDATE transactions
         SET status = ?, error_message = ?, updated_at = NOW()
         WHERE id = ?
     `
 
-	_, err := tx.ExecContext(ctx, query, status, errMsg, txnID)
+	_, err = stx.ExecContext(ctx, query, status, errMsg, txnID)
 	if err != nil {
 		return fmt.Errorf("failed to update transaction status: %w", err)
 	}
@@ -107,31 +247,144 @@ func (r *MySQLRepository) UpdateTransactionStatus(ctx context.Context, tx *sql.T
 	return nil
 }
 
+// CreateAccount opens a standard, unowned account.
+func (r *MySQLRepository) CreateAccount(ctx context.Context, balance int64) (uint64, error) {
+	result, err := r.db.ExecContext(ctx, `INSERT INTO accounts (balance) VALUES (?)`, balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert account: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inserted account id: %w", err)
+	}
+
+	return uint64(id), nil
+}
+
 func (r *MySQLRepository) GetAllAccounts(ctx context.Context) ([]Account, error) {
 
 	query := `
-        SELECT id, balance, created_at, updated_at
+        SELECT id, customer_id, class, balance, created_at, updated_at
+        FROM accounts
+        ORDER BY id ASC
+    `
+
+	rows, err := r.read.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAccounts(rows)
+}
+
+func (r *MySQLRepository) GetAccount(ctx context.Context, accountID uint64) (*Account, error) {
+
+	query := `
+        SELECT id, customer_id, class, balance, created_at, updated_at
+        FROM accounts
+        WHERE id = ?
+    `
+
+	var acc Account
+	err := r.read.QueryRowContext(ctx, query, accountID).Scan(
+		&acc.ID, &acc.CustomerID, &acc.Class, &acc.Balance, &acc.CreatedAt, &acc.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, err)
+	}
+
+	return &acc, nil
+}
+
+func (r *MySQLRepository) GetAccountsByCustomer(ctx context.Context, customerID uint64) ([]Account, error) {
+
+	query := `
+        SELECT id, customer_id, class, balance, created_at, updated_at
         FROM accounts
+        WHERE customer_id = ?
         ORDER BY id ASC
     `
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.read.QueryContext(ctx, query, customerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanAccounts(rows)
+}
+
+func (r *MySQLRepository) IsAccountOwnedBy(ctx context.Context, accountID, customerID uint64) (bool, error) {
+
+	query := `
+        SELECT COUNT(*)
+        FROM accounts
+        WHERE id = ? AND customer_id = ?
+    `
+
+	var n int
+	if err := r.read.QueryRowContext(ctx, query, accountID, customerID).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to check owner of account %d: %w", accountID, err)
+	}
+
+	return n > 0, nil
+}
+
+// GetAccountClass returns the class of an account, which decides the
+// default priority of transfers out of it.
+func (r *MySQLRepository) GetAccountClass(ctx context.Context, accountID uint64) (AccountClass, error) {
+
+	var class AccountClass
+	err := r.read.QueryRowContext(ctx, `SELECT class FROM accounts WHERE id = ?`, accountID).Scan(&class)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAccountNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch class of account %d: %w", accountID, err)
+	}
+
+	return class, nil
+}
+
+func (r *MySQLRepository) SetAccountClass(ctx context.Context, accountID uint64, class AccountClass) error {
+
+	result, err := r.db.ExecContext(ctx, `UPDATE accounts SET class = ? WHERE id = ?`, class, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to set class of account %d: %w", accountID, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set class of account %d: %w", accountID, err)
+	}
+	if n == 0 {
+		// MySQL reports 0 rows when the class is unchanged, so check
+		// the account actually exists.
+		if _, err := r.GetAccountClass(ctx, accountID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanAccounts(rows *sql.Rows) ([]Account, error) {
 	var accounts []Account
 
 	for rows.Next() {
 		var acc Account
-		if err := rows.Scan(&acc.ID, &acc.Balance, &acc.CreatedAt, &acc.UpdatedAt); err != nil {
+		if err := rows.Scan(&acc.ID, &acc.CustomerID, &acc.Class, &acc.Balance, &acc.CreatedAt, &acc.UpdatedAt); err != nil {
 			return nil, err
 		}
 		accounts = append(accounts, acc)
 	}
 
-	return accounts, nil
+	return accounts, rows.Err()
 }
 
 func (r *MySQLRepository) GetRecentTransactions(ctx context.Context) ([]Transaction, error) {
@@ -146,14 +399,80 @@ func (r *MySQLRepository) GetRecentTransactions(ctx context.Context) ([]Transact
         LIMIT 50
     `
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.read.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+func (r *MySQLRepository) GetTransactionByRequestID(ctx context.Context, requestID string) (*Transaction, error) {
+
+	query := `
+        SELECT id, request_id, from_account_id, to_account_id,
+               amount, status, error_message,
+               from_balance, to_balance,
+               created_at, updated_at
+        FROM transactions
+        WHERE request_id = ?
+    `
+
+	rows, err := r.read.QueryContext(ctx, query, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	txns, err := scanTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(txns) == 0 {
+		return nil, ErrTransactionNotFound
+	}
+
+	return &txns[0], nil
+}
+
+// GetRecentTransactionsByCustomer returns transactions where either side is
+// an account owned by the customer.
+func (r *MySQLRepository) GetRecentTransactionsByCustomer(ctx context.Context, customerID uint64) ([]Transaction, error) {
+
+	query := `
+        SELECT t.id, t.request_id, t.from_account_id, t.to_account_id,
+               t.amount, t.status, t.error_message,
+               t.from_balance, t.to_balance,
+               t.created_at, t.updated_at
+        FROM transactions t
+        WHERE t.from_account_id IN (SELECT id FROM accounts WHERE customer_id = ?)
+           OR t.to_account_id IN (SELECT id FROM accounts WHERE customer_id = ?)
+        ORDER BY t.created_at DESC
+        LIMIT 50
+    `
+
+	rows, err := r.read.QueryContext(ctx, query, customerID, customerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanTransactions(rows)
+}
+
+func scanTransactions(rows *sql.Rows) ([]Transaction, error) {
 	var txns []Transaction
+	err := forEachTransaction(rows, func(txn Transaction) error {
+		txns = append(txns, txn)
+		return nil
+	})
+	return txns, err
+}
 
+// forEachTransaction scans rows of transaction columns one at a time,
+// stopping at the first error from fn.
+func forEachTransaction(rows *sql.Rows, fn func(Transaction) error) error {
 	for rows.Next() {
 		var txn Transaction
 		if err := rows.Scan(
@@ -169,10 +488,60 @@ func (r *MySQLRepository) GetRecentTransactions(ctx context.Context) ([]Transact
 			&txn.CreatedAt,
 			&txn.UpdatedAt,
 		); err != nil {
-			return nil, err
+			return err
 		}
-		txns = append(txns, txn)
+		if err := fn(txn); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *MySQLRepository) BalanceAt(ctx context.Context, accountID uint64, at time.Time) (int64, error) {
+
+	// One statement, so the balance and the transfers since agree.
+	query := `
+        SELECT CAST(a.balance - COALESCE((
+                   SELECT SUM(CASE WHEN t.to_account_id = a.id THEN t.amount ELSE -t.amount END)
+                   FROM transactions t
+                   WHERE (t.from_account_id = a.id OR t.to_account_id = a.id)
+                     AND t.status = 'SUCCESS'
+                     AND t.created_at >= ?
+               ), 0) AS SIGNED)
+        FROM accounts a
+        WHERE a.id = ?
+    `
+
+	var bal int64
+	err := r.read.QueryRowContext(ctx, query, at.UTC(), accountID).Scan(&bal)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrAccountNotFound
 	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute balance of account %d: %w", accountID, err)
+	}
+	return bal, nil
+}
+
+func (r *MySQLRepository) ForEachPosting(ctx context.Context, accountID uint64, from, to time.Time, fn func(Transaction) error) error {
+
+	query := `
+        SELECT id, request_id, from_account_id, to_account_id,
+               amount, status, error_message,
+               from_balance, to_balance,
+               created_at, updated_at
+        FROM transactions
+        WHERE (from_account_id = ? OR to_account_id = ?)
+          AND status = 'SUCCESS'
+          AND created_at >= ? AND created_at < ?
+        ORDER BY id
+    `
+
+	rows, err := r.read.QueryContext(ctx, query, accountID, accountID, from.UTC(), to.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to query postings of account %d: %w", accountID, err)
+	}
+	defer rows.Close()
 
-	return txns, nil
+	return forEachTransaction(rows, fn)
 }
@@ -0,0 +1,25 @@
+package billing
+
+import "time"
+
+// Transfer steps reported to an Observer.
+const (
+	StepRequestLock   = "request_lock" // look up the request ID's row, locking it
+	StepLockWait      = "lock_wait"    // SELECT ... FOR UPDATE on both accounts
+	StepRead          = "read"         // unlocked read of both accounts, optimistic mode
+	StepBalanceUpdate = "balance_update"
+	StepRecord        = "record" // write the transaction row with its final status
+	StepCommit        = "commit"
+)
+
+// Observer receives timings from Transfer, e.g. to export metrics. Calls
+// are made synchronously on the worker goroutine and must be cheap.
+type Observer interface {
+	ObserveStep(step string, d time.Duration)
+	ObserveRetry()
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ObserveStep(string, time.Duration) {}
+func (nopObserver) ObserveRetry()                     {}
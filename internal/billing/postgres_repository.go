@@ -0,0 +1,479 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopherpay/internal/replica"
+)
+
+// PostgresRepository runs on the schema in migrations/postgres.
+//
+// Transactions run at Postgres' default READ COMMITTED isolation, which
+// for this workload behaves like MySQL's REPEATABLE READ: SELECT ... FOR
+// UPDATE locks the row and returns its latest committed version, so
+// Transfer's lock ordering and balance checks work unchanged. SERIALIZABLE
+// would add serialization failures without protecting anything the row
+// locks don't.
+type PostgresRepository struct {
+	db   *sql.DB
+	read replica.Querier
+}
+
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return &PostgresRepository{db: db, read: db}
+}
+
+// SetReader sends the AccountReader queries to q, typically a replica.Set,
+// instead of the primary. Transactions always run on the primary.
+func (r *PostgresRepository) SetReader(q replica.Querier) {
+	r.read = q
+}
+
+func (r *PostgresRepository) BeginTx(ctx context.Context) (Tx, error) {
+	return r.db.BeginTx(ctx, nil)
+}
+
+func (r *PostgresRepository) GetAccountForUpdate(ctx context.Context, tx Tx, accountID uint64) (*Account, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, balance, version, created_at, updated_at
+        FROM accounts
+        WHERE id = $1
+        FOR UPDATE
+    `
+
+	var acc Account
+	err = stx.QueryRowContext(ctx, query, accountID).Scan(&acc.ID, &acc.Balance, &acc.Version, &acc.CreatedAt, &acc.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, err)
+	}
+
+	return &acc, nil
+}
+
+func (r *PostgresRepository) GetAccountBalance(ctx context.Context, tx Tx, accountID uint64) (int64, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	var bal int64
+	err = stx.QueryRowContext(ctx, `SELECT balance FROM accounts WHERE id = $1`, accountID).Scan(&bal)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrAccountNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch balance for account %d: %w", accountID, err)
+	}
+	return bal, nil
+}
+
+func (r *PostgresRepository) UpdateAccountBalance(ctx context.Context, tx Tx, accountID uint64, newBalance int64) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE accounts
+        SET balance = $1, version = version + 1, updated_at = now()
+        WHERE id = $2
+    `
+
+	if _, err := stx.ExecContext(ctx, query, newBalance, accountID); err != nil {
+		return fmt.Errorf("failed to update balance for account %d: %w", accountID, err)
+	}
+
+	return nil
+}
+
+// ReadAccount reads accountID inside tx without locking it.
+func (r *PostgresRepository) ReadAccount(ctx context.Context, tx Tx, accountID uint64) (*Account, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, balance, version, created_at, updated_at
+        FROM accounts
+        WHERE id = $1
+    `
+
+	var acc Account
+	err = stx.QueryRowContext(ctx, query, accountID).Scan(&acc.ID, &acc.Balance, &acc.Version, &acc.CreatedAt, &acc.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, err)
+	}
+
+	return &acc, nil
+}
+
+func (r *PostgresRepository) UpdateAccountBalanceAtVersion(ctx context.Context, tx Tx, accountID uint64, newBalance int64, version uint64) (bool, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+        UPDATE accounts
+        SET balance = $1, version = version + 1, updated_at = now()
+        WHERE id = $2 AND version = $3
+    `
+
+	res, err := stx.ExecContext(ctx, query, newBalance, accountID, version)
+	if err != nil {
+		return false, fmt.Errorf("failed to update balance for account %d: %w", accountID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update balance for account %d: %w", accountID, err)
+	}
+
+	return n == 1, nil
+}
+
+// FindTransactionByRequestID looks up the row for requestID inside tx,
+// returning ErrTransactionNotFound if the transfer has not been recorded
+// yet. Postgres takes no gap lock when no row matches, so a transaction
+// advisory lock on the request ID stands in for InnoDB's: a second
+// delivery of the same request waits here until the first commits and
+// then finds its row, rather than failing on the unique index.
+func (r *PostgresRepository) FindTransactionByRequestID(ctx context.Context, tx Tx, requestID string) (*Transaction, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := stx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, requestID); err != nil {
+		return nil, fmt.Errorf("failed to lock request %s: %w", requestID, err)
+	}
+
+	query := `
+        SELECT id, request_id, from_account_id, to_account_id,
+               amount, status, error_message,
+               from_balance, to_balance,
+               created_at, updated_at
+        FROM transactions
+        WHERE request_id = $1
+        FOR UPDATE
+    `
+
+	rows, err := stx.QueryContext(ctx, query, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find transaction: %w", err)
+	}
+	defer rows.Close()
+
+	txns, err := scanTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(txns) == 0 {
+		return nil, ErrTransactionNotFound
+	}
+
+	return &txns[0], nil
+}
+
+func (r *PostgresRepository) InsertTransaction(ctx context.Context, tx Tx, txn *Transaction) (uint64, error) {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+        INSERT INTO transactions (request_id, from_account_id, to_account_id, amount,
+        status, error_message, from_balance, to_balance)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `
+
+	var id uint64
+	err = stx.QueryRowContext(ctx, query,
+		txn.RequestID,
+		txn.FromAccountID,
+		txn.ToAccountID,
+		txn.Amount,
+		txn.Status,
+		txn.ErrorMessage,
+		txn.FromBalance,
+		txn.ToBalance,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert transaction: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r *PostgresRepository) UpdateTransactionStatus(ctx context.Context, tx Tx, txnID uint64, status TransactionStatus, errMsg *string) error {
+	stx, err := sqlTx(tx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE transactions
+        SET status = $1, error_message = $2, updated_at = now()
+        WHERE id = $3
+    `
+
+	if _, err := stx.ExecContext(ctx, query, status, errMsg, txnID); err != nil {
+		return fmt.Errorf("failed to update transaction status: %w", err)
+	}
+
+	return nil
+}
+
+// CreateAccount opens a standard, unowned account.
+func (r *PostgresRepository) CreateAccount(ctx context.Context, balance int64) (uint64, error) {
+	var id uint64
+	err := r.db.QueryRowContext(ctx, `INSERT INTO accounts (balance) VALUES ($1) RETURNING id`, balance).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert account: %w", err)
+	}
+	return id, nil
+}
+
+func (r *PostgresRepository) GetAllAccounts(ctx context.Context) ([]Account, error) {
+
+	query := `
+        SELECT id, customer_id, class, balance, created_at, updated_at
+        FROM accounts
+        ORDER BY id ASC
+    `
+
+	rows, err := r.read.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAccounts(rows)
+}
+
+func (r *PostgresRepository) GetAccount(ctx context.Context, accountID uint64) (*Account, error) {
+
+	query := `
+        SELECT id, customer_id, class, balance, created_at, updated_at
+        FROM accounts
+        WHERE id = $1
+    `
+
+	var acc Account
+	err := r.read.QueryRowContext(ctx, query, accountID).Scan(
+		&acc.ID, &acc.CustomerID, &acc.Class, &acc.Balance, &acc.CreatedAt, &acc.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, err)
+	}
+
+	return &acc, nil
+}
+
+func (r *PostgresRepository) GetAccountsByCustomer(ctx context.Context, customerID uint64) ([]Account, error) {
+
+	query := `
+        SELECT id, customer_id, class, balance, created_at, updated_at
+        FROM accounts
+        WHERE customer_id = $1
+        ORDER BY id ASC
+    `
+
+	rows, err := r.read.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAccounts(rows)
+}
+
+func (r *PostgresRepository) IsAccountOwnedBy(ctx context.Context, accountID, customerID uint64) (bool, error) {
+
+	var owned bool
+	err := r.read.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM accounts WHERE id = $1 AND customer_id = $2)`,
+		accountID, customerID,
+	).Scan(&owned)
+	if err != nil {
+		return false, fmt.Errorf("failed to check owner of account %d: %w", accountID, err)
+	}
+
+	return owned, nil
+}
+
+func (r *PostgresRepository) GetAccountClass(ctx context.Context, accountID uint64) (AccountClass, error) {
+
+	var class AccountClass
+	err := r.read.QueryRowContext(ctx, `SELECT class FROM accounts WHERE id = $1`, accountID).Scan(&class)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrAccountNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch class of account %d: %w", accountID, err)
+	}
+
+	return class, nil
+}
+
+func (r *PostgresRepository) SetAccountClass(ctx context.Context, accountID uint64, class AccountClass) error {
+
+	// Postgres counts matched rows, unchanged or not, so 0 means no account.
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE accounts SET class = $1, updated_at = now() WHERE id = $2`, class, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to set class of account %d: %w", accountID, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to set class of account %d: %w", accountID, err)
+	}
+	if n == 0 {
+		return ErrAccountNotFound
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetRecentTransactions(ctx context.Context) ([]Transaction, error) {
+
+	query := `
+        SELECT id, request_id, from_account_id, to_account_id,
+               amount, status, error_message,
+               from_balance, to_balance,
+               created_at, updated_at
+        FROM transactions
+        ORDER BY created_at DESC
+        LIMIT 50
+    `
+
+	rows, err := r.read.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+func (r *PostgresRepository) GetTransactionByRequestID(ctx context.Context, requestID string) (*Transaction, error) {
+
+	query := `
+        SELECT id, request_id, from_account_id, to_account_id,
+               amount, status, error_message,
+               from_balance, to_balance,
+               created_at, updated_at
+        FROM transactions
+        WHERE request_id = $1
+    `
+
+	rows, err := r.read.QueryContext(ctx, query, requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	txns, err := scanTransactions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(txns) == 0 {
+		return nil, ErrTransactionNotFound
+	}
+
+	return &txns[0], nil
+}
+
+// GetRecentTransactionsByCustomer returns transactions where either side is
+// an account owned by the customer.
+func (r *PostgresRepository) GetRecentTransactionsByCustomer(ctx context.Context, customerID uint64) ([]Transaction, error) {
+
+	query := `
+        SELECT t.id, t.request_id, t.from_account_id, t.to_account_id,
+               t.amount, t.status, t.error_message,
+               t.from_balance, t.to_balance,
+               t.created_at, t.updated_at
+        FROM transactions t
+        WHERE t.from_account_id IN (SELECT id FROM accounts WHERE customer_id = $1)
+           OR t.to_account_id IN (SELECT id FROM accounts WHERE customer_id = $1)
+        ORDER BY t.created_at DESC
+        LIMIT 50
+    `
+
+	rows, err := r.read.QueryContext(ctx, query, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+func (r *PostgresRepository) BalanceAt(ctx context.Context, accountID uint64, at time.Time) (int64, error) {
+
+	// One statement, so the balance and the transfers since agree.
+	query := `
+        SELECT (a.balance - COALESCE((
+                   SELECT SUM(CASE WHEN t.to_account_id = a.id THEN t.amount ELSE -t.amount END)
+                   FROM transactions t
+                   WHERE (t.from_account_id = a.id OR t.to_account_id = a.id)
+                     AND t.status = 'SUCCESS'
+                     AND t.created_at >= $1
+               ), 0))::BIGINT
+        FROM accounts a
+        WHERE a.id = $2
+    `
+
+	var bal int64
+	err := r.read.QueryRowContext(ctx, query, at, accountID).Scan(&bal)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrAccountNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute balance of account %d: %w", accountID, err)
+	}
+	return bal, nil
+}
+
+func (r *PostgresRepository) ForEachPosting(ctx context.Context, accountID uint64, from, to time.Time, fn func(Transaction) error) error {
+
+	query := `
+        SELECT id, request_id, from_account_id, to_account_id,
+               amount, status, error_message,
+               from_balance, to_balance,
+               created_at, updated_at
+        FROM transactions
+        WHERE (from_account_id = $1 OR to_account_id = $1)
+          AND status = 'SUCCESS'
+          AND created_at >= $2 AND created_at < $3
+        ORDER BY id
+    `
+
+	rows, err := r.read.QueryContext(ctx, query, accountID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to query postings of account %d: %w", accountID, err)
+	}
+	defer rows.Close()
+
+	return forEachTransaction(rows, fn)
+}
@@ -2,19 +2,71 @@ package billing
 
 import (
 	"context"
-	"database/sql"
+	"time"
 )
 
+// Tx is one unit of work on a WalletRepository. Changes made through it are
+// visible to other transactions only after Commit. Rollback after Commit
+// does nothing, so it can always be deferred.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+// WalletRepository is the storage Service.Transfer runs on. Every method
+// taking a Tx must be given one returned by the same repository's BeginTx.
 type WalletRepository interface {
-	BeginTx(ctx context.Context) (*sql.Tx, error)
+	BeginTx(ctx context.Context) (Tx, error)
+
+	// GetAccountForUpdate reads an account and holds a row lock on it
+	// until tx ends.
+	GetAccountForUpdate(ctx context.Context, tx Tx, accountID uint64) (*Account, error)
+
+	GetAccountBalance(ctx context.Context, tx Tx, accountID uint64) (int64, error)
+
+	UpdateAccountBalance(ctx context.Context, tx Tx, accountID uint64, newBalance int64) error
+
+	// ReadAccount reads an account without locking it, for optimistic
+	// transfers that check its Version when they write.
+	ReadAccount(ctx context.Context, tx Tx, accountID uint64) (*Account, error)
 
-	GetAccountForUpdate(ctx context.Context, tx *sql.Tx, accountID uint64) (*Account, error)
+	// UpdateAccountBalanceAtVersion sets the balance only if the account is
+	// still at version, and reports whether it was.
+	UpdateAccountBalanceAtVersion(ctx context.Context, tx Tx, accountID uint64, newBalance int64, version uint64) (bool, error)
 
-	GetAccountBalance(ctx context.Context, tx *sql.Tx, accountID uint64) (int64, error)
+	FindTransactionByRequestID(ctx context.Context, tx Tx, requestID string) (*Transaction, error)
 
-	UpdateAccountBalance(ctx context.Context, tx *sql.Tx, accountID uint64, newBalance int64) error
+	InsertTransaction(ctx context.Context, tx Tx, txn *Transaction) (uint64, error)
 
-	InsertTransaction(ctx context.Context, tx *sql.Tx, txn *Transaction) (uint64, error)
+	UpdateTransactionStatus(ctx context.Context, tx Tx, txnID uint64, status TransactionStatus, errMsg *string) error
+}
+
+// AccountReader serves the read-only API and the ownership checks made
+// before a transfer is accepted.
+type AccountReader interface {
+	GetAllAccounts(ctx context.Context) ([]Account, error)
+	GetAccount(ctx context.Context, accountID uint64) (*Account, error)
+	GetAccountsByCustomer(ctx context.Context, customerID uint64) ([]Account, error)
+	IsAccountOwnedBy(ctx context.Context, accountID, customerID uint64) (bool, error)
+	GetAccountClass(ctx context.Context, accountID uint64) (AccountClass, error)
+
+	GetRecentTransactions(ctx context.Context) ([]Transaction, error)
+	GetRecentTransactionsByCustomer(ctx context.Context, customerID uint64) ([]Transaction, error)
+	GetTransactionByRequestID(ctx context.Context, requestID string) (*Transaction, error)
+
+	// BalanceAt returns an account's balance as of at: its current
+	// balance less every SUCCESS transfer on it recorded since.
+	BalanceAt(ctx context.Context, accountID uint64, at time.Time) (int64, error)
+
+	// ForEachPosting calls fn with every SUCCESS transfer on accountID
+	// recorded in [from, to), in the order they were recorded, reading
+	// rows as fn consumes them. It stops at the first error fn returns.
+	ForEachPosting(ctx context.Context, accountID uint64, from, to time.Time, fn func(Transaction) error) error
+}
 
-	UpdateTransactionStatus(ctx context.Context, tx *sql.Tx, txnID uint64, status TransactionStatus, errMsg *string) error
+// Store is everything a storage backend provides to billing.
+type Store interface {
+	WalletRepository
+	AccountReader
+	SetAccountClass(ctx context.Context, accountID uint64, class AccountClass) error
 }
@@ -0,0 +1,35 @@
+package billing
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy bounds how often Transfer re-runs after a transient database
+// error such as a deadlock or lock wait timeout.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts, including the first
+	BaseDelay   time.Duration // delay before the first retry
+	MaxDelay    time.Duration // cap on any single delay
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   25 * time.Millisecond,
+	MaxDelay:    time.Second,
+}
+
+// backoff returns the delay before the attempt following attempt n. It
+// doubles per attempt and picks uniformly in [d/2, d) so that transfers that
+// deadlocked against each other do not collide again in lockstep.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half)
+}
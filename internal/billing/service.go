@@ -5,131 +5,362 @@ import (
 	"errors"
 	"fmt"
 	"gopherpay/internal/audit"
+	"gopherpay/internal/dberr"
 	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("gopherpay/internal/billing")
+
 var (
 	ErrInvalidAmount     = errors.New("amount must be greater than zero")
 	ErrSameAccount       = errors.New("cannot transfer to same account")
 	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrTransferExpired   = errors.New("transfer expired before processing")
+	ErrTransferAborted   = errors.New("transfer aborted during shutdown")
+	ErrRequestConflict   = errors.New("request id already used for a different transfer")
+
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrTransactionNotFound = errors.New("transaction not found")
 )
 
 type Service struct {
-	repo   WalletRepository //repository for wallet operations
-	audit  audit.Repository //audit repository for logging transfer attempts
-	logger *slog.Logger
+	repo        WalletRepository //repository for wallet operations
+	audit       audit.Repository //audit repository for logging transfer attempts
+	logger      *slog.Logger
+	retry       RetryPolicy
+	concurrency Concurrency
+	observer    Observer
 }
 
 func NewService(repo WalletRepository, auditRepo audit.Repository, logger *slog.Logger) *Service {
 	return &Service{
-		repo:   repo,
-		audit:  auditRepo,
-		logger: logger,
+		repo:        repo,
+		audit:       auditRepo,
+		logger:      logger,
+		retry:       DefaultRetryPolicy,
+		concurrency: Pessimistic,
+		observer:    nopObserver{},
 	}
 }
 
-func (s *Service) markTransactionFailed(ctx context.Context, txnID uint64, message string) {
-	tx, err := s.repo.BeginTx(ctx)
-	if err != nil {
-		return
-	}
-	defer tx.Rollback()
+// SetObserver reports per-step timings and retries to o.
+func (s *Service) SetObserver(o Observer) {
+	s.observer = o
+}
 
-	_ = s.repo.UpdateTransactionStatus(ctx, tx, txnID, StatusFailed, &message)
-	_ = tx.Commit()
+// startStep opens a span for step. The returned func ends it, recording
+// err if any, and reports the step's duration to the observer.
+func (s *Service) startStep(ctx context.Context, step string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "billing."+step)
+	return ctx, func(err error) {
+		s.observer.ObserveStep(step, time.Since(start))
+		endSpan(span, err)
+	}
 }
 
-func (s *Service) markTransactionSuccess(ctx context.Context, txnID uint64) {
-	tx, err := s.repo.BeginTx(ctx)
+func endSpan(span trace.Span, err error) {
 	if err != nil {
-		return
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
-	defer tx.Rollback()
+	span.End()
+}
 
-	_ = s.repo.UpdateTransactionStatus(ctx, tx, txnID, StatusSuccess, nil)
-	_ = tx.Commit()
+// SetRetryPolicy replaces the policy used for transient database errors. A
+// MaxAttempts of 1 disables retries.
+func (s *Service) SetRetryPolicy(p RetryPolicy) {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	s.retry = p
+}
+
+// SetConcurrency selects how transfers guard balances against concurrent
+// updates. The default is Pessimistic.
+func (s *Service) SetConcurrency(c Concurrency) {
+	s.concurrency = c
 }
-func (s *Service) logAudit(ctx context.Context, requestID, action, status, message string) {
+
+func (s *Service) logAudit(ctx context.Context, req TransferRequest, action, status, message string) {
 	msg := message
 	_ = s.audit.Log(ctx, &audit.AuditLog{
-		RequestID: requestID,
+		RequestID: req.RequestID,
+		Subject:   req.Subject,
 		Action:    action,
 		Status:    status,
 		Message:   &msg,
 	})
 }
 
-func (s *Service) Transfer(ctx context.Context, req TransferRequest) error {
+// Transfer moves req.Amount from req.FromID to req.ToID. Each attempt is a
+// single transaction that writes the transaction row, with its final
+// status, together with the balance updates, so a row is SUCCESS exactly
+// when the funds moved. A shortfall commits a FAILED row and no balance
+// change. An attempt that fails leaves nothing behind; after the last one
+// only the audit log records the request.
+func (s *Service) Transfer(ctx context.Context, req TransferRequest) (err error) {
+	ctx, span := tracer.Start(ctx, "billing.Transfer", trace.WithAttributes(
+		attribute.String("request_id", req.RequestID),
+		attribute.Int64("from_id", int64(req.FromID)),
+		attribute.Int64("to_id", int64(req.ToID)),
+		attribute.Int64("amount", req.Amount),
+	))
+	defer func() { endSpan(span, err) }()
 
 	s.logger.Info("transfer started",
 		"request_id", req.RequestID,
+		"subject", req.Subject,
 		"from", req.FromID,
 		"to", req.ToID,
 		"amount", req.Amount,
 	)
 
-	if req.Amount <= 0 {
-		s.logAudit(ctx, req.RequestID, "TRANSFER", "FAILED", "invalid amount")
-		return ErrInvalidAmount
+	switch err := req.Validate(); err {
+	case ErrInvalidAmount:
+		s.logAudit(ctx, req, "TRANSFER", "FAILED", "invalid amount")
+		s.recordRejected(ctx, req, "invalid amount")
+		return err
+	case ErrSameAccount:
+		s.logAudit(ctx, req, "TRANSFER", "FAILED", "self transfer not allowed")
+		s.recordRejected(ctx, req, "self transfer not allowed")
+		return err
 	}
 
-	if req.FromID == req.ToID {
-		s.logAudit(ctx, req.RequestID, "TRANSFER", "FAILED", "self transfer not allowed")
-		return ErrSameAccount
+	for attempt := 1; ; attempt++ {
+		txnID, err := s.transferOnce(ctx, req)
+		if err == nil {
+			if txnID == 0 {
+				// An earlier delivery of this request already completed.
+				return nil
+			}
+
+			span.SetAttributes(attribute.Int("attempts", attempt))
+			s.logAudit(ctx, req, "TRANSFER", "SUCCESS",
+				fmt.Sprintf("transfer completed after %d attempt(s)", attempt))
+
+			s.logger.Info("transfer successful",
+				"request_id", req.RequestID,
+				"subject", req.Subject,
+				"txn_id", txnID,
+				"attempts", attempt,
+			)
+			return nil
+		}
+
+		if errors.Is(err, ErrInsufficientFunds) {
+			s.logger.Warn("transfer failed - insufficient funds",
+				"request_id", req.RequestID,
+				"subject", req.Subject,
+			)
+			s.logAudit(ctx, req, "TRANSFER", "FAILED", "insufficient funds")
+			return err
+		}
+
+		step := "database error"
+		var se *stepError
+		if errors.As(err, &se) {
+			step = se.step
+		}
+
+		// A failed COMMIT may or may not have applied, so it is never
+		// retried; the transaction row, if any, says which. A duplicate
+		// means a concurrent delivery of the same request recorded it
+		// first, and the next attempt finds its row.
+		if step == stepCommit {
+			s.logAudit(ctx, req, "TRANSFER", "UNKNOWN", "commit failed, outcome unknown")
+			s.recordGivenUp(ctx, req, step)
+			return err
+		}
+		retryable := dberr.IsTransient(err) || dberr.IsDuplicate(err)
+		if !retryable {
+			s.logAudit(ctx, req, "TRANSFER", "FAILED", step)
+			s.recordGivenUp(ctx, req, step)
+			return err
+		}
+		if attempt >= s.retry.MaxAttempts {
+			reason := fmt.Sprintf("gave up after %d attempts: %s", attempt, step)
+			s.logAudit(ctx, req, "TRANSFER", "FAILED", reason)
+			s.recordGivenUp(ctx, req, reason)
+			return err
+		}
+
+		delay := s.retry.backoff(attempt)
+		s.observer.ObserveRetry()
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("step", step),
+			attribute.String("delay", delay.String()),
+		))
+		s.logger.Warn("transfer hit transient error, retrying",
+			"request_id", req.RequestID,
+			"subject", req.Subject,
+			"attempt", attempt,
+			"delay", delay,
+			"error", err,
+		)
+		s.logAudit(ctx, req, "TRANSFER_RETRY", "RETRY",
+			fmt.Sprintf("attempt %d failed (%s), retrying in %s", attempt, step, delay))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			s.logAudit(context.WithoutCancel(ctx), req, "TRANSFER", "FAILED", "deadline exceeded while retrying")
+			s.recordGivenUp(ctx, req, "deadline exceeded while retrying")
+			return ctx.Err()
+		case <-timer.C:
+		}
 	}
+}
 
-	// -------------------------------------------------
-	// STEP 1: Insert transaction as PENDING (NO SQL TX)
-	// -------------------------------------------------
+// Abandon records a transfer that will never be executed, for example one
+// that waited in the queue past its deadline, as FAILED with cause as the
+// reason. Balances are not touched. It returns cause so callers can pass it
+// on as the transfer's outcome.
+func (s *Service) Abandon(ctx context.Context, req TransferRequest, cause error) error {
+	// The caller's context is usually what expired.
+	ctx = context.WithoutCancel(ctx)
 
-	pendingTxn := &Transaction{
-		RequestID:     req.RequestID,
-		FromAccountID: req.FromID,
-		ToAccountID:   req.ToID,
-		Amount:        req.Amount,
-		Status:        StatusPending,
+	s.logger.Warn("transfer abandoned",
+		"request_id", req.RequestID,
+		"subject", req.Subject,
+		"reason", cause,
+	)
+	s.logAudit(ctx, req, "TRANSFER", "FAILED", cause.Error())
+	s.recordRejected(ctx, req, cause.Error())
+	return cause
+}
+
+// recordGivenUp writes the FAILED row for a transfer Transfer stopped
+// trying, so its request ID still resolves. A cancelled (rather than
+// expired) context means the caller is handing the request back to be
+// delivered again, so nothing is recorded. After a failed COMMIT the lookup
+// waits on the request ID lock, so a commit that did apply is found and
+// left alone.
+func (s *Service) recordGivenUp(ctx context.Context, req TransferRequest, reason string) {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
 	}
+	s.recordRejected(context.WithoutCancel(ctx), req, reason)
+}
 
-	// We insert using normal DB connection (no tx yet)
-	txInsert, err := s.repo.BeginTx(ctx)
+// recordRejected is the best-effort record of a request turned down or
+// given up on without a row of its own: a FAILED row with reason, written only if the
+// request ID has no row yet, so it never contradicts one written by
+// transferOnce. Its balance snapshots are read without locks. Errors are
+// ignored (e.g. an unknown account cannot be referenced); the audit entry
+// stands either way.
+func (s *Service) recordRejected(ctx context.Context, req TransferRequest, reason string) {
+	tx, err := s.repo.BeginTx(ctx)
 	if err != nil {
-		return fmt.Errorf("begin insert tx failed: %w", err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := s.repo.FindTransactionByRequestID(ctx, tx, req.RequestID); !errors.Is(err, ErrTransactionNotFound) {
+		return
 	}
 
-	// Read current balances (no FOR UPDATE) to store snapshots
-	fromBal, err := s.repo.GetAccountBalance(ctx, txInsert, req.FromID)
+	fromBal, err := s.repo.GetAccountBalance(ctx, tx, req.FromID)
 	if err != nil {
-		txInsert.Rollback()
-		return fmt.Errorf("failed to read sender balance: %w", err)
+		return
 	}
-	toBal, err := s.repo.GetAccountBalance(ctx, txInsert, req.ToID)
+	toBal, err := s.repo.GetAccountBalance(ctx, tx, req.ToID)
 	if err != nil {
-		txInsert.Rollback()
-		return fmt.Errorf("failed to read receiver balance: %w", err)
+		return
 	}
 
-	pendingTxn.FromBalance = fromBal
-	pendingTxn.ToBalance = toBal
-
-	txnID, err := s.repo.InsertTransaction(ctx, txInsert, pendingTxn)
-	if err != nil {
-		txInsert.Rollback()
-		return err
+	if _, err := s.repo.InsertTransaction(ctx, tx, &Transaction{
+		RequestID:     req.RequestID,
+		FromAccountID: req.FromID,
+		ToAccountID:   req.ToID,
+		Amount:        req.Amount,
+		Status:        StatusFailed,
+		ErrorMessage:  &reason,
+		FromBalance:   fromBal,
+		ToBalance:     toBal,
+	}); err != nil {
+		return
 	}
+	_ = tx.Commit()
+}
 
-	if err := txInsert.Commit(); err != nil {
-		return err
+const (
+	stepRequestLookup   = "request lookup failed"
+	stepAlreadyFailed   = "already failed"
+	stepRequestConflict = "request id conflict"
+	stepAccountFetch    = "account fetch failed"
+	stepBalanceUpdate   = "balance update failed"
+	stepConflict        = "account changed concurrently"
+	stepRecord          = "transaction record failed"
+	stepCommit          = "commit failed"
+)
+
+// stepError records which part of the transfer transaction failed, for the
+// audit entry written once Transfer stops retrying.
+type stepError struct {
+	step string
+	err  error
+}
+
+func (e *stepError) Error() string { return e.step + ": " + e.err.Error() }
+func (e *stepError) Unwrap() error { return e.err }
+
+// transferOnce makes a single attempt at the transfer, all in one
+// transaction: it claims the request ID, locks (or, in optimistic mode,
+// reads) both accounts, moves the funds and writes the transaction row with
+// its final status and the balances seen under lock. If any step fails,
+// the rollback discards all of it. It returns the id of the committed row,
+// or 0 if an earlier delivery of the request already succeeded.
+func (s *Service) transferOnce(ctx context.Context, req TransferRequest) (uint64, error) {
+
+	tx, err := s.repo.BeginTx(ctx)
+	if err != nil {
+		return 0, err
 	}
+	defer tx.Rollback()
 
 	// -------------------------------------------------
-	// STEP 2: Begin SQL transaction for balance updates
+	// STEP 1: Claim the request ID
 	// -------------------------------------------------
 
-	tx, err := s.repo.BeginTx(ctx)
+	// The lock is held until commit, so a concurrent delivery of the same
+	// request waits here and then finds this one's row.
+	stepCtx, end := s.startStep(ctx, StepRequestLock)
+	existing, err := s.repo.FindTransactionByRequestID(stepCtx, tx, req.RequestID)
+	if errors.Is(err, ErrTransactionNotFound) {
+		existing, err = nil, nil
+	}
+	end(err)
 	if err != nil {
-		return err
+		return 0, &stepError{stepRequestLookup, err}
+	}
+	if existing != nil {
+		// A request ID names one transfer; reusing it for another must not
+		// report the first one's outcome.
+		if existing.FromAccountID != req.FromID || existing.ToAccountID != req.ToID || existing.Amount != req.Amount {
+			return 0, &stepError{stepRequestConflict, ErrRequestConflict}
+		}
+		switch existing.Status {
+		case StatusSuccess:
+			return 0, nil
+		case StatusFailed:
+			return 0, &stepError{stepAlreadyFailed, fmt.Errorf("transfer %s already failed", req.RequestID)}
+		}
+		// A PENDING row from a release that recorded transfers before
+		// moving the funds. It is settled below like a new one.
 	}
-	defer tx.Rollback()
+
+	// -------------------------------------------------
+	// STEP 2: Lock (or read) both accounts
+	// -------------------------------------------------
 
 	// Deadlock prevention: consistent lock ordering
 	firstID, secondID := req.FromID, req.ToID
@@ -137,16 +368,23 @@ func (s *Service) Transfer(ctx context.Context, req TransferRequest) error {
 		firstID, secondID = req.ToID, req.FromID
 	}
 
-	acc1, err := s.repo.GetAccountForUpdate(ctx, tx, firstID)
+	// In optimistic mode nothing is locked until the conditional updates.
+	step, fetch := StepLockWait, s.repo.GetAccountForUpdate
+	if s.concurrency == Optimistic {
+		step, fetch = StepRead, s.repo.ReadAccount
+	}
+
+	stepCtx, end = s.startStep(ctx, step)
+	acc1, err := fetch(stepCtx, tx, firstID)
 	if err != nil {
-		s.markTransactionFailed(ctx, txnID, "account fetch failed")
-		return err
+		end(err)
+		return 0, &stepError{stepAccountFetch, err}
 	}
 
-	acc2, err := s.repo.GetAccountForUpdate(ctx, tx, secondID)
+	acc2, err := fetch(stepCtx, tx, secondID)
+	end(err)
 	if err != nil {
-		s.markTransactionFailed(ctx, txnID, "account fetch failed")
-		return err
+		return 0, &stepError{stepAccountFetch, err}
 	}
 
 	var sender, receiver *Account
@@ -158,54 +396,136 @@ func (s *Service) Transfer(ctx context.Context, req TransferRequest) error {
 		receiver = acc1
 	}
 
+	txn := &Transaction{
+		RequestID:     req.RequestID,
+		FromAccountID: req.FromID,
+		ToAccountID:   req.ToID,
+		Amount:        req.Amount,
+		FromBalance:   sender.Balance,
+		ToBalance:     receiver.Balance,
+	}
+
 	// -------------------------------------------------
 	// STEP 3: Validate balance
 	// -------------------------------------------------
 
 	if sender.Balance < req.Amount {
-
-		s.logger.Warn("transfer failed - insufficient funds",
-			"request_id", req.RequestID,
-		)
-		s.logAudit(ctx, req.RequestID, "TRANSFER", "FAILED", "insufficient funds")
-
-		s.markTransactionFailed(ctx, txnID, "insufficient funds")
-		return ErrInsufficientFunds
+		// An optimistic read may be stale. Writing the balances back at
+		// the versions read confirms them under lock before the shortfall
+		// is recorded.
+		if s.concurrency == Optimistic {
+			stepCtx, end = s.startStep(ctx, StepBalanceUpdate)
+			err = s.updateAtVersions(stepCtx, tx, map[uint64]int64{
+				sender.ID:   sender.Balance,
+				receiver.ID: receiver.Balance,
+			}, acc1, acc2)
+			end(err)
+			if err != nil {
+				return 0, balanceUpdateError(err)
+			}
+		}
+
+		msg := "insufficient funds"
+		txn.Status = StatusFailed
+		txn.ErrorMessage = &msg
+		if _, err := s.record(ctx, tx, existing, txn); err != nil {
+			return 0, err
+		}
+		if err := s.commit(ctx, tx); err != nil {
+			return 0, err
+		}
+		return 0, ErrInsufficientFunds
 	}
 
 	// -------------------------------------------------
-	// STEP 4: Update balances atomically
+	// STEP 4: Update balances
 	// -------------------------------------------------
 
 	newSenderBalance := sender.Balance - req.Amount
 	newReceiverBalance := receiver.Balance + req.Amount
 
-	if err := s.repo.UpdateAccountBalance(ctx, tx, sender.ID, newSenderBalance); err != nil {
-		s.markTransactionFailed(ctx, txnID, "balance update failed")
-		return err
+	stepCtx, end = s.startStep(ctx, StepBalanceUpdate)
+	if s.concurrency == Optimistic {
+		err = s.updateAtVersions(stepCtx, tx, map[uint64]int64{
+			sender.ID:   newSenderBalance,
+			receiver.ID: newReceiverBalance,
+		}, acc1, acc2)
+	} else {
+		err = s.repo.UpdateAccountBalance(stepCtx, tx, sender.ID, newSenderBalance)
+		if err == nil {
+			err = s.repo.UpdateAccountBalance(stepCtx, tx, receiver.ID, newReceiverBalance)
+		}
+	}
+	end(err)
+	if err != nil {
+		return 0, balanceUpdateError(err)
 	}
 
-	if err := s.repo.UpdateAccountBalance(ctx, tx, receiver.ID, newReceiverBalance); err != nil {
-		s.markTransactionFailed(ctx, txnID, "balance update failed")
-		return err
+	// -------------------------------------------------
+	// STEP 5: Record SUCCESS and commit it with the balances
+	// -------------------------------------------------
+
+	txn.Status = StatusSuccess
+	txnID, err := s.record(ctx, tx, existing, txn)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.commit(ctx, tx); err != nil {
+		return 0, err
 	}
+	return txnID, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		s.markTransactionFailed(ctx, txnID, "commit failed")
-		return err
+func balanceUpdateError(err error) error {
+	if errors.Is(err, dberr.ErrConflict) {
+		return &stepError{stepConflict, err}
 	}
+	return &stepError{stepBalanceUpdate, err}
+}
 
-	// -------------------------------------------------
-	// STEP 5: Mark SUCCESS (outside balance transaction)
-	// -------------------------------------------------
+// record writes txn in tx: as a new row, or as the final status of
+// existing, a PENDING row for the same request.
+func (s *Service) record(ctx context.Context, tx Tx, existing, txn *Transaction) (uint64, error) {
+	ctx, end := s.startStep(ctx, StepRecord)
 
-	s.markTransactionSuccess(ctx, txnID)
-	s.logAudit(ctx, req.RequestID, "TRANSFER", "SUCCESS", "transfer completed")
+	var txnID uint64
+	var err error
+	if existing != nil {
+		txnID = existing.ID
+		err = s.repo.UpdateTransactionStatus(ctx, tx, txnID, txn.Status, txn.ErrorMessage)
+	} else {
+		txnID, err = s.repo.InsertTransaction(ctx, tx, txn)
+	}
+	end(err)
+	if err != nil {
+		return 0, &stepError{stepRecord, err}
+	}
+	return txnID, nil
+}
 
-	s.logger.Info("transfer successful",
-		"request_id", req.RequestID,
-		"txn_id", txnID,
-	)
+func (s *Service) commit(ctx context.Context, tx Tx) error {
+	_, end := s.startStep(ctx, StepCommit)
+	err := tx.Commit()
+	end(err)
+	if err != nil {
+		return &stepError{stepCommit, err}
+	}
+	return nil
+}
 
+// updateAtVersions writes balances to accounts, which must be in lock
+// order, only if each is still at the version it was read at. If one has
+// changed since, the transaction is left for the caller to roll back and
+// the returned dberr.ErrConflict makes Transfer retry it.
+func (s *Service) updateAtVersions(ctx context.Context, tx Tx, balances map[uint64]int64, accounts ...*Account) error {
+	for _, acc := range accounts {
+		ok, err := s.repo.UpdateAccountBalanceAtVersion(ctx, tx, acc.ID, balances[acc.ID], acc.Version)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("account %d: %w", acc.ID, dberr.ErrConflict)
+		}
+	}
 	return nil
 }
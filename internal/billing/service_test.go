@@ -0,0 +1,308 @@
+package billing_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/memstore"
+)
+
+// observer counts retries and runs hook, if set, at the end of each step.
+type observer struct {
+	mu      sync.Mutex
+	retries int
+	hook    func(step string)
+}
+
+func (o *observer) ObserveStep(step string, d time.Duration) {
+	if o.hook != nil {
+		o.hook(step)
+	}
+}
+
+func (o *observer) ObserveRetry() {
+	o.mu.Lock()
+	o.retries++
+	o.mu.Unlock()
+}
+
+func (o *observer) Retries() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.retries
+}
+
+func newService(store *memstore.Store, mode billing.Concurrency) (*billing.Service, *observer) {
+	obs := &observer{}
+	svc := billing.NewService(store, store, slog.New(slog.DiscardHandler))
+	svc.SetConcurrency(mode)
+	svc.SetObserver(obs)
+	svc.SetRetryPolicy(billing.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	return svc, obs
+}
+
+func createAccounts(t *testing.T, store *memstore.Store, balances ...int64) []uint64 {
+	t.Helper()
+
+	ids := make([]uint64, len(balances))
+	for i, b := range balances {
+		id, err := store.CreateAccount(context.Background(), b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+func wantBalances(t *testing.T, store *memstore.Store, ids []uint64, want ...int64) {
+	t.Helper()
+
+	for i, id := range ids {
+		acc, err := store.GetAccount(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acc.Balance != want[i] {
+			t.Errorf("account %d balance = %d, want %d", id, acc.Balance, want[i])
+		}
+	}
+}
+
+// A transfer from the higher account ID locks the lower one first, so it
+// never holds one account while waiting for the other in the opposite
+// order to a concurrent transfer.
+func TestTransferLocksAccountsInIDOrder(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	ids := createAccounts(t, store, 1000, 1000)
+	low, high := ids[0], ids[1]
+
+	svc, _ := newService(store, billing.Pessimistic)
+
+	// Hold the higher account so the transfer stops between its two locks.
+	blocker, err := store.BeginTx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetAccountForUpdate(ctx, blocker, high); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.Transfer(ctx, billing.TransferRequest{RequestID: "high-to-low", FromID: high, ToID: low, Amount: 100})
+	}()
+
+	// The lower account becomes locked by the transfer while the higher
+	// one is still held here.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		probe, err := store.BeginTx(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		_, err = store.GetAccountForUpdate(probeCtx, probe, low)
+		cancel()
+		probe.Rollback()
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("transfer never locked the lower account ID")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	blocker.Rollback()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	wantBalances(t, store, ids, 1100, 900)
+}
+
+// Opposing transfers between the same two accounts never deadlock, so none
+// of them needs a retry.
+func TestOpposingTransfersDoNotDeadlock(t *testing.T) {
+	store := memstore.New()
+	ids := createAccounts(t, store, 10_000, 10_000)
+
+	svc, obs := newService(store, billing.Pessimistic)
+	svc.SetRetryPolicy(billing.RetryPolicy{MaxAttempts: 1})
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		from, to := ids[0], ids[1]
+		if i%2 == 1 {
+			from, to = to, from
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := billing.TransferRequest{RequestID: fmt.Sprintf("opposing-%d", i), FromID: from, ToID: to, Amount: 10}
+			if err := svc.Transfer(context.Background(), req); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := obs.Retries(); n != 0 {
+		t.Errorf("%d retries, want none", n)
+	}
+	wantBalances(t, store, ids, 10_000, 10_000)
+}
+
+// A transfer picked as a deadlock victim is rolled back and retried, and
+// applies exactly once.
+func TestTransferRetriesAfterDeadlock(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	ids := createAccounts(t, store, 1000, 1000)
+	low, high := ids[0], ids[1]
+
+	svc, obs := newService(store, billing.Pessimistic)
+
+	// The other transaction holds the higher account, then waits for the
+	// request ID the transfer has just locked. When the transfer asks for
+	// the higher account it closes the cycle and is rolled back.
+	other, err := store.BeginTx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetAccountForUpdate(ctx, other, high); err != nil {
+		t.Fatal(err)
+	}
+
+	otherDone := make(chan struct{})
+	var once sync.Once
+	obs.hook = func(step string) {
+		if step != billing.StepRequestLock {
+			return
+		}
+		once.Do(func() {
+			go func() {
+				defer close(otherDone)
+				store.FindTransactionByRequestID(ctx, other, "deadlocked")
+				other.Rollback()
+			}()
+			time.Sleep(50 * time.Millisecond) // let it start waiting
+		})
+	}
+
+	err = svc.Transfer(ctx, billing.TransferRequest{RequestID: "deadlocked", FromID: low, ToID: high, Amount: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-otherDone
+
+	if n := obs.Retries(); n != 1 {
+		t.Errorf("%d retries, want 1", n)
+	}
+	wantBalances(t, store, ids, 900, 1100)
+
+	txn, err := store.GetTransactionByRequestID(ctx, "deadlocked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txn.Status != billing.StatusSuccess {
+		t.Errorf("status = %s, want SUCCESS", txn.Status)
+	}
+
+	logs, err := store.GetRecentAuditLogs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var retried bool
+	for _, l := range logs {
+		retried = retried || (l.RequestID == "deadlocked" && l.Action == "TRANSFER_RETRY")
+	}
+	if !retried {
+		t.Error("no TRANSFER_RETRY audit entry")
+	}
+}
+
+// In optimistic mode a balance changed between the read and the update is
+// a version conflict: the attempt is discarded and retried on the new
+// balance, so neither transfer is lost.
+func TestOptimisticTransferRetriesOnVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	ids := createAccounts(t, store, 1000, 0, 0)
+
+	svc, obs := newService(store, billing.Optimistic)
+	other, _ := newService(store, billing.Optimistic)
+
+	var once sync.Once
+	obs.hook = func(step string) {
+		if step != billing.StepRead {
+			return
+		}
+		once.Do(func() {
+			// Commits between the first attempt's read and its update.
+			if err := other.Transfer(ctx, billing.TransferRequest{RequestID: "interloper", FromID: ids[0], ToID: ids[2], Amount: 300}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	if err := svc.Transfer(ctx, billing.TransferRequest{RequestID: "optimistic", FromID: ids[0], ToID: ids[1], Amount: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := obs.Retries(); n != 1 {
+		t.Errorf("%d retries, want 1", n)
+	}
+	wantBalances(t, store, ids, 600, 100, 300)
+
+	txn, err := store.GetTransactionByRequestID(ctx, "optimistic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txn.FromBalance != 700 {
+		t.Errorf("recorded sender balance = %d, want 700 (read after the conflict)", txn.FromBalance)
+	}
+}
+
+// A stale read that shows too little money is confirmed at its versions
+// before the transfer is failed, so a deposit that raced it is not missed.
+func TestOptimisticShortfallIsConfirmed(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.New()
+	ids := createAccounts(t, store, 50, 1000, 0)
+
+	svc, obs := newService(store, billing.Optimistic)
+	other, _ := newService(store, billing.Optimistic)
+
+	var once sync.Once
+	obs.hook = func(step string) {
+		if step != billing.StepRead {
+			return
+		}
+		once.Do(func() {
+			if err := other.Transfer(ctx, billing.TransferRequest{RequestID: "deposit", FromID: ids[1], ToID: ids[0], Amount: 500}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	if err := svc.Transfer(ctx, billing.TransferRequest{RequestID: "after-deposit", FromID: ids[0], ToID: ids[2], Amount: 100}); err != nil {
+		t.Fatalf("transfer failed despite the deposit: %v", err)
+	}
+	if n := obs.Retries(); n != 1 {
+		t.Errorf("%d retries, want 1", n)
+	}
+	wantBalances(t, store, ids, 450, 500, 100)
+}
@@ -0,0 +1,282 @@
+// Package config loads the settings shared by the server, admin and bench
+// binaries. Values come from, in increasing precedence: built-in defaults,
+// a YAML file (--config or GOPHERPAY_CONFIG), environment variables (also
+// read from .env) and command-line flags.
+//
+// Each field's `yaml` tag is its key in the file and, joined with dots, its
+// flag name (e.g. --pool.workers) unless a `flag` tag overrides it; `env`
+// names its environment variable. Fields tagged `secret` are redacted by
+// Redacted.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Storage  StorageConfig  `yaml:"storage"`
+	Database DatabaseConfig `yaml:"database"`
+	Server   ServerConfig   `yaml:"server"`
+	Queue    QueueConfig    `yaml:"queue"`
+	Pool     PoolConfig     `yaml:"pool"`
+	Transfer TransferConfig `yaml:"transfer"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Health   HealthConfig   `yaml:"health"`
+}
+
+// StorageConfig picks where accounts and transactions live: "mysql" or
+// "postgres", both reached with DatabaseConfig, "sqlite", a local file at
+// Path, or "memory", which needs no database. Memory storage, and a new
+// SQLite file, start with SeedAccounts accounts holding SeedBalance paise
+// each; memory loses everything on exit. The operator API key seeded with
+// them is written to KeyFile rather than logged.
+type StorageConfig struct {
+	Backend      string `yaml:"backend" env:"STORAGE" flag:"storage"`
+	Path         string `yaml:"path" env:"STORAGE_PATH"`
+	SeedAccounts int    `yaml:"seed_accounts" env:"STORAGE_SEED_ACCOUNTS"`
+	SeedBalance  int64  `yaml:"seed_balance" env:"STORAGE_SEED_BALANCE"`
+	KeyFile      string `yaml:"key_file" env:"STORAGE_KEY_FILE"`
+}
+
+// OperatorKeyFile is where a seeded operator API key is written:
+// storage.key_file, or by default next to the SQLite file or, for memory
+// storage, in the temporary directory.
+func (c StorageConfig) OperatorKeyFile() string {
+	if c.KeyFile != "" {
+		return c.KeyFile
+	}
+	if c.Backend == "sqlite" {
+		return c.Path + ".operator-key"
+	}
+	return filepath.Join(os.TempDir(), "gopherpay-operator-key")
+}
+
+// UsesDatabase reports whether the backend is a database server described
+// by DatabaseConfig.
+func (c StorageConfig) UsesDatabase() bool {
+	return c.Backend == "mysql" || c.Backend == "postgres"
+}
+
+type DatabaseConfig struct {
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" env:"DB_PORT"` // 0 for the backend's default
+	Name     string `yaml:"name" env:"DB_NAME"`
+
+	// TLS is "false", "true", "skip-verify" or "preferred". With TLSCA
+	// set, the server certificate is verified against that CA instead of
+	// the system pool. Postgres maps them onto sslmode disable,
+	// verify-full, require and prefer.
+	TLS   string `yaml:"tls" env:"DB_TLS"`
+	TLSCA string `yaml:"tls_ca" env:"DB_TLS_CA"`
+
+	DialTimeout  time.Duration `yaml:"dial_timeout" env:"DB_DIAL_TIMEOUT"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"DB_READ_TIMEOUT"`   // MySQL only
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"DB_WRITE_TIMEOUT"` // MySQL only
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+
+	// Replicas are read-only copies of the database, each host:port,
+	// reached with the settings above. Read endpoints and reports use a
+	// replica that answered its last check within ReplicaMaxLag (0: lag
+	// is not checked) and fall back to the primary when none did.
+	Replicas             []string      `yaml:"replicas" env:"DB_REPLICAS"`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"DB_REPLICA_CHECK_INTERVAL"`
+	ReplicaMaxLag        time.Duration `yaml:"replica_max_lag" env:"DB_REPLICA_MAX_LAG"`
+}
+
+type ServerConfig struct {
+	HTTPAddr          string        `yaml:"http_addr" env:"HTTP_ADDR"`
+	GRPCAddr          string        `yaml:"grpc_addr" env:"GRPC_ADDR"`
+	AutoMigrate       bool          `yaml:"auto_migrate" env:"AUTO_MIGRATE" flag:"auto-migrate"`
+	ValidateResponses bool          `yaml:"validate_responses" env:"OPENAPI_VALIDATE_RESPONSES"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	ShutdownGrace     time.Duration `yaml:"shutdown_grace" env:"SHUTDOWN_GRACE_SECONDS"`
+}
+
+type QueueConfig struct {
+	Backend      string        `yaml:"backend" env:"QUEUE_BACKEND"`
+	Capacity     int           `yaml:"capacity" env:"QUEUE_CAPACITY"`
+	CapacityHigh int           `yaml:"capacity_high" env:"QUEUE_CAPACITY_HIGH"`
+	CapacityLow  int           `yaml:"capacity_low" env:"QUEUE_CAPACITY_LOW"`
+	Lease        time.Duration `yaml:"lease" env:"QUEUE_LEASE"`
+}
+
+type PoolConfig struct {
+	Workers      int           `yaml:"workers" env:"POOL_WORKERS"`
+	MinWorkers   int           `yaml:"min_workers" env:"POOL_MIN_WORKERS"`
+	MaxWorkers   int           `yaml:"max_workers" env:"POOL_MAX_WORKERS"` // 0 disables autoscaling
+	Dispatch     string        `yaml:"dispatch" env:"WORKER_DISPATCH"`
+	DrainTimeout time.Duration `yaml:"drain_timeout" env:"POOL_DRAIN_SECONDS"`
+}
+
+type TransferConfig struct {
+	MaxWait time.Duration `yaml:"max_wait" env:"TRANSFER_MAX_WAIT_SECONDS"`
+
+	// Concurrency is pessimistic (lock both accounts) or optimistic
+	// (version-checked updates, retried on conflict). MaxAttempts bounds
+	// the retries of either after a transient error or conflict.
+	Concurrency string `yaml:"concurrency" env:"TRANSFER_CONCURRENCY"`
+	MaxAttempts int    `yaml:"max_attempts" env:"TRANSFER_MAX_ATTEMPTS"`
+}
+
+type AuthConfig struct {
+	JWKS        string        `yaml:"jwks" env:"JWT_JWKS"`
+	Audience    string        `yaml:"audience" env:"JWT_AUDIENCE"`
+	Issuer      string        `yaml:"issuer" env:"JWT_ISSUER"`
+	JWKSRefresh time.Duration `yaml:"jwks_refresh" env:"JWT_JWKS_REFRESH"`
+}
+
+type HealthConfig struct {
+	AuditMaxInFlight int `yaml:"audit_max_in_flight" env:"AUDIT_MAX_IN_FLIGHT"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() Config {
+	return Config{
+		Storage: StorageConfig{
+			Backend:      "sqlite",
+			Path:         "gopherpay.db",
+			SeedAccounts: 5,
+			SeedBalance:  1000000,
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Name:            "gopherpay",
+			TLS:             "false",
+			DialTimeout:     5 * time.Second,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			MaxOpenConns:    50,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+
+			ReplicaCheckInterval: 5 * time.Second,
+			ReplicaMaxLag:        10 * time.Second,
+		},
+		Server: ServerConfig{
+			HTTPAddr:        ":8080",
+			GRPCAddr:        ":9090",
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Queue: QueueConfig{
+			Backend:      "database",
+			Capacity:     100,
+			CapacityHigh: 50,
+			CapacityLow:  200,
+			Lease:        5 * time.Minute,
+		},
+		Pool: PoolConfig{
+			Workers:      10,
+			MinWorkers:   1,
+			Dispatch:     "shared",
+			DrainTimeout: 30 * time.Second,
+		},
+		Transfer: TransferConfig{
+			MaxWait:     time.Minute,
+			Concurrency: "pessimistic",
+			MaxAttempts: 4,
+		},
+		Auth: AuthConfig{
+			JWKSRefresh: 10 * time.Minute,
+		},
+		Health: HealthConfig{
+			AuditMaxInFlight: 50,
+		},
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(oneOf(c.Storage.Backend, "mysql", "postgres", "sqlite", "memory"),
+		"storage.backend must be mysql, postgres, sqlite or memory, got %q", c.Storage.Backend)
+	check(c.Storage.Path != "" || c.Storage.Backend != "sqlite", "storage.path is required for sqlite (STORAGE_PATH)")
+	check(c.Storage.SeedAccounts >= 0, "storage.seed_accounts must not be negative")
+	check(c.Storage.SeedBalance >= 0, "storage.seed_balance must not be negative")
+
+	db := c.Database
+	check(db.User != "" || !c.Storage.UsesDatabase(), "database.user is required (DB_USER)")
+	check(db.Host != "", "database.host is required (DB_HOST)")
+	check(db.Port >= 0 && db.Port < 65536, "database.port must be between 0 and 65535, got %d", db.Port)
+	check(db.Name != "", "database.name is required (DB_NAME)")
+	check(oneOf(db.TLS, "false", "true", "skip-verify", "preferred"),
+		"database.tls must be false, true, skip-verify or preferred, got %q", db.TLS)
+	check(db.TLSCA == "" || db.TLS == "true",
+		"database.tls_ca requires database.tls to be true")
+	check(db.DialTimeout >= 0 && db.ReadTimeout >= 0 && db.WriteTimeout >= 0,
+		"database timeouts must not be negative")
+	check(db.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(db.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(db.MaxOpenConns == 0 || db.MaxIdleConns <= db.MaxOpenConns,
+		"database.max_idle_conns (%d) must not exceed database.max_open_conns (%d)", db.MaxIdleConns, db.MaxOpenConns)
+	check(len(db.Replicas) == 0 || c.Storage.UsesDatabase(),
+		"database.replicas needs storage.backend mysql or postgres, got %q", c.Storage.Backend)
+	for _, addr := range db.Replicas {
+		check(validAddr(addr) && !strings.HasPrefix(addr, ":"), "database.replicas entries must be host:port, got %q", addr)
+	}
+	check(db.ReplicaCheckInterval > 0, "database.replica_check_interval must be positive")
+	check(db.ReplicaMaxLag >= 0, "database.replica_max_lag must not be negative")
+
+	check(validAddr(c.Server.HTTPAddr), "server.http_addr must be host:port, got %q", c.Server.HTTPAddr)
+	check(validAddr(c.Server.GRPCAddr), "server.grpc_addr must be host:port, got %q", c.Server.GRPCAddr)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.ShutdownGrace >= 0, "server.shutdown_grace must not be negative")
+
+	// mysql is the name the database queue had while it was MySQL only.
+	check(oneOf(c.Queue.Backend, "database", "mysql", "memory"), "queue.backend must be database or memory, got %q", c.Queue.Backend)
+	check(c.Queue.Capacity > 0, "queue.capacity must be positive")
+	check(c.Queue.CapacityHigh > 0, "queue.capacity_high must be positive")
+	check(c.Queue.CapacityLow > 0, "queue.capacity_low must be positive")
+	check(c.Queue.Lease > 0, "queue.lease must be positive")
+
+	check(c.Pool.Workers > 0, "pool.workers must be positive")
+	check(oneOf(c.Pool.Dispatch, "shared", "account"), "pool.dispatch must be shared or account, got %q", c.Pool.Dispatch)
+	check(c.Pool.DrainTimeout > 0, "pool.drain_timeout must be positive")
+	if c.Pool.MaxWorkers > 0 {
+		check(c.Pool.MinWorkers > 0 && c.Pool.MinWorkers <= c.Pool.MaxWorkers,
+			"pool.min_workers must be between 1 and pool.max_workers (%d), got %d", c.Pool.MaxWorkers, c.Pool.MinWorkers)
+		check(c.Pool.Dispatch == "shared", "autoscaling (pool.max_workers) needs pool.dispatch shared")
+	}
+
+	check(c.Transfer.MaxWait > 0, "transfer.max_wait must be positive")
+	check(oneOf(c.Transfer.Concurrency, "pessimistic", "optimistic"),
+		"transfer.concurrency must be pessimistic or optimistic, got %q", c.Transfer.Concurrency)
+	check(c.Transfer.MaxAttempts > 0, "transfer.max_attempts must be positive")
+	check(c.Auth.JWKSRefresh > 0, "auth.jwks_refresh must be positive")
+	check(c.Auth.JWKS == "" || c.Auth.Audience != "", "auth.audience is required when auth.jwks is set")
+	check(c.Health.AuditMaxInFlight > 0, "health.audit_max_in_flight must be positive")
+
+	return errors.Join(errs...)
+}
+
+func oneOf(s string, options ...string) bool {
+	for _, o := range options {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
+
+func validAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && strings.TrimSpace(port) != ""
+}
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRequiresAudienceWithJWKS(t *testing.T) {
+	c := Default()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+
+	c.Auth.JWKS = "https://auth.example.com/.well-known/jwks.json"
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "auth.audience") {
+		t.Fatalf("err = %v, want auth.audience to be required", err)
+	}
+
+	c.Auth.Audience = "gopherpay"
+	if err := c.Validate(); err != nil {
+		t.Fatalf("jwks with audience: %v", err)
+	}
+}
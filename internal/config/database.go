@@ -0,0 +1,240 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"gopherpay/internal/replica"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" driver
+	_ "modernc.org/sqlite"             // registers the "sqlite" driver
+)
+
+// Default ports, used when database.port is 0.
+const (
+	mysqlPort    = 3306
+	postgresPort = 5432
+)
+
+// lockWaitTimeout bounds Postgres row lock waits and SQLite write lock
+// waits the way MySQL's default innodb_lock_wait_timeout does, so a stuck
+// transfer fails with a retryable error rather than waiting forever.
+const lockWaitTimeout = 50 * time.Second
+
+// tlsConfigName prefixes the names the custom CA configurations are
+// registered under with the MySQL driver, one per host since each carries
+// the host's name to verify.
+const tlsConfigName = "gopherpay"
+
+// DSN returns the go-sql-driver/mysql data source name for c. Times are
+// always parsed into time.Time, which the repositories rely on.
+func (c DatabaseConfig) DSN() (string, error) {
+	m := mysql.NewConfig()
+	m.User = c.User
+	m.Passwd = c.Password
+	m.Net = "tcp"
+	m.Addr = c.addr(mysqlPort)
+	m.DBName = c.Name
+	m.ParseTime = true
+	m.Timeout = c.DialTimeout
+	m.ReadTimeout = c.ReadTimeout
+	m.WriteTimeout = c.WriteTimeout
+
+	if c.TLSCA != "" {
+		pem, err := os.ReadFile(c.TLSCA)
+		if err != nil {
+			return "", fmt.Errorf("failed to read database CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return "", fmt.Errorf("no certificates found in %s", c.TLSCA)
+		}
+		name := tlsConfigName + "-" + c.Host
+		err = mysql.RegisterTLSConfig(name, &tls.Config{
+			RootCAs:    pool,
+			ServerName: c.Host,
+			MinVersion: tls.VersionTLS12,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to register database TLS config: %w", err)
+		}
+		m.TLSConfig = name
+	} else {
+		m.TLSConfig = c.TLS
+	}
+
+	return m.FormatDSN(), nil
+}
+
+// PostgresDSN returns the pgx connection URL for c.
+func (c DatabaseConfig) PostgresDSN() (string, error) {
+	q := url.Values{}
+	q.Set("connect_timeout", strconv.Itoa(int(c.DialTimeout.Seconds())))
+	q.Set("lock_timeout", strconv.Itoa(int(lockWaitTimeout.Milliseconds())))
+
+	switch c.TLS {
+	case "true":
+		q.Set("sslmode", "verify-full")
+		if c.TLSCA != "" {
+			q.Set("sslrootcert", c.TLSCA)
+		}
+	case "skip-verify":
+		q.Set("sslmode", "require")
+	case "preferred":
+		q.Set("sslmode", "prefer")
+	default:
+		q.Set("sslmode", "disable")
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     c.addr(postgresPort),
+		Path:     "/" + c.Name,
+		RawQuery: q.Encode(),
+	}
+	return u.String(), nil
+}
+
+func (c DatabaseConfig) addr(defaultPort int) string {
+	port := c.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// ConnectDB opens the connection pool described by c for backend ("mysql"
+// or "postgres") and checks that the database is reachable.
+func ConnectDB(backend string, c DatabaseConfig) (*sql.DB, error) {
+	db, addr, err := openPool(backend, c)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.DialTimeout+5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to reach %s database at %s: %w", backend, addr, err)
+	}
+	return db, nil
+}
+
+// OpenReplica opens a pool to the read replica at addr (host:port), with
+// every other setting taken from c. Unlike ConnectDB it does not contact
+// the replica: one that is down at startup is left to the replica health
+// checks rather than stopping the process.
+func OpenReplica(backend string, c DatabaseConfig, addr string) (*sql.DB, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replica address %q: %w", addr, err)
+	}
+	c.Host = host
+	if c.Port, err = strconv.Atoi(port); err != nil {
+		return nil, fmt.Errorf("invalid replica port in %q", addr)
+	}
+
+	db, _, err := openPool(backend, c)
+	return db, err
+}
+
+// OpenReplicas returns primary with a pool for each of c.Replicas,
+// checked for lag against c.ReplicaMaxLag. Start the health checks with
+// Set.Run, or a single round with Set.Check.
+func OpenReplicas(backend string, c DatabaseConfig, primary *sql.DB) (*replica.Set, error) {
+	set := replica.New(primary)
+	for _, addr := range c.Replicas {
+		db, err := OpenReplica(backend, c, addr)
+		if err != nil {
+			set.Close()
+			return nil, err
+		}
+		set.Add(addr, db)
+	}
+
+	if c.ReplicaMaxLag > 0 {
+		lag := replica.MySQLLag
+		if backend == "postgres" {
+			lag = replica.PostgresLag
+		}
+		set.SetLagCheck(lag, c.ReplicaMaxLag)
+	}
+	return set, nil
+}
+
+func openPool(backend string, c DatabaseConfig) (*sql.DB, string, error) {
+	var driver, dsn, addr string
+	var err error
+	switch backend {
+	case "mysql":
+		driver, addr = "mysql", c.addr(mysqlPort)
+		dsn, err = c.DSN()
+	case "postgres":
+		driver, addr = "pgx", c.addr(postgresPort)
+		dsn, err = c.PostgresDSN()
+	default:
+		return nil, "", fmt.Errorf("storage backend %q has no database", backend)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	return db, addr, nil
+}
+
+// OpenDB opens the database of the configured storage backend: the
+// SQLite file or, for mysql and postgres, the server in c.Database.
+func (c Config) OpenDB() (*sql.DB, error) {
+	if c.Storage.Backend == "sqlite" {
+		return OpenSQLite(c.Storage)
+	}
+	return ConnectDB(c.Storage.Backend, c.Database)
+}
+
+// OpenSQLite opens the SQLite database at c.Path, creating the file if it
+// does not exist.
+//
+// The connection runs in WAL mode, so reads proceed while a transfer
+// writes, with foreign keys enforced. Transactions begin with BEGIN
+// IMMEDIATE: SQLite has no row locks, so each transaction takes the
+// database write lock up front, which serialises transfers the way
+// SELECT ... FOR UPDATE would and avoids the deadlock two deferred
+// transactions hit when both try to upgrade a read lock. A writer waits
+// up to lockWaitTimeout for the lock before failing with SQLITE_BUSY.
+func OpenSQLite(c StorageConfig) (*sql.DB, error) {
+	q := url.Values{}
+	q.Add("_pragma", "journal_mode(WAL)")
+	q.Add("_pragma", "busy_timeout("+strconv.Itoa(int(lockWaitTimeout.Milliseconds()))+")")
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Set("_txlock", "immediate")
+	q.Set("_time_format", "sqlite")
+
+	db, err := sql.Open("sqlite", "file:"+c.Path+"?"+q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database %s: %w", c.Path, err)
+	}
+	return db, nil
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the effective configuration from the defaults, the config
+// file, the environment and args, then validates it. args are flags only,
+// e.g. os.Args[1:] for the server.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+
+	// A missing .env is fine; variables may come from the real environment.
+	_ = godotenv.Load()
+
+	flags, configPath, err := parseFlags(&cfg, args)
+	if err != nil {
+		return cfg, err
+	}
+
+	if configPath == "" {
+		configPath = os.Getenv("GOPHERPAY_CONFIG")
+	}
+	if configPath != "" {
+		if err := loadFile(&cfg, configPath); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	for _, set := range flags {
+		if err := set(); err != nil {
+			return cfg, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// setting is one leaf field of Config.
+type setting struct {
+	path   string // dotted yaml keys, e.g. pool.workers
+	env    string
+	flag   string
+	secret bool
+	value  reflect.Value
+}
+
+func settings(cfg *Config) []setting {
+	var out []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			path := prefix + f.Tag.Get("yaml")
+			if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(v.Field(i), path+".")
+				continue
+			}
+
+			s := setting{
+				path:   path,
+				env:    f.Tag.Get("env"),
+				flag:   f.Tag.Get("flag"),
+				secret: f.Tag.Get("secret") == "true",
+				value:  v.Field(i),
+			}
+			if s.flag == "" {
+				s.flag = path
+			}
+			out = append(out, s)
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+func applyEnv(cfg *Config) error {
+	var errs []error
+	for _, s := range settings(cfg) {
+		raw, ok := os.LookupEnv(s.env)
+		if s.env == "" || !ok || raw == "" {
+			continue
+		}
+		if err := set(s.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// parseFlags registers a flag per setting and parses args. Values are
+// applied by the returned funcs so that flags win over the file and
+// environment, which are only read afterwards.
+func parseFlags(cfg *Config, args []string) ([]func() error, string, error) {
+	fs := flag.NewFlagSet("gopherpay", flag.ContinueOnError)
+	configPath := fs.String("config", "", "YAML config file (env GOPHERPAY_CONFIG)")
+
+	var pending []func() error
+	for _, s := range settings(cfg) {
+		usage := s.path
+		if s.env != "" {
+			usage += " (env " + s.env + ")"
+		}
+		record := func(raw string) error {
+			pending = append(pending, func() error {
+				if err := set(s.value, raw); err != nil {
+					return fmt.Errorf("--%s: %w", s.flag, err)
+				}
+				return nil
+			})
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			fs.BoolFunc(s.flag, usage, record)
+		} else {
+			fs.Func(s.flag, usage, record)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+	return pending, *configPath, nil
+}
+
+// set parses raw into v. Durations accept Go syntax ("1m30s") or a bare
+// number of seconds, which keeps the older *_SECONDS variables working.
+// Lists are comma separated.
+func set(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		if secs, err := strconv.Atoi(raw); err == nil {
+			v.SetInt(int64(time.Duration(secs) * time.Second))
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported setting type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// Redacted returns a copy of c with every secret that is set replaced by
+// "REDACTED", safe to print or log.
+func (c Config) Redacted() Config {
+	for _, s := range settings(&c) {
+		if s.secret && s.value.String() != "" {
+			s.value.SetString("REDACTED")
+		}
+	}
+	return c
+}
+
+// YAML renders c in the config file format.
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
@@ -0,0 +1,711 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/dberr"
+)
+
+// missingAccount is an account ID no backend will have handed out.
+const missingAccount = uint64(1) << 62
+
+// lockProbe is how long a blocked lock waiter is watched to make sure it
+// really is blocked.
+const lockProbe = 200 * time.Millisecond
+
+func Cases() []Case {
+	return []Case{
+		{"commit makes writes visible", commitVisible},
+		{"rollback discards writes", rollbackDiscards},
+		{"missing account", missingAccountErrors},
+		{"transaction lifecycle", transactionLifecycle},
+		{"duplicate request id", duplicateRequestID},
+		{"foreign tx rejected", foreignTx},
+		{"account class", accountClass},
+		{"audit round trip", auditRoundTrip},
+		{"row lock blocks until commit", rowLockBlocks},
+		{"concurrent find-or-insert", concurrentFindOrInsert},
+		{"version-checked update", versionCheckedUpdate},
+		{"postings and past balances", postingsAndPastBalances},
+		{"concurrent transfers conserve money", concurrentTransfers(billing.Pessimistic)},
+		{"concurrent optimistic transfers conserve money", concurrentTransfers(billing.Optimistic)},
+	}
+}
+
+func commitVisible(ctx context.Context, t *T) error {
+	id, err := t.CreateAccount(ctx, 1000)
+	if err != nil {
+		return err
+	}
+
+	tx, err := t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := t.GetAccountForUpdate(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := t.UpdateAccountBalance(ctx, tx, id, 700); err != nil {
+		return err
+	}
+	if bal, err := t.GetAccountBalance(ctx, tx, id); err != nil || bal != 700 {
+		return fmt.Errorf("balance inside tx = %d, %v; want 700", bal, err)
+	}
+	if err := wantBalance(ctx, t, id, 1000); err != nil {
+		return fmt.Errorf("uncommitted write leaked: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return wantBalance(ctx, t, id, 700)
+}
+
+func rollbackDiscards(ctx context.Context, t *T) error {
+	id, err := t.CreateAccount(ctx, 1000)
+	if err != nil {
+		return err
+	}
+	requestID := t.RequestID("rollback")
+
+	tx, err := t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	if err := t.UpdateAccountBalance(ctx, tx, id, 1); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := t.InsertTransaction(ctx, tx, pending(requestID, id, id)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+
+	if err := wantBalance(ctx, t, id, 1000); err != nil {
+		return err
+	}
+	if _, err := t.GetTransactionByRequestID(ctx, requestID); !errors.Is(err, billing.ErrTransactionNotFound) {
+		return fmt.Errorf("rolled back transaction: got %v, want ErrTransactionNotFound", err)
+	}
+	return nil
+}
+
+func missingAccountErrors(ctx context.Context, t *T) error {
+	if _, err := t.GetAccount(ctx, missingAccount); !errors.Is(err, billing.ErrAccountNotFound) {
+		return fmt.Errorf("GetAccount: got %v, want ErrAccountNotFound", err)
+	}
+	if _, err := t.GetAccountClass(ctx, missingAccount); !errors.Is(err, billing.ErrAccountNotFound) {
+		return fmt.Errorf("GetAccountClass: got %v, want ErrAccountNotFound", err)
+	}
+	if owned, err := t.IsAccountOwnedBy(ctx, missingAccount, 1); err != nil || owned {
+		return fmt.Errorf("IsAccountOwnedBy = %v, %v; want false, nil", owned, err)
+	}
+
+	tx, err := t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := t.GetAccountForUpdate(ctx, tx, missingAccount); !errors.Is(err, billing.ErrAccountNotFound) {
+		return fmt.Errorf("GetAccountForUpdate: got %v, want ErrAccountNotFound", err)
+	}
+	return nil
+}
+
+func transactionLifecycle(ctx context.Context, t *T) error {
+	from, err := t.CreateAccount(ctx, 500)
+	if err != nil {
+		return err
+	}
+	to, err := t.CreateAccount(ctx, 0)
+	if err != nil {
+		return err
+	}
+	requestID := t.RequestID("lifecycle")
+
+	tx, err := t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := t.FindTransactionByRequestID(ctx, tx, requestID); !errors.Is(err, billing.ErrTransactionNotFound) {
+		return fmt.Errorf("before insert: got %v, want ErrTransactionNotFound", err)
+	}
+	txnID, err := t.InsertTransaction(ctx, tx, pending(requestID, from, to))
+	if err != nil {
+		return err
+	}
+	found, err := t.FindTransactionByRequestID(ctx, tx, requestID)
+	if err != nil {
+		return fmt.Errorf("own insert not visible in tx: %w", err)
+	}
+	if found.ID != txnID {
+		return fmt.Errorf("found id %d, inserted %d", found.ID, txnID)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	got, err := t.GetTransactionByRequestID(ctx, requestID)
+	if err != nil {
+		return err
+	}
+	want := pending(requestID, from, to)
+	if got.ID != txnID || got.FromAccountID != from || got.ToAccountID != to ||
+		got.Amount != want.Amount || got.Status != billing.StatusPending ||
+		got.FromBalance != want.FromBalance || got.ToBalance != want.ToBalance {
+		return fmt.Errorf("read back %+v, want %+v with id %d", got, want, txnID)
+	}
+	if got.CreatedAt.IsZero() {
+		return errors.New("created_at not set")
+	}
+
+	msg := "conformance failure"
+	tx, err = t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := t.UpdateTransactionStatus(ctx, tx, txnID, billing.StatusFailed, &msg); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	got, err = t.GetTransactionByRequestID(ctx, requestID)
+	if err != nil {
+		return err
+	}
+	if got.Status != billing.StatusFailed || got.ErrorMessage == nil || *got.ErrorMessage != msg {
+		return fmt.Errorf("after update: status %s, message %v", got.Status, got.ErrorMessage)
+	}
+
+	// A rejected transfer is inserted as FAILED, message and all.
+	rejected := pending(t.RequestID("rejected"), from, to)
+	rejected.Status, rejected.ErrorMessage = billing.StatusFailed, &msg
+	tx, err = t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := t.InsertTransaction(ctx, tx, rejected); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	got, err = t.GetTransactionByRequestID(ctx, rejected.RequestID)
+	if err != nil {
+		return err
+	}
+	if got.Status != billing.StatusFailed || got.ErrorMessage == nil || *got.ErrorMessage != msg {
+		return fmt.Errorf("inserted as failed: status %s, message %v", got.Status, got.ErrorMessage)
+	}
+	return nil
+}
+
+func duplicateRequestID(ctx context.Context, t *T) error {
+	id, err := t.CreateAccount(ctx, 0)
+	if err != nil {
+		return err
+	}
+	requestID := t.RequestID("duplicate")
+
+	for i := 0; i < 2; i++ {
+		tx, err := t.BeginTx(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = t.InsertTransaction(ctx, tx, pending(requestID, id, id))
+		if i == 0 {
+			if err == nil {
+				err = tx.Commit()
+			}
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			continue
+		}
+		tx.Rollback()
+		if !dberr.IsDuplicate(err) {
+			return fmt.Errorf("second insert: got %v (%s), want a duplicate", err, dberr.Classify(err))
+		}
+	}
+	return nil
+}
+
+type otherTx struct{}
+
+func (otherTx) Commit() error   { return nil }
+func (otherTx) Rollback() error { return nil }
+
+func foreignTx(ctx context.Context, t *T) error {
+	id, err := t.CreateAccount(ctx, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := t.GetAccountForUpdate(ctx, otherTx{}, id); !errors.Is(err, billing.ErrForeignTx) {
+		return fmt.Errorf("got %v, want ErrForeignTx", err)
+	}
+	return nil
+}
+
+func accountClass(ctx context.Context, t *T) error {
+	id, err := t.CreateAccount(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	if class, err := t.GetAccountClass(ctx, id); err != nil || class != billing.ClassStandard {
+		return fmt.Errorf("new account class = %q, %v; want standard", class, err)
+	}
+	// Setting the current class again must not look like a missing account
+	for i := 0; i < 2; i++ {
+		if err := t.SetAccountClass(ctx, id, billing.ClassTreasury); err != nil {
+			return err
+		}
+	}
+	if class, err := t.GetAccountClass(ctx, id); err != nil || class != billing.ClassTreasury {
+		return fmt.Errorf("class = %q, %v; want treasury", class, err)
+	}
+	if err := t.SetAccountClass(ctx, missingAccount, billing.ClassPayroll); !errors.Is(err, billing.ErrAccountNotFound) {
+		return fmt.Errorf("missing account: got %v, want ErrAccountNotFound", err)
+	}
+	return nil
+}
+
+func auditRoundTrip(ctx context.Context, t *T) error {
+	msg := "written by the conformance suite"
+	entry := audit.AuditLog{
+		RequestID: t.RequestID("audit"),
+		Subject:   "conformance",
+		Action:    "TRANSFER",
+		Status:    "SUCCESS",
+		Message:   &msg,
+	}
+	if err := t.Log(ctx, &entry); err != nil {
+		return err
+	}
+
+	logs, err := t.GetRecentAuditLogs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, got := range logs {
+		if got.RequestID != entry.RequestID {
+			continue
+		}
+		if got.Subject != entry.Subject || got.Action != entry.Action || got.Status != entry.Status ||
+			got.Message == nil || *got.Message != msg || got.CreatedAt.IsZero() {
+			return fmt.Errorf("read back %+v", got)
+		}
+		return nil
+	}
+	return errors.New("logged entry not among recent audit logs")
+}
+
+func rowLockBlocks(ctx context.Context, t *T) error {
+	id, err := t.CreateAccount(ctx, 1000)
+	if err != nil {
+		return err
+	}
+
+	holder, err := t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer holder.Rollback()
+	if _, err := t.GetAccountForUpdate(ctx, holder, id); err != nil {
+		return err
+	}
+
+	// The waiter may block in BeginTx, as on SQLite, where BEGIN IMMEDIATE
+	// takes the write lock, or in the locking read.
+	type read struct {
+		acc *billing.Account
+		err error
+	}
+	done := make(chan read, 1)
+	go func() {
+		waiter, err := t.BeginTx(ctx)
+		if err != nil {
+			done <- read{nil, err}
+			return
+		}
+		defer waiter.Rollback()
+		acc, err := t.GetAccountForUpdate(ctx, waiter, id)
+		done <- read{acc, err}
+	}()
+
+	select {
+	case r := <-done:
+		return fmt.Errorf("second locker was not blocked: %+v, %v", r.acc, r.err)
+	case <-time.After(lockProbe):
+	}
+
+	if err := t.UpdateAccountBalance(ctx, holder, id, 400); err != nil {
+		return err
+	}
+	if err := holder.Commit(); err != nil {
+		return err
+	}
+
+	r := <-done
+	if r.err != nil {
+		return fmt.Errorf("waiter: %w", r.err)
+	}
+	if r.acc.Balance != 400 {
+		return fmt.Errorf("waiter read balance %d after commit, want 400", r.acc.Balance)
+	}
+	return nil
+}
+
+// concurrentFindOrInsert races two deliveries of each request the way
+// Service.Transfer records them. Backends may block the second delivery
+// or fail one with a transient error to be retried, but never with a
+// duplicate, and exactly one row must result.
+func concurrentFindOrInsert(ctx context.Context, t *T) error {
+	id, err := t.CreateAccount(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	const requests = 10
+	errs := make(chan error, 2*requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		requestID := t.RequestID("race")
+		for j := 0; j < 2; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- findOrInsert(ctx, t, pending(requestID, id, id))
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findOrInsert(ctx context.Context, t *T, txn *billing.Transaction) error {
+	for attempt := 1; ; attempt++ {
+		err := func() error {
+			tx, err := t.BeginTx(ctx)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+
+			_, err = t.FindTransactionByRequestID(ctx, tx, txn.RequestID)
+			if !errors.Is(err, billing.ErrTransactionNotFound) {
+				return err
+			}
+			if _, err := t.InsertTransaction(ctx, tx, txn); err != nil {
+				return err
+			}
+			return tx.Commit()
+		}()
+		if err == nil || !dberr.IsTransient(err) || attempt == 5 {
+			if err != nil {
+				return fmt.Errorf("%s attempt %d: %w (%s)", txn.RequestID, attempt, err, dberr.Classify(err))
+			}
+			return nil
+		}
+	}
+}
+
+// concurrentTransfers runs transfers in both directions between a handful
+// of accounts through billing.Service and checks every paisa is accounted
+// for.
+// versionCheckedUpdate checks that every balance update bumps the version
+// and that an update at a version already moved past matches nothing.
+func versionCheckedUpdate(ctx context.Context, t *T) error {
+	id, err := t.CreateAccount(ctx, 1000)
+	if err != nil {
+		return err
+	}
+
+	tx, err := t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	read, err := t.ReadAccount(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if read.Balance != 1000 {
+		return fmt.Errorf("read balance %d, want 1000", read.Balance)
+	}
+	// End it before the next begins: a SQLite transaction holds the
+	// database write lock.
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	tx, err = t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	ok, err := t.UpdateAccountBalanceAtVersion(ctx, tx, id, 900, read.Version)
+	if err != nil || !ok {
+		return fmt.Errorf("update at current version = %v, %v; want true", ok, err)
+	}
+	acc, err := t.ReadAccount(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if acc.Balance != 900 || acc.Version != read.Version+1 {
+		return fmt.Errorf("inside tx: balance %d version %d, want 900 and %d", acc.Balance, acc.Version, read.Version+1)
+	}
+	if ok, err := t.UpdateAccountBalanceAtVersion(ctx, tx, id, 1, read.Version); err != nil || ok {
+		return fmt.Errorf("update at old version inside tx = %v, %v; want false", ok, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	tx, err = t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if ok, err := t.UpdateAccountBalanceAtVersion(ctx, tx, id, 1, read.Version); err != nil || ok {
+		return fmt.Errorf("update at stale version = %v, %v; want false", ok, err)
+	}
+	acc, err = t.GetAccountForUpdate(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err := t.UpdateAccountBalance(ctx, tx, id, 800); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if acc.Version != read.Version+1 {
+		return fmt.Errorf("locked read version %d, want %d", acc.Version, read.Version+1)
+	}
+
+	if err := wantBalance(ctx, t, id, 800); err != nil {
+		return err
+	}
+	tx, err = t.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	acc, err = t.ReadAccount(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if acc.Version != read.Version+2 {
+		return fmt.Errorf("unconditional update left version %d, want %d", acc.Version, read.Version+2)
+	}
+	return nil
+}
+
+// concurrentTransfers runs overlapping transfers between a few accounts. In
+// optimistic mode most of them conflict, so they get retries to spare.
+func concurrentTransfers(mode billing.Concurrency) func(ctx context.Context, t *T) error {
+	return func(ctx context.Context, t *T) error {
+		return runConcurrentTransfers(ctx, t, mode)
+	}
+}
+
+func runConcurrentTransfers(ctx context.Context, t *T, mode billing.Concurrency) error {
+	const (
+		accounts  = 4
+		opening   = 1_000_000
+		transfers = 200
+		workers   = 16
+	)
+
+	ids := make([]uint64, accounts)
+	for i := range ids {
+		id, err := t.CreateAccount(ctx, opening)
+		if err != nil {
+			return err
+		}
+		ids[i] = id
+	}
+
+	service := billing.NewService(t.Backend, t.Backend, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	service.SetConcurrency(mode)
+	if mode == billing.Optimistic {
+		service.SetRetryPolicy(billing.RetryPolicy{MaxAttempts: 100, BaseDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond})
+	}
+
+	jobs := make(chan billing.TransferRequest)
+	errs := make(chan error, transfers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range jobs {
+				errs <- service.Transfer(ctx, req)
+			}
+		}()
+	}
+
+	want := make(map[uint64]int64, accounts)
+	for _, id := range ids {
+		want[id] = opening
+	}
+	for i := 0; i < transfers; i++ {
+		from, to := ids[i%accounts], ids[(i+1+i/accounts)%accounts]
+		if from == to {
+			to = ids[(i+2)%accounts]
+		}
+		amount := int64(1 + i%97)
+		want[from] -= amount
+		want[to] += amount
+		jobs <- billing.TransferRequest{
+			RequestID: t.RequestID("transfer"),
+			Subject:   "conformance",
+			FromID:    from,
+			ToID:      to,
+			Amount:    amount,
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return fmt.Errorf("transfer failed: %w", err)
+		}
+	}
+	for _, id := range ids {
+		if err := wantBalance(ctx, t, id, want[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postingsAndPastBalances checks the reads statements are built from: only
+// SUCCESS transfers are posted, in order, and a past balance backs out the
+// transfers posted since.
+func postingsAndPastBalances(ctx context.Context, t *T) error {
+	a, err := t.CreateAccount(ctx, 1000)
+	if err != nil {
+		return err
+	}
+	b, err := t.CreateAccount(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	service := billing.NewService(t.Backend, t.Backend, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	transfer := func(from, to uint64, amount int64) error {
+		return service.Transfer(ctx, billing.TransferRequest{
+			RequestID: t.RequestID("posting"),
+			Subject:   "conformance",
+			FromID:    from,
+			ToID:      to,
+			Amount:    amount,
+		})
+	}
+	for _, tr := range []struct {
+		from, to uint64
+		amount   int64
+	}{{a, b, 100}, {b, a, 30}, {a, b, 200}} {
+		if err := transfer(tr.from, tr.to, tr.amount); err != nil {
+			return err
+		}
+	}
+	if err := transfer(a, b, 1_000_000); !errors.Is(err, billing.ErrInsufficientFunds) {
+		return fmt.Errorf("overdraft: got %v, want ErrInsufficientFunds", err)
+	}
+
+	epoch, later := time.Unix(0, 0).UTC(), time.Now().Add(time.Hour)
+
+	var amounts []int64
+	var lastID uint64
+	err = t.ForEachPosting(ctx, a, epoch, later, func(txn billing.Transaction) error {
+		if txn.Status != billing.StatusSuccess || txn.ID <= lastID {
+			return fmt.Errorf("posting %d with status %s after %d", txn.ID, txn.Status, lastID)
+		}
+		lastID = txn.ID
+		amounts = append(amounts, txn.Amount)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if fmt.Sprint(amounts) != "[100 30 200]" {
+		return fmt.Errorf("posted amounts %v, want [100 30 200]", amounts)
+	}
+
+	if bal, err := t.BalanceAt(ctx, a, epoch); err != nil || bal != 1000 {
+		return fmt.Errorf("balance at epoch = %d, %v; want 1000", bal, err)
+	}
+	if bal, err := t.BalanceAt(ctx, a, later); err != nil || bal != 730 {
+		return fmt.Errorf("balance after the transfers = %d, %v; want 730", bal, err)
+	}
+
+	err = t.ForEachPosting(ctx, b, later, later.Add(time.Hour), func(txn billing.Transaction) error {
+		return fmt.Errorf("transfer %d posted after it happened", txn.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	stop := errors.New("stop")
+	if err := t.ForEachPosting(ctx, b, epoch, later, func(billing.Transaction) error { return stop }); !errors.Is(err, stop) {
+		return fmt.Errorf("callback error: got %v, want it returned", err)
+	}
+	return nil
+}
+
+func pending(requestID string, from, to uint64) *billing.Transaction {
+	return &billing.Transaction{
+		RequestID:     requestID,
+		FromAccountID: from,
+		ToAccountID:   to,
+		Amount:        100,
+		Status:        billing.StatusPending,
+		FromBalance:   500,
+		ToBalance:     0,
+	}
+}
+
+func wantBalance(ctx context.Context, t *T, id uint64, want int64) error {
+	acc, err := t.GetAccount(ctx, id)
+	if err != nil {
+		return err
+	}
+	if acc.Balance != want {
+		return fmt.Errorf("account %d balance = %d, want %d", id, acc.Balance, want)
+	}
+	return nil
+}
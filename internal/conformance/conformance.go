@@ -0,0 +1,73 @@
+// Package conformance checks that a storage backend behaves the way
+// billing.Service relies on: transactions are atomic and isolated, locking
+// reads block each other until commit, lookups report the billing
+// sentinel errors, and duplicate request IDs are classified by dberr.
+//
+// Every backend (memory, SQLite, MySQL, Postgres) runs the same cases through
+// `admin conformance`. The cases only touch accounts and request IDs they
+// create, but they do write, so point them at a scratch database.
+package conformance
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/billing"
+)
+
+// Backend is a store under test plus the fixture the cases need.
+type Backend interface {
+	billing.Store
+	audit.Repository
+	audit.Reader
+
+	// CreateAccount opens a standard, unowned account.
+	CreateAccount(ctx context.Context, balance int64) (uint64, error)
+}
+
+type Case struct {
+	Name string
+	Run  func(ctx context.Context, t *T) error
+}
+
+// T is what a case runs against. Request IDs from T.RequestID are unique
+// to the run, so cases can share a database with earlier runs.
+type T struct {
+	Backend
+	run string
+	seq int
+}
+
+func (t *T) RequestID(name string) string {
+	t.seq++
+	return fmt.Sprintf("conformance-%s-%s-%d", t.run, name, t.seq)
+}
+
+// Result is the outcome of one case; Err is nil if it passed.
+type Result struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// Run runs every case against b, each with timeout, and returns one result
+// per case in order.
+func Run(ctx context.Context, b Backend, timeout time.Duration) []Result {
+	id := make([]byte, 4)
+	rand.Read(id)
+	t := &T{Backend: b, run: hex.EncodeToString(id)}
+
+	var results []Result
+	for _, c := range Cases() {
+		caseCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err := c.Run(caseCtx, t)
+		cancel()
+		results = append(results, Result{Name: c.Name, Err: err, Duration: time.Since(start)})
+	}
+	return results
+}
@@ -0,0 +1,109 @@
+package conformance_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/config"
+	"gopherpay/internal/conformance"
+	"gopherpay/internal/memstore"
+	"gopherpay/migrations"
+)
+
+// MySQL and Postgres run only when a scratch database is named by these
+// variables. The MySQL DSN needs parseTime=true.
+const (
+	mysqlDSNEnv    = "GOPHERPAY_TEST_MYSQL_DSN"
+	postgresDSNEnv = "GOPHERPAY_TEST_POSTGRES_DSN"
+)
+
+type sqlBackend struct {
+	accountStore
+	audit.Repository
+	audit.Reader
+}
+
+type accountStore interface {
+	billing.Store
+	CreateAccount(ctx context.Context, balance int64) (uint64, error)
+}
+
+func run(t *testing.T, b conformance.Backend) {
+	t.Helper()
+
+	for _, r := range conformance.Run(context.Background(), b, time.Minute) {
+		t.Run(r.Name, func(t *testing.T) {
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+		})
+	}
+}
+
+// migrated applies every migration of backend to db.
+func migrated(t *testing.T, db *sql.DB, backend string) *sql.DB {
+	t.Helper()
+
+	m, err := migrations.NewMigrator(db, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate %s: %v", backend, err)
+	}
+	return db
+}
+
+func openDSN(t *testing.T, driver, env string) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv(env)
+	if dsn == "" {
+		t.Skipf("%s is not set", env)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("failed to connect with %s: %v", env, err)
+	}
+	return db
+}
+
+func TestMemory(t *testing.T) {
+	run(t, memstore.New())
+}
+
+func TestSQLite(t *testing.T) {
+	db, err := config.OpenSQLite(config.StorageConfig{Path: filepath.Join(t.TempDir(), "conformance.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrated(t, db, "sqlite")
+	repo := audit.NewSQLiteRepository(db)
+	run(t, sqlBackend{billing.NewSQLiteRepository(db), repo, repo})
+}
+
+func TestMySQL(t *testing.T) {
+	db := migrated(t, openDSN(t, "mysql", mysqlDSNEnv), "mysql")
+	repo := audit.NewMySQLRepository(db)
+	run(t, sqlBackend{billing.NewMySQLRepository(db), repo, repo})
+}
+
+func TestPostgres(t *testing.T) {
+	db := migrated(t, openDSN(t, "pgx", postgresDSNEnv), "postgres")
+	repo := audit.NewPostgresRepository(db)
+	run(t, sqlBackend{billing.NewPostgresRepository(db), repo, repo})
+}
@@ -0,0 +1,136 @@
+// Package dberr classifies MySQL, Postgres and SQLite driver errors so
+// callers can decide whether an operation is worth retrying.
+package dberr
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// MySQL server error numbers.
+const (
+	mysqlTooManyConns     = 1040
+	mysqlServerShutdown   = 1053
+	mysqlDuplicateEntry   = 1062
+	mysqlTooManyUserConns = 1203
+	mysqlLockWaitTimeout  = 1205
+	mysqlDeadlock         = 1213
+	mysqlQueryInterrupted = 1317
+	mysqlReadOnlyTxn      = 1792 // primary demoted during failover
+	mysqlLockNowait       = 3572
+)
+
+// Postgres SQLSTATE codes.
+const (
+	pgUniqueViolation      = "23505"
+	pgReadOnlyTxn          = "25006"
+	pgSerializationFailure = "40001"
+	pgDeadlock             = "40P01"
+	pgTooManyConns         = "53300"
+	pgLockNotAvailable     = "55P03" // lock_timeout or NOWAIT
+	pgQueryCanceled        = "57014"
+	pgAdminShutdown        = "57P01"
+)
+
+// Errors for backends that have no driver error numbers to classify, such
+// as the in-memory store. Wrap them to get the same treatment as the MySQL
+// errors they stand for.
+var (
+	ErrDeadlock        = errors.New("deadlock found when trying to get lock")
+	ErrLockWaitTimeout = errors.New("lock wait timeout exceeded")
+	ErrDuplicate       = errors.New("duplicate entry")
+)
+
+// ErrConflict means a row changed between an optimistic read and the
+// conditional write that depended on it. Retrying rereads the row.
+var ErrConflict = errors.New("row changed since it was read")
+
+type Class int
+
+const (
+	// Permanent errors will fail again if retried.
+	Permanent Class = iota
+	// Transient errors (deadlocks, lock wait timeouts, dropped connections)
+	// may succeed if the whole transaction is retried.
+	Transient
+	// Duplicate means a unique key already holds the value.
+	Duplicate
+)
+
+func (c Class) String() string {
+	switch c {
+	case Transient:
+		return "transient"
+	case Duplicate:
+		return "duplicate"
+	}
+	return "permanent"
+}
+
+func Classify(err error) Class {
+	if err == nil {
+		return Permanent
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case mysqlDeadlock, mysqlLockWaitTimeout, mysqlLockNowait,
+			mysqlTooManyConns, mysqlTooManyUserConns, mysqlQueryInterrupted,
+			mysqlServerShutdown, mysqlReadOnlyTxn:
+			return Transient
+		case mysqlDuplicateEntry:
+			return Duplicate
+		}
+		return Permanent
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgDeadlock, pgSerializationFailure, pgLockNotAvailable,
+			pgTooManyConns, pgQueryCanceled, pgAdminShutdown, pgReadOnlyTxn:
+			return Transient
+		case pgUniqueViolation:
+			return Duplicate
+		}
+		return Permanent
+	}
+
+	// SQLITE_BUSY is a write lock wait that ran past the busy timeout.
+	// Code is the extended result code; its low byte is the primary one.
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) {
+		switch code := liteErr.Code(); {
+		case code&0xff == sqlite3.SQLITE_BUSY || code&0xff == sqlite3.SQLITE_LOCKED:
+			return Transient
+		case code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return Duplicate
+		}
+		return Permanent
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, ErrDeadlock) || errors.Is(err, ErrLockWaitTimeout) ||
+		errors.Is(err, ErrConflict) {
+		return Transient
+	}
+	if errors.Is(err, ErrDuplicate) {
+		return Duplicate
+	}
+
+	return Permanent
+}
+
+func IsTransient(err error) bool {
+	return Classify(err) == Transient
+}
+
+func IsDuplicate(err error) bool {
+	return Classify(err) == Duplicate
+}
@@ -0,0 +1,86 @@
+// Package events fans transfer outcomes out to in-process subscribers such
+// as gRPC streams.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// TransferEvent reports that a transfer finished processing. Err is nil for
+// successful transfers.
+type TransferEvent struct {
+	RequestID  string
+	Subject    string
+	FromID     uint64
+	ToID       uint64
+	Amount     int64
+	Err        error
+	OccurredAt time.Time
+}
+
+// Broker delivers every published event to every subscriber. Publishing
+// never blocks: a subscriber whose buffer is full misses the event.
+type Broker struct {
+	mu     sync.RWMutex
+	subs   map[int]chan TransferEvent
+	nextID int
+	closed bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[int]chan TransferEvent)}
+}
+
+func (b *Broker) Publish(e TransferEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of future events and a function that
+// unsubscribes and closes the channel. After Close the channel is returned
+// already closed.
+func (b *Broker) Subscribe(buffer int) (<-chan TransferEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan TransferEvent, buffer)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = ch
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[id]; ok {
+			delete(b.subs, id)
+			close(ch)
+		}
+	}
+}
+
+// Close ends every subscription by closing its channel, so subscribers
+// such as open gRPC streams return during shutdown instead of waiting for
+// their clients to go away.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for id, ch := range b.subs {
+		delete(b.subs, id)
+		close(ch)
+	}
+}
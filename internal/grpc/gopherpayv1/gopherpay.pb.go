@@ -0,0 +1,882 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v5.29.3
+// source: gopherpay/v1/gopherpay.proto
+
+package gopherpayv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type TransactionStatus int32
+
+const (
+	TransactionStatus_TRANSACTION_STATUS_UNSPECIFIED TransactionStatus = 0
+	TransactionStatus_TRANSACTION_STATUS_PENDING     TransactionStatus = 1
+	TransactionStatus_TRANSACTION_STATUS_SUCCESS     TransactionStatus = 2
+	TransactionStatus_TRANSACTION_STATUS_FAILED      TransactionStatus = 3
+)
+
+// Enum value maps for TransactionStatus.
+var (
+	TransactionStatus_name = map[int32]string{
+		0: "TRANSACTION_STATUS_UNSPECIFIED",
+		1: "TRANSACTION_STATUS_PENDING",
+		2: "TRANSACTION_STATUS_SUCCESS",
+		3: "TRANSACTION_STATUS_FAILED",
+	}
+	TransactionStatus_value = map[string]int32{
+		"TRANSACTION_STATUS_UNSPECIFIED": 0,
+		"TRANSACTION_STATUS_PENDING":     1,
+		"TRANSACTION_STATUS_SUCCESS":     2,
+		"TRANSACTION_STATUS_FAILED":      3,
+	}
+)
+
+func (x TransactionStatus) Enum() *TransactionStatus {
+	p := new(TransactionStatus)
+	*p = x
+	return p
+}
+
+func (x TransactionStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TransactionStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_gopherpay_v1_gopherpay_proto_enumTypes[0].Descriptor()
+}
+
+func (TransactionStatus) Type() protoreflect.EnumType {
+	return &file_gopherpay_v1_gopherpay_proto_enumTypes[0]
+}
+
+func (x TransactionStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TransactionStatus.Descriptor instead.
+func (TransactionStatus) EnumDescriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{0}
+}
+
+type TransferRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	FromId uint64                 `protobuf:"varint,1,opt,name=from_id,json=fromId,proto3" json:"from_id,omitempty"`
+	ToId   uint64                 `protobuf:"varint,2,opt,name=to_id,json=toId,proto3" json:"to_id,omitempty"`
+	// Amount in paise.
+	Amount int64 `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	// Queue lane: "high", "normal" or "low". Empty defaults to high for
+	// treasury and payroll accounts and normal otherwise.
+	Priority      string `protobuf:"bytes,4,opt,name=priority,proto3" json:"priority,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferRequest) Reset() {
+	*x = TransferRequest{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferRequest) ProtoMessage() {}
+
+func (x *TransferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferRequest.ProtoReflect.Descriptor instead.
+func (*TransferRequest) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *TransferRequest) GetFromId() uint64 {
+	if x != nil {
+		return x.FromId
+	}
+	return 0
+}
+
+func (x *TransferRequest) GetToId() uint64 {
+	if x != nil {
+		return x.ToId
+	}
+	return 0
+}
+
+func (x *TransferRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *TransferRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+type TransferResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	Status        TransactionStatus      `protobuf:"varint,2,opt,name=status,proto3,enum=gopherpay.v1.TransactionStatus" json:"status,omitempty"`
+	Priority      string                 `protobuf:"bytes,3,opt,name=priority,proto3" json:"priority,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferResponse) Reset() {
+	*x = TransferResponse{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferResponse) ProtoMessage() {}
+
+func (x *TransferResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferResponse.ProtoReflect.Descriptor instead.
+func (*TransferResponse) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TransferResponse) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *TransferResponse) GetStatus() TransactionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return TransactionStatus_TRANSACTION_STATUS_UNSPECIFIED
+}
+
+func (x *TransferResponse) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+type GetTransferRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTransferRequest) Reset() {
+	*x = GetTransferRequest{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTransferRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTransferRequest) ProtoMessage() {}
+
+func (x *GetTransferRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTransferRequest.ProtoReflect.Descriptor instead.
+func (*GetTransferRequest) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetTransferRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+type ListTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsRequest) Reset() {
+	*x = ListTransactionsRequest{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsRequest) ProtoMessage() {}
+
+func (x *ListTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*ListTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{3}
+}
+
+type ListTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsResponse) Reset() {
+	*x = ListTransactionsResponse{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsResponse) ProtoMessage() {}
+
+func (x *ListTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*ListTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListTransactionsResponse) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type GetAccountRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAccountRequest) Reset() {
+	*x = GetAccountRequest{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAccountRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAccountRequest) ProtoMessage() {}
+
+func (x *GetAccountRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAccountRequest.ProtoReflect.Descriptor instead.
+func (*GetAccountRequest) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetAccountRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type StreamTransferEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Empty streams every transfer visible to the caller.
+	RequestId     string `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamTransferEventsRequest) Reset() {
+	*x = StreamTransferEventsRequest{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamTransferEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamTransferEventsRequest) ProtoMessage() {}
+
+func (x *StreamTransferEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamTransferEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamTransferEventsRequest) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StreamTransferEventsRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+type Account struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Id         uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	CustomerId *uint64                `protobuf:"varint,2,opt,name=customer_id,json=customerId,proto3,oneof" json:"customer_id,omitempty"`
+	Balance    int64                  `protobuf:"varint,3,opt,name=balance,proto3" json:"balance,omitempty"`
+	CreatedAt  *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt  *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// "standard", "treasury" or "payroll".
+	Class         string `protobuf:"bytes,6,opt,name=class,proto3" json:"class,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Account) Reset() {
+	*x = Account{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Account) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Account) ProtoMessage() {}
+
+func (x *Account) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Account.ProtoReflect.Descriptor instead.
+func (*Account) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Account) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Account) GetCustomerId() uint64 {
+	if x != nil && x.CustomerId != nil {
+		return *x.CustomerId
+	}
+	return 0
+}
+
+func (x *Account) GetBalance() int64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+func (x *Account) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Account) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Account) GetClass() string {
+	if x != nil {
+		return x.Class
+	}
+	return ""
+}
+
+type Transaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	RequestId     string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	FromAccountId uint64                 `protobuf:"varint,3,opt,name=from_account_id,json=fromAccountId,proto3" json:"from_account_id,omitempty"`
+	ToAccountId   uint64                 `protobuf:"varint,4,opt,name=to_account_id,json=toAccountId,proto3" json:"to_account_id,omitempty"`
+	Amount        int64                  `protobuf:"varint,5,opt,name=amount,proto3" json:"amount,omitempty"`
+	Status        TransactionStatus      `protobuf:"varint,6,opt,name=status,proto3,enum=gopherpay.v1.TransactionStatus" json:"status,omitempty"`
+	ErrorMessage  *string                `protobuf:"bytes,7,opt,name=error_message,json=errorMessage,proto3,oneof" json:"error_message,omitempty"`
+	FromBalance   int64                  `protobuf:"varint,8,opt,name=from_balance,json=fromBalance,proto3" json:"from_balance,omitempty"`
+	ToBalance     int64                  `protobuf:"varint,9,opt,name=to_balance,json=toBalance,proto3" json:"to_balance,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Transaction) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Transaction) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *Transaction) GetFromAccountId() uint64 {
+	if x != nil {
+		return x.FromAccountId
+	}
+	return 0
+}
+
+func (x *Transaction) GetToAccountId() uint64 {
+	if x != nil {
+		return x.ToAccountId
+	}
+	return 0
+}
+
+func (x *Transaction) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Transaction) GetStatus() TransactionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return TransactionStatus_TRANSACTION_STATUS_UNSPECIFIED
+}
+
+func (x *Transaction) GetErrorMessage() string {
+	if x != nil && x.ErrorMessage != nil {
+		return *x.ErrorMessage
+	}
+	return ""
+}
+
+func (x *Transaction) GetFromBalance() int64 {
+	if x != nil {
+		return x.FromBalance
+	}
+	return 0
+}
+
+func (x *Transaction) GetToBalance() int64 {
+	if x != nil {
+		return x.ToBalance
+	}
+	return 0
+}
+
+func (x *Transaction) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Transaction) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type TransferEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	FromAccountId uint64                 `protobuf:"varint,2,opt,name=from_account_id,json=fromAccountId,proto3" json:"from_account_id,omitempty"`
+	ToAccountId   uint64                 `protobuf:"varint,3,opt,name=to_account_id,json=toAccountId,proto3" json:"to_account_id,omitempty"`
+	Amount        int64                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Status        TransactionStatus      `protobuf:"varint,5,opt,name=status,proto3,enum=gopherpay.v1.TransactionStatus" json:"status,omitempty"`
+	// Stable error code from the REST error envelope, empty on success.
+	ErrorCode     string                 `protobuf:"bytes,6,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
+	OccurredAt    *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=occurred_at,json=occurredAt,proto3" json:"occurred_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransferEvent) Reset() {
+	*x = TransferEvent{}
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransferEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransferEvent) ProtoMessage() {}
+
+func (x *TransferEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_gopherpay_v1_gopherpay_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransferEvent.ProtoReflect.Descriptor instead.
+func (*TransferEvent) Descriptor() ([]byte, []int) {
+	return file_gopherpay_v1_gopherpay_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TransferEvent) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *TransferEvent) GetFromAccountId() uint64 {
+	if x != nil {
+		return x.FromAccountId
+	}
+	return 0
+}
+
+func (x *TransferEvent) GetToAccountId() uint64 {
+	if x != nil {
+		return x.ToAccountId
+	}
+	return 0
+}
+
+func (x *TransferEvent) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *TransferEvent) GetStatus() TransactionStatus {
+	if x != nil {
+		return x.Status
+	}
+	return TransactionStatus_TRANSACTION_STATUS_UNSPECIFIED
+}
+
+func (x *TransferEvent) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *TransferEvent) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+var File_gopherpay_v1_gopherpay_proto protoreflect.FileDescriptor
+
+const file_gopherpay_v1_gopherpay_proto_rawDesc = "" +
+	"\n" +
+	"\x1cgopherpay/v1/gopherpay.proto\x12\fgopherpay.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"s\n" +
+	"\x0fTransferRequest\x12\x17\n" +
+	"\afrom_id\x18\x01 \x01(\x04R\x06fromId\x12\x13\n" +
+	"\x05to_id\x18\x02 \x01(\x04R\x04toId\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bpriority\x18\x04 \x01(\tR\bpriority\"\x86\x01\n" +
+	"\x10TransferResponse\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x127\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x1f.gopherpay.v1.TransactionStatusR\x06status\x12\x1a\n" +
+	"\bpriority\x18\x03 \x01(\tR\bpriority\"3\n" +
+	"\x12GetTransferRequest\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\"\x19\n" +
+	"\x17ListTransactionsRequest\"Y\n" +
+	"\x18ListTransactionsResponse\x12=\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x19.gopherpay.v1.TransactionR\ftransactions\"#\n" +
+	"\x11GetAccountRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\"<\n" +
+	"\x1bStreamTransferEventsRequest\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\"\xf5\x01\n" +
+	"\aAccount\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12$\n" +
+	"\vcustomer_id\x18\x02 \x01(\x04H\x00R\n" +
+	"customerId\x88\x01\x01\x12\x18\n" +
+	"\abalance\x18\x03 \x01(\x03R\abalance\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x14\n" +
+	"\x05class\x18\x06 \x01(\tR\x05classB\x0e\n" +
+	"\f_customer_id\"\xcd\x03\n" +
+	"\vTransaction\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12&\n" +
+	"\x0ffrom_account_id\x18\x03 \x01(\x04R\rfromAccountId\x12\"\n" +
+	"\rto_account_id\x18\x04 \x01(\x04R\vtoAccountId\x12\x16\n" +
+	"\x06amount\x18\x05 \x01(\x03R\x06amount\x127\n" +
+	"\x06status\x18\x06 \x01(\x0e2\x1f.gopherpay.v1.TransactionStatusR\x06status\x12(\n" +
+	"\rerror_message\x18\a \x01(\tH\x00R\ferrorMessage\x88\x01\x01\x12!\n" +
+	"\ffrom_balance\x18\b \x01(\x03R\vfromBalance\x12\x1d\n" +
+	"\n" +
+	"to_balance\x18\t \x01(\x03R\ttoBalance\x129\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\x10\n" +
+	"\x0e_error_message\"\xa7\x02\n" +
+	"\rTransferEvent\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12&\n" +
+	"\x0ffrom_account_id\x18\x02 \x01(\x04R\rfromAccountId\x12\"\n" +
+	"\rto_account_id\x18\x03 \x01(\x04R\vtoAccountId\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x03R\x06amount\x127\n" +
+	"\x06status\x18\x05 \x01(\x0e2\x1f.gopherpay.v1.TransactionStatusR\x06status\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\x06 \x01(\tR\terrorCode\x12;\n" +
+	"\voccurred_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"occurredAt*\x96\x01\n" +
+	"\x11TransactionStatus\x12\"\n" +
+	"\x1eTRANSACTION_STATUS_UNSPECIFIED\x10\x00\x12\x1e\n" +
+	"\x1aTRANSACTION_STATUS_PENDING\x10\x01\x12\x1e\n" +
+	"\x1aTRANSACTION_STATUS_SUCCESS\x10\x02\x12\x1d\n" +
+	"\x19TRANSACTION_STATUS_FAILED\x10\x032\xad\x03\n" +
+	"\tGopherPay\x12I\n" +
+	"\bTransfer\x12\x1d.gopherpay.v1.TransferRequest\x1a\x1e.gopherpay.v1.TransferResponse\x12J\n" +
+	"\vGetTransfer\x12 .gopherpay.v1.GetTransferRequest\x1a\x19.gopherpay.v1.Transaction\x12a\n" +
+	"\x10ListTransactions\x12%.gopherpay.v1.ListTransactionsRequest\x1a&.gopherpay.v1.ListTransactionsResponse\x12D\n" +
+	"\n" +
+	"GetAccount\x12\x1f.gopherpay.v1.GetAccountRequest\x1a\x15.gopherpay.v1.Account\x12`\n" +
+	"\x14StreamTransferEvents\x12).gopherpay.v1.StreamTransferEventsRequest\x1a\x1b.gopherpay.v1.TransferEvent0\x01B1Z/gopherpay/internal/grpc/gopherpayv1;gopherpayv1b\x06proto3"
+
+var (
+	file_gopherpay_v1_gopherpay_proto_rawDescOnce sync.Once
+	file_gopherpay_v1_gopherpay_proto_rawDescData []byte
+)
+
+func file_gopherpay_v1_gopherpay_proto_rawDescGZIP() []byte {
+	file_gopherpay_v1_gopherpay_proto_rawDescOnce.Do(func() {
+		file_gopherpay_v1_gopherpay_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gopherpay_v1_gopherpay_proto_rawDesc), len(file_gopherpay_v1_gopherpay_proto_rawDesc)))
+	})
+	return file_gopherpay_v1_gopherpay_proto_rawDescData
+}
+
+var file_gopherpay_v1_gopherpay_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_gopherpay_v1_gopherpay_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_gopherpay_v1_gopherpay_proto_goTypes = []any{
+	(TransactionStatus)(0),              // 0: gopherpay.v1.TransactionStatus
+	(*TransferRequest)(nil),             // 1: gopherpay.v1.TransferRequest
+	(*TransferResponse)(nil),            // 2: gopherpay.v1.TransferResponse
+	(*GetTransferRequest)(nil),          // 3: gopherpay.v1.GetTransferRequest
+	(*ListTransactionsRequest)(nil),     // 4: gopherpay.v1.ListTransactionsRequest
+	(*ListTransactionsResponse)(nil),    // 5: gopherpay.v1.ListTransactionsResponse
+	(*GetAccountRequest)(nil),           // 6: gopherpay.v1.GetAccountRequest
+	(*StreamTransferEventsRequest)(nil), // 7: gopherpay.v1.StreamTransferEventsRequest
+	(*Account)(nil),                     // 8: gopherpay.v1.Account
+	(*Transaction)(nil),                 // 9: gopherpay.v1.Transaction
+	(*TransferEvent)(nil),               // 10: gopherpay.v1.TransferEvent
+	(*timestamppb.Timestamp)(nil),       // 11: google.protobuf.Timestamp
+}
+var file_gopherpay_v1_gopherpay_proto_depIdxs = []int32{
+	0,  // 0: gopherpay.v1.TransferResponse.status:type_name -> gopherpay.v1.TransactionStatus
+	9,  // 1: gopherpay.v1.ListTransactionsResponse.transactions:type_name -> gopherpay.v1.Transaction
+	11, // 2: gopherpay.v1.Account.created_at:type_name -> google.protobuf.Timestamp
+	11, // 3: gopherpay.v1.Account.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 4: gopherpay.v1.Transaction.status:type_name -> gopherpay.v1.TransactionStatus
+	11, // 5: gopherpay.v1.Transaction.created_at:type_name -> google.protobuf.Timestamp
+	11, // 6: gopherpay.v1.Transaction.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 7: gopherpay.v1.TransferEvent.status:type_name -> gopherpay.v1.TransactionStatus
+	11, // 8: gopherpay.v1.TransferEvent.occurred_at:type_name -> google.protobuf.Timestamp
+	1,  // 9: gopherpay.v1.GopherPay.Transfer:input_type -> gopherpay.v1.TransferRequest
+	3,  // 10: gopherpay.v1.GopherPay.GetTransfer:input_type -> gopherpay.v1.GetTransferRequest
+	4,  // 11: gopherpay.v1.GopherPay.ListTransactions:input_type -> gopherpay.v1.ListTransactionsRequest
+	6,  // 12: gopherpay.v1.GopherPay.GetAccount:input_type -> gopherpay.v1.GetAccountRequest
+	7,  // 13: gopherpay.v1.GopherPay.StreamTransferEvents:input_type -> gopherpay.v1.StreamTransferEventsRequest
+	2,  // 14: gopherpay.v1.GopherPay.Transfer:output_type -> gopherpay.v1.TransferResponse
+	9,  // 15: gopherpay.v1.GopherPay.GetTransfer:output_type -> gopherpay.v1.Transaction
+	5,  // 16: gopherpay.v1.GopherPay.ListTransactions:output_type -> gopherpay.v1.ListTransactionsResponse
+	8,  // 17: gopherpay.v1.GopherPay.GetAccount:output_type -> gopherpay.v1.Account
+	10, // 18: gopherpay.v1.GopherPay.StreamTransferEvents:output_type -> gopherpay.v1.TransferEvent
+	14, // [14:19] is the sub-list for method output_type
+	9,  // [9:14] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_gopherpay_v1_gopherpay_proto_init() }
+func file_gopherpay_v1_gopherpay_proto_init() {
+	if File_gopherpay_v1_gopherpay_proto != nil {
+		return
+	}
+	file_gopherpay_v1_gopherpay_proto_msgTypes[7].OneofWrappers = []any{}
+	file_gopherpay_v1_gopherpay_proto_msgTypes[8].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gopherpay_v1_gopherpay_proto_rawDesc), len(file_gopherpay_v1_gopherpay_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gopherpay_v1_gopherpay_proto_goTypes,
+		DependencyIndexes: file_gopherpay_v1_gopherpay_proto_depIdxs,
+		EnumInfos:         file_gopherpay_v1_gopherpay_proto_enumTypes,
+		MessageInfos:      file_gopherpay_v1_gopherpay_proto_msgTypes,
+	}.Build()
+	File_gopherpay_v1_gopherpay_proto = out.File
+	file_gopherpay_v1_gopherpay_proto_goTypes = nil
+	file_gopherpay_v1_gopherpay_proto_depIdxs = nil
+}
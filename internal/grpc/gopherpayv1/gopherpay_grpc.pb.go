@@ -0,0 +1,299 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: gopherpay/v1/gopherpay.proto
+
+package gopherpayv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	GopherPay_Transfer_FullMethodName             = "/gopherpay.v1.GopherPay/Transfer"
+	GopherPay_GetTransfer_FullMethodName          = "/gopherpay.v1.GopherPay/GetTransfer"
+	GopherPay_ListTransactions_FullMethodName     = "/gopherpay.v1.GopherPay/ListTransactions"
+	GopherPay_GetAccount_FullMethodName           = "/gopherpay.v1.GopherPay/GetAccount"
+	GopherPay_StreamTransferEvents_FullMethodName = "/gopherpay.v1.GopherPay/StreamTransferEvents"
+)
+
+// GopherPayClient is the client API for GopherPay service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// GopherPay mirrors the /v1 REST API. Calls authenticate with "x-api-key"
+// or "authorization: Bearer <jwt>" metadata and may pass "x-request-id",
+// which is echoed back in the response header metadata.
+type GopherPayClient interface {
+	// Transfer queues a transfer and returns once it has been accepted. The
+	// call deadline, if any, bounds how long the transfer may take to process.
+	Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (*TransferResponse, error)
+	// GetTransfer returns a transfer by the request ID it was submitted with.
+	GetTransfer(ctx context.Context, in *GetTransferRequest, opts ...grpc.CallOption) (*Transaction, error)
+	// ListTransactions returns the most recent transactions visible to the caller.
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error)
+	// GetAccount returns an account owned by the caller.
+	GetAccount(ctx context.Context, in *GetAccountRequest, opts ...grpc.CallOption) (*Account, error)
+	// StreamTransferEvents streams the outcome of transfers as workers finish
+	// them, optionally filtered to a single request ID.
+	StreamTransferEvents(ctx context.Context, in *StreamTransferEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransferEvent], error)
+}
+
+type gopherPayClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGopherPayClient(cc grpc.ClientConnInterface) GopherPayClient {
+	return &gopherPayClient{cc}
+}
+
+func (c *gopherPayClient) Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (*TransferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransferResponse)
+	err := c.cc.Invoke(ctx, GopherPay_Transfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gopherPayClient) GetTransfer(ctx context.Context, in *GetTransferRequest, opts ...grpc.CallOption) (*Transaction, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, GopherPay_GetTransfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gopherPayClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTransactionsResponse)
+	err := c.cc.Invoke(ctx, GopherPay_ListTransactions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gopherPayClient) GetAccount(ctx context.Context, in *GetAccountRequest, opts ...grpc.CallOption) (*Account, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Account)
+	err := c.cc.Invoke(ctx, GopherPay_GetAccount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gopherPayClient) StreamTransferEvents(ctx context.Context, in *StreamTransferEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransferEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &GopherPay_ServiceDesc.Streams[0], GopherPay_StreamTransferEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamTransferEventsRequest, TransferEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GopherPay_StreamTransferEventsClient = grpc.ServerStreamingClient[TransferEvent]
+
+// GopherPayServer is the server API for GopherPay service.
+// All implementations must embed UnimplementedGopherPayServer
+// for forward compatibility.
+//
+// GopherPay mirrors the /v1 REST API. Calls authenticate with "x-api-key"
+// or "authorization: Bearer <jwt>" metadata and may pass "x-request-id",
+// which is echoed back in the response header metadata.
+type GopherPayServer interface {
+	// Transfer queues a transfer and returns once it has been accepted. The
+	// call deadline, if any, bounds how long the transfer may take to process.
+	Transfer(context.Context, *TransferRequest) (*TransferResponse, error)
+	// GetTransfer returns a transfer by the request ID it was submitted with.
+	GetTransfer(context.Context, *GetTransferRequest) (*Transaction, error)
+	// ListTransactions returns the most recent transactions visible to the caller.
+	ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error)
+	// GetAccount returns an account owned by the caller.
+	GetAccount(context.Context, *GetAccountRequest) (*Account, error)
+	// StreamTransferEvents streams the outcome of transfers as workers finish
+	// them, optionally filtered to a single request ID.
+	StreamTransferEvents(*StreamTransferEventsRequest, grpc.ServerStreamingServer[TransferEvent]) error
+	mustEmbedUnimplementedGopherPayServer()
+}
+
+// UnimplementedGopherPayServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGopherPayServer struct{}
+
+func (UnimplementedGopherPayServer) Transfer(context.Context, *TransferRequest) (*TransferResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Transfer not implemented")
+}
+func (UnimplementedGopherPayServer) GetTransfer(context.Context, *GetTransferRequest) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransfer not implemented")
+}
+func (UnimplementedGopherPayServer) ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedGopherPayServer) GetAccount(context.Context, *GetAccountRequest) (*Account, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccount not implemented")
+}
+func (UnimplementedGopherPayServer) StreamTransferEvents(*StreamTransferEventsRequest, grpc.ServerStreamingServer[TransferEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTransferEvents not implemented")
+}
+func (UnimplementedGopherPayServer) mustEmbedUnimplementedGopherPayServer() {}
+func (UnimplementedGopherPayServer) testEmbeddedByValue()                   {}
+
+// UnsafeGopherPayServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GopherPayServer will
+// result in compilation errors.
+type UnsafeGopherPayServer interface {
+	mustEmbedUnimplementedGopherPayServer()
+}
+
+func RegisterGopherPayServer(s grpc.ServiceRegistrar, srv GopherPayServer) {
+	// If the following call pancis, it indicates UnimplementedGopherPayServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&GopherPay_ServiceDesc, srv)
+}
+
+func _GopherPay_Transfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GopherPayServer).Transfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GopherPay_Transfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GopherPayServer).Transfer(ctx, req.(*TransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GopherPay_GetTransfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GopherPayServer).GetTransfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GopherPay_GetTransfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GopherPayServer).GetTransfer(ctx, req.(*GetTransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GopherPay_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GopherPayServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GopherPay_ListTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GopherPayServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GopherPay_GetAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GopherPayServer).GetAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GopherPay_GetAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GopherPayServer).GetAccount(ctx, req.(*GetAccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GopherPay_StreamTransferEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTransferEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GopherPayServer).StreamTransferEvents(m, &grpc.GenericServerStream[StreamTransferEventsRequest, TransferEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type GopherPay_StreamTransferEventsServer = grpc.ServerStreamingServer[TransferEvent]
+
+// GopherPay_ServiceDesc is the grpc.ServiceDesc for GopherPay service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GopherPay_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gopherpay.v1.GopherPay",
+	HandlerType: (*GopherPayServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Transfer",
+			Handler:    _GopherPay_Transfer_Handler,
+		},
+		{
+			MethodName: "GetTransfer",
+			Handler:    _GopherPay_GetTransfer_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _GopherPay_ListTransactions_Handler,
+		},
+		{
+			MethodName: "GetAccount",
+			Handler:    _GopherPay_GetAccount_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTransferEvents",
+			Handler:       _GopherPay_StreamTransferEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "gopherpay/v1/gopherpay.proto",
+}
@@ -0,0 +1,113 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"gopherpay/internal/auth"
+	"gopherpay/internal/middleware"
+)
+
+const (
+	requestIDMetadata = "x-request-id"
+	apiKeyMetadata    = "x-api-key"
+)
+
+// UnaryInterceptor assigns a request ID, authenticates the caller and logs
+// the call, mirroring the HTTP middleware chain.
+func UnaryInterceptor(authenticator *auth.Authenticator, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		ctx, err := prepareContext(ctx, authenticator)
+		if err == nil {
+			var resp any
+			resp, err = handler(ctx, req)
+			logCall(ctx, logger, info.FullMethod, start, err)
+			return resp, err
+		}
+
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return nil, err
+	}
+}
+
+func StreamInterceptor(authenticator *auth.Authenticator, logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		ctx, err := prepareContext(ss.Context(), authenticator)
+		if err == nil {
+			err = handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		}
+
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func prepareContext(ctx context.Context, authenticator *auth.Authenticator) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	reqID := first(md, requestIDMetadata)
+	if reqID == "" {
+		reqID = uuid.NewString()
+	}
+	ctx = middleware.WithRequestID(ctx, reqID)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, reqID))
+
+	principal, err := authenticator.Authenticate(ctx,
+		first(md, apiKeyMetadata),
+		auth.BearerToken(first(md, "authorization")),
+	)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrMissingCredentials), errors.Is(err, auth.ErrInvalidCredentials):
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, auth.ErrUnboundCredential):
+		return ctx, status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return ctx, status.Error(codes.Unavailable, "authentication unavailable")
+	}
+
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error) {
+	subject := ""
+	if p, ok := auth.PrincipalFromContext(ctx); ok {
+		subject = p.Subject
+	}
+
+	logger.Info("grpc request",
+		"request_id", middleware.GetRequestID(ctx),
+		"subject", subject,
+		"method", method,
+		"code", status.Code(err).String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,337 @@
+// Package grpc serves the GopherPay API over gRPC. It shares billing,
+// worker and auth components with the REST API so both transports behave
+// identically.
+package grpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=gopherpay --go-grpc_out=../.. --go-grpc_opt=module=gopherpay gopherpay/v1/gopherpay.proto
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/auth"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/events"
+	pb "gopherpay/internal/grpc/gopherpayv1"
+	"gopherpay/internal/middleware"
+	"gopherpay/internal/worker"
+)
+
+type Server struct {
+	pb.UnimplementedGopherPayServer
+
+	pool    *worker.Pool
+	repo    billing.AccountReader
+	events  *events.Broker
+	maxWait time.Duration
+}
+
+func NewServer(pool *worker.Pool, repo billing.AccountReader, broker *events.Broker) *Server {
+	return &Server{
+		pool:    pool,
+		repo:    repo,
+		events:  broker,
+		maxWait: time.Minute,
+	}
+}
+
+// SetMaxWait bounds how long an accepted transfer may wait in the queue.
+// A shorter call deadline takes precedence.
+func (s *Server) SetMaxWait(d time.Duration) {
+	s.maxWait = d
+}
+
+func (s *Server) Transfer(ctx context.Context, in *pb.TransferRequest) (*pb.TransferResponse, error) {
+	principal, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := billing.TransferRequest{
+		RequestID: middleware.GetRequestID(ctx),
+		Subject:   principal.Subject,
+		FromID:    in.GetFromId(),
+		ToID:      in.GetToId(),
+		Amount:    in.GetAmount(),
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, serviceError(err)
+	}
+
+	if !principal.IsOperator() {
+		owned, err := s.repo.IsAccountOwnedBy(ctx, req.FromID, principal.CustomerID)
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		if !owned {
+			return nil, status.Error(codes.PermissionDenied, "source account not owned by caller")
+		}
+	}
+
+	priority, err := s.resolvePriority(ctx, in, principal.IsOperator())
+	switch {
+	case errors.Is(err, worker.ErrInvalidPriority):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, worker.ErrPriorityNotAllowed):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return nil, serviceError(err)
+	}
+
+	job := worker.TransferJob{
+		Request:  req,
+		Priority: priority,
+		Deadline: time.Now().Add(s.maxWait),
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(job.Deadline) {
+		job.Deadline = deadline
+	}
+
+	if err := s.pool.Submit(ctx, job); err != nil {
+		if errors.Is(err, worker.ErrQueueFull) {
+			return nil, status.Error(codes.ResourceExhausted, "transfer queue is full, retry later")
+		}
+		if errors.Is(err, billing.ErrRequestConflict) {
+			return nil, serviceError(err)
+		}
+		return nil, status.Error(codes.Unavailable, "failed to queue transfer")
+	}
+
+	return &pb.TransferResponse{
+		RequestId: req.RequestID,
+		Status:    pb.TransactionStatus_TRANSACTION_STATUS_PENDING,
+		Priority:  priority.String(),
+	}, nil
+}
+
+// resolvePriority mirrors the REST handler: the source account's class sets
+// the default lane, which callers may lower and only operators may raise.
+func (s *Server) resolvePriority(ctx context.Context, in *pb.TransferRequest, operator bool) (worker.Priority, error) {
+	if in.GetPriority() == "low" || in.GetPriority() == "normal" {
+		return worker.ParsePriority(in.GetPriority())
+	}
+
+	class, err := s.repo.GetAccountClass(ctx, in.GetFromId())
+	if errors.Is(err, billing.ErrAccountNotFound) {
+		class = billing.ClassStandard
+	} else if err != nil {
+		return 0, err
+	}
+
+	return worker.ResolvePriority(in.GetPriority(), class, operator)
+}
+
+func (s *Server) GetTransfer(ctx context.Context, in *pb.GetTransferRequest) (*pb.Transaction, error) {
+	principal, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	txn, err := s.repo.GetTransactionByRequestID(ctx, in.GetRequestId())
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	if !principal.IsOperator() {
+		owned, err := s.ownsEither(ctx, principal.CustomerID, txn.FromAccountID, txn.ToAccountID)
+		if err != nil {
+			return nil, serviceError(err)
+		}
+		if !owned {
+			return nil, status.Error(codes.PermissionDenied, "transfer not visible to caller")
+		}
+	}
+
+	return toTransaction(*txn), nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, _ *pb.ListTransactionsRequest) (*pb.ListTransactionsResponse, error) {
+	principal, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var txns []billing.Transaction
+	if principal.IsOperator() {
+		txns, err = s.repo.GetRecentTransactions(ctx)
+	} else {
+		txns, err = s.repo.GetRecentTransactionsByCustomer(ctx, principal.CustomerID)
+	}
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	resp := &pb.ListTransactionsResponse{}
+	for _, txn := range txns {
+		resp.Transactions = append(resp.Transactions, toTransaction(txn))
+	}
+
+	return resp, nil
+}
+
+func (s *Server) GetAccount(ctx context.Context, in *pb.GetAccountRequest) (*pb.Account, error) {
+	principal, err := principalFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	acc, err := s.repo.GetAccount(ctx, in.GetId())
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	if !principal.IsOperator() && (acc.CustomerID == nil || *acc.CustomerID != principal.CustomerID) {
+		return nil, status.Error(codes.PermissionDenied, "account not owned by caller")
+	}
+
+	return &pb.Account{
+		Id:         acc.ID,
+		CustomerId: acc.CustomerID,
+		Balance:    acc.Balance,
+		Class:      string(acc.Class),
+		CreatedAt:  timestamppb.New(acc.CreatedAt),
+		UpdatedAt:  timestamppb.New(acc.UpdatedAt),
+	}, nil
+}
+
+func (s *Server) StreamTransferEvents(in *pb.StreamTransferEventsRequest, stream pb.GopherPay_StreamTransferEventsServer) error {
+	ctx := stream.Context()
+
+	principal, err := principalFrom(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Customers only see transfers touching accounts they owned when the
+	// stream was opened.
+	var owned map[uint64]bool
+	if !principal.IsOperator() {
+		accounts, err := s.repo.GetAccountsByCustomer(ctx, principal.CustomerID)
+		if err != nil {
+			return serviceError(err)
+		}
+		owned = make(map[uint64]bool, len(accounts))
+		for _, acc := range accounts {
+			owned[acc.ID] = true
+		}
+	}
+
+	ch, unsubscribe := s.events.Subscribe(64)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case e, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if in.GetRequestId() != "" && e.RequestID != in.GetRequestId() {
+				continue
+			}
+			if owned != nil && !owned[e.FromID] && !owned[e.ToID] {
+				continue
+			}
+
+			if err := stream.Send(toTransferEvent(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) ownsEither(ctx context.Context, customerID uint64, accountIDs ...uint64) (bool, error) {
+	for _, id := range accountIDs {
+		owned, err := s.repo.IsAccountOwnedBy(ctx, id, customerID)
+		if err != nil || owned {
+			return owned, err
+		}
+	}
+	return false, nil
+}
+
+func principalFrom(ctx context.Context) (*auth.Principal, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	return principal, nil
+}
+
+// serviceError maps billing errors onto gRPC codes, hiding the text of
+// unrecognised errors as the REST API does.
+func serviceError(err error) error {
+	_, code, message := apierror.FromServiceError(err)
+
+	switch code {
+	case apierror.CodeInvalidAmount, apierror.CodeSameAccount:
+		return status.Error(codes.InvalidArgument, message)
+	case apierror.CodeInsufficientFunds:
+		return status.Error(codes.FailedPrecondition, message)
+	case apierror.CodeNotFound:
+		return status.Error(codes.NotFound, message)
+	case apierror.CodeConflict:
+		return status.Error(codes.AlreadyExists, message)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toTransaction(t billing.Transaction) *pb.Transaction {
+	return &pb.Transaction{
+		Id:            t.ID,
+		RequestId:     t.RequestID,
+		FromAccountId: t.FromAccountID,
+		ToAccountId:   t.ToAccountID,
+		Amount:        t.Amount,
+		Status:        toStatus(t.Status),
+		ErrorMessage:  t.ErrorMessage,
+		FromBalance:   t.FromBalance,
+		ToBalance:     t.ToBalance,
+		CreatedAt:     timestamppb.New(t.CreatedAt),
+		UpdatedAt:     timestamppb.New(t.UpdatedAt),
+	}
+}
+
+func toStatus(s billing.TransactionStatus) pb.TransactionStatus {
+	switch s {
+	case billing.StatusPending:
+		return pb.TransactionStatus_TRANSACTION_STATUS_PENDING
+	case billing.StatusSuccess:
+		return pb.TransactionStatus_TRANSACTION_STATUS_SUCCESS
+	case billing.StatusFailed:
+		return pb.TransactionStatus_TRANSACTION_STATUS_FAILED
+	}
+	return pb.TransactionStatus_TRANSACTION_STATUS_UNSPECIFIED
+}
+
+func toTransferEvent(e events.TransferEvent) *pb.TransferEvent {
+	out := &pb.TransferEvent{
+		RequestId:     e.RequestID,
+		FromAccountId: e.FromID,
+		ToAccountId:   e.ToID,
+		Amount:        e.Amount,
+		Status:        pb.TransactionStatus_TRANSACTION_STATUS_SUCCESS,
+		OccurredAt:    timestamppb.New(e.OccurredAt),
+	}
+
+	if e.Err != nil {
+		_, code, _ := apierror.FromServiceError(e.Err)
+		out.Status = pb.TransactionStatus_TRANSACTION_STATUS_FAILED
+		out.ErrorCode = string(code)
+	}
+
+	return out
+}
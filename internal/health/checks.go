@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gopherpay/internal/replica"
+	"gopherpay/internal/worker"
+)
+
+// Database pings db and fails if it is unreachable or slower than
+// maxLatency.
+func Database(db *sql.DB, maxLatency time.Duration) Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		start := time.Now()
+		err := db.PingContext(ctx)
+		latency := time.Since(start)
+
+		detail := map[string]any{"latency_ms": latency.Milliseconds()}
+		if err != nil {
+			return detail, fmt.Errorf("database unreachable: %w", err)
+		}
+		if latency > maxLatency {
+			return detail, fmt.Errorf("ping took %s, limit %s", latency, maxLatency)
+		}
+		return detail, nil
+	}
+}
+
+// ConnectionPool fails when at least maxRatio of db's connections are in
+// use, meaning new queries will wait for a free one. It always passes when
+// the pool is unbounded.
+func ConnectionPool(db *sql.DB, maxRatio float64) Check {
+	return func(context.Context) (map[string]any, error) {
+		s := db.Stats()
+		detail := map[string]any{
+			"open":       s.OpenConnections,
+			"in_use":     s.InUse,
+			"idle":       s.Idle,
+			"max_open":   s.MaxOpenConnections,
+			"wait_count": s.WaitCount,
+		}
+		if s.MaxOpenConnections > 0 && float64(s.InUse) >= maxRatio*float64(s.MaxOpenConnections) {
+			return detail, fmt.Errorf("%d of %d connections in use", s.InUse, s.MaxOpenConnections)
+		}
+		return detail, nil
+	}
+}
+
+// Queue fails when the transfer queue, or any of its priority lanes, is
+// nearly full, so new transfers would be rejected with 429.
+func Queue(pool *worker.Pool) Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		stats, err := pool.Stats(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("queue unreachable: %w", err)
+		}
+
+		fill := 0.0
+		if stats.QueueCapacity > 0 {
+			fill = float64(stats.QueueDepth) / float64(stats.QueueCapacity)
+		}
+		detail := map[string]any{
+			"depth":      stats.QueueDepth,
+			"capacity":   stats.QueueCapacity,
+			"fill_ratio": fill,
+		}
+		if stats.LaneDepths != nil {
+			lanes := make(map[string]any, len(stats.LaneDepths))
+			for p, depth := range stats.LaneDepths {
+				lanes[p.String()] = map[string]any{"depth": depth, "capacity": stats.LaneCapacity[p]}
+			}
+			detail["lanes"] = lanes
+		}
+
+		if full := stats.SaturatedLanes(); len(full) > 0 {
+			p := full[0]
+			return detail, fmt.Errorf("%s lane is %.0f%% full", p, 100*float64(stats.LaneDepths[p])/float64(stats.LaneCapacity[p]))
+		}
+		if stats.Saturated() {
+			return detail, fmt.Errorf("queue is %.0f%% full", fill*100)
+		}
+		return detail, nil
+	}
+}
+
+// Backlog reports writes that have started but not finished.
+type Backlog interface {
+	InFlight() int64
+}
+
+// AuditBacklog fails when more than max audit writes are outstanding, which
+// means the audit table is slowing every transfer down.
+func AuditBacklog(b Backlog, max int64) Check {
+	return func(context.Context) (map[string]any, error) {
+		n := b.InFlight()
+		detail := map[string]any{"in_flight": n, "max": max}
+		if n > max {
+			return detail, fmt.Errorf("%d audit writes outstanding", n)
+		}
+		return detail, nil
+	}
+}
+
+// SchemaVersion fails unless the newest migration recorded in
+// schema_migrations is want, so an instance never serves traffic against a
+// schema it was not built for.
+func SchemaVersion(db *sql.DB, want int) Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		var got sql.NullInt64
+		err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&got)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema version: %w", err)
+		}
+
+		detail := map[string]any{"current": got.Int64, "expected": want}
+		if got.Int64 != int64(want) {
+			return detail, fmt.Errorf("schema is at version %d, expected %d", got.Int64, want)
+		}
+		return detail, nil
+	}
+}
+
+// Replicas reports each read replica's last check. It never fails: reads
+// fall back to the primary while no replica is healthy.
+func Replicas(set *replica.Set) Check {
+	return func(context.Context) (map[string]any, error) {
+		healthy := 0
+		detail := map[string]any{}
+		for _, st := range set.Statuses() {
+			r := map[string]any{"healthy": st.Healthy, "lag_ms": st.Lag.Milliseconds()}
+			if st.Err != nil {
+				r["error"] = st.Err.Error()
+			}
+			if st.Healthy {
+				healthy++
+			}
+			detail[st.Name] = r
+		}
+		detail["healthy"] = healthy
+		detail["total"] = set.Len()
+		return detail, nil
+	}
+}
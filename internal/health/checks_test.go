@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/memstore"
+	"gopherpay/internal/worker"
+)
+
+// One full lane makes the instance not ready even though the queue as a
+// whole has room, because that lane is already rejecting its transfers.
+func TestQueueFailsWhenOneLaneIsFull(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.DiscardHandler)
+	store := memstore.New()
+
+	queue := worker.NewPriorityQueue(
+		worker.Lane{Priority: worker.PriorityHigh, Queue: worker.NewMemoryQueue(100), Weight: 6},
+		worker.Lane{Priority: worker.PriorityNormal, Queue: worker.NewMemoryQueue(100), Weight: 3},
+		worker.Lane{Priority: worker.PriorityLow, Queue: worker.NewMemoryQueue(2), Weight: 1},
+	)
+	pool := worker.NewPool(queue, billing.NewService(store, store, logger), logger)
+
+	check := Queue(pool)
+	if _, err := check(ctx); err != nil {
+		t.Fatalf("empty queue: %v", err)
+	}
+
+	for _, id := range []string{"a", "b"} {
+		job := worker.TransferJob{Request: billing.TransferRequest{RequestID: id, FromID: 1, ToID: 2, Amount: 1}, Priority: worker.PriorityLow}
+		if err := pool.Submit(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	detail, err := check(ctx)
+	if err == nil || !strings.Contains(err.Error(), "low lane") {
+		t.Fatalf("err = %v, want the low lane reported full", err)
+	}
+	if fill := detail["fill_ratio"].(float64); fill > 0.1 {
+		t.Fatalf("fill_ratio = %v; the combined queue should look nearly empty", fill)
+	}
+}
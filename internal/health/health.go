@@ -0,0 +1,118 @@
+// Package health runs the readiness checks behind /readyz: each check
+// reports its own status and duration so an operator can see which
+// dependency took the instance out of rotation.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+	StatusDraining = "draining"
+
+	StatusPass = "pass"
+	StatusFail = "fail"
+)
+
+// Check inspects one dependency. A non-nil error fails readiness; detail is
+// reported either way.
+type Check func(ctx context.Context) (detail map[string]any, err error)
+
+// Result is the outcome of one check.
+type Result struct {
+	Name       string         `json:"name"`
+	Status     string         `json:"status"`
+	DurationMs float64        `json:"duration_ms"`
+	Detail     map[string]any `json:"detail,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// Report is the combined outcome of every registered check.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusReady
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the registered checks concurrently, each bounded by the
+// checker's timeout.
+type Checker struct {
+	timeout  time.Duration
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check. Checks are added at startup, before Run is called.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Drain makes every later Run report the instance as draining, so load
+// balancers stop routing to it while it shuts down.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Run executes every check and reports ready only if all of them pass and
+// the instance is not draining.
+func (c *Checker) Run(ctx context.Context) Report {
+	results := make([]Result, len(c.checks))
+
+	var wg sync.WaitGroup
+	for i, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, nc)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusReady, Checks: results}
+	for _, res := range results {
+		if res.Status == StatusFail {
+			report.Status = StatusNotReady
+		}
+	}
+	if c.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, nc namedCheck) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := nc.check(ctx)
+
+	res := Result{
+		Name:       nc.name,
+		Status:     StatusPass,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		Detail:     detail,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/billing"
+)
+
+// Response bodies are explicit DTOs so renaming a model field never changes
+// the wire format.
+
+type listResponse[T any] struct {
+	Data []T `json:"data"`
+}
+
+type accountResponse struct {
+	ID         uint64    `json:"id"`
+	CustomerID *uint64   `json:"customer_id,omitempty"`
+	Class      string    `json:"class"`
+	Balance    int64     `json:"balance"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type transactionResponse struct {
+	ID            uint64    `json:"id"`
+	RequestID     string    `json:"request_id"`
+	FromAccountID uint64    `json:"from_account_id"`
+	ToAccountID   uint64    `json:"to_account_id"`
+	Amount        int64     `json:"amount"`
+	Status        string    `json:"status"`
+	ErrorMessage  *string   `json:"error_message,omitempty"`
+	FromBalance   int64     `json:"from_balance"`
+	ToBalance     int64     `json:"to_balance"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type auditLogResponse struct {
+	ID        uint64    `json:"id"`
+	RequestID string    `json:"request_id"`
+	Subject   string    `json:"subject,omitempty"`
+	Action    string    `json:"action"`
+	Status    string    `json:"status"`
+	Message   *string   `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type transferAcceptedResponse struct {
+	RequestID string `json:"request_id"`
+	Status    string `json:"status"`
+	Priority  string `json:"priority"`
+}
+
+func toAccountResponse(a billing.Account) accountResponse {
+	return accountResponse{
+		ID:         a.ID,
+		CustomerID: a.CustomerID,
+		Class:      string(a.Class),
+		Balance:    a.Balance,
+		CreatedAt:  a.CreatedAt,
+		UpdatedAt:  a.UpdatedAt,
+	}
+}
+
+func toTransactionResponse(t billing.Transaction) transactionResponse {
+	return transactionResponse{
+		ID:            t.ID,
+		RequestID:     t.RequestID,
+		FromAccountID: t.FromAccountID,
+		ToAccountID:   t.ToAccountID,
+		Amount:        t.Amount,
+		Status:        string(t.Status),
+		ErrorMessage:  t.ErrorMessage,
+		FromBalance:   t.FromBalance,
+		ToBalance:     t.ToBalance,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+	}
+}
+
+func toAuditLogResponse(l audit.AuditLog) auditLogResponse {
+	return auditLogResponse{
+		ID:        l.ID,
+		RequestID: l.RequestID,
+		Subject:   l.Subject,
+		Action:    l.Action,
+		Status:    l.Status,
+		Message:   l.Message,
+		CreatedAt: l.CreatedAt,
+	}
+}
+
+func mapSlice[T, R any](in []T, fn func(T) R) []R {
+	out := make([]R, 0, len(in))
+	for _, v := range in {
+		out = append(out, fn(v))
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
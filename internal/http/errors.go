@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/middleware"
+)
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code apierror.Code, message string) {
+	if isLegacy(r) {
+		http.Error(w, message, status)
+		return
+	}
+	apierror.Write(w, middleware.GetRequestID(r.Context()), status, code, message, nil)
+}
+
+// writeServiceError reports err using its mapped code, or as an internal
+// error with a generic message so driver details do not leak to clients.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	status, code, message := apierror.FromServiceError(err)
+	if message == "" {
+		message = fallback
+	}
+	writeError(w, r, status, code, message)
+}
@@ -2,17 +2,24 @@ package http
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"time"
+
+	"gopherpay/internal/health"
 )
 
+// Pinger is the storage connectivity check behind /health, satisfied by
+// *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
 type HealthHandler struct {
-	db *sql.DB
+	db Pinger
 }
 
-func NewHealthHandler(db *sql.DB) *HealthHandler {
+func NewHealthHandler(db Pinger) *HealthHandler {
 	return &HealthHandler{db: db}
 }
 
@@ -22,7 +29,7 @@ type healthResponse struct {
 
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
 	defer cancel()
 
 	// Check DB connectivity
@@ -39,3 +46,37 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Status: "ok",
 	})
 }
+
+// LivenessHandler answers as long as the process can serve HTTP. It checks
+// no dependencies, so an outage elsewhere never gets the instance
+// restarted.
+type LivenessHandler struct{}
+
+func NewLivenessHandler() LivenessHandler {
+	return LivenessHandler{}
+}
+
+func (LivenessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "alive"})
+}
+
+// ReadinessHandler tells load balancers whether to route traffic here. It
+// returns 503 with the failing checks while a dependency is down, the
+// transfer queue is close to full, or the server is shutting down.
+type ReadinessHandler struct {
+	checker *health.Checker
+}
+
+func NewReadinessHandler(checker *health.Checker) *ReadinessHandler {
+	return &ReadinessHandler{checker: checker}
+}
+
+func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Run(r.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
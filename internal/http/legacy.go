@@ -0,0 +1,32 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+type legacyKey struct{}
+
+// legacyFormat marks requests that arrived on an unversioned alias. Those
+// keep the pre-/v1 wire format: list endpoints return a bare array of the
+// models, errors are plain text and /transfer answers {"status":"pending"}.
+func legacyFormat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), legacyKey{}, true)))
+	})
+}
+
+func isLegacy(r *http.Request) bool {
+	legacy, _ := r.Context().Value(legacyKey{}).(bool)
+	return legacy
+}
+
+// writeList writes items as a {"data": [...]} envelope of DTOs, or as the
+// bare models on a legacy alias.
+func writeList[T, R any](w http.ResponseWriter, r *http.Request, items []T, fn func(T) R) {
+	if isLegacy(r) {
+		writeJSON(w, http.StatusOK, items)
+		return
+	}
+	writeJSON(w, http.StatusOK, listResponse[R]{Data: mapSlice(items, fn)})
+}
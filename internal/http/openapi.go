@@ -0,0 +1,17 @@
+package http
+
+import (
+	_ "embed"
+
+	"gopherpay/internal/openapi"
+)
+
+// openAPIDocument describes every route registered by NewRouter. NewRouter
+// refuses to build a mux when the two disagree.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+func OpenAPISpec() (*openapi.Spec, error) {
+	return openapi.Load(openAPIDocument)
+}
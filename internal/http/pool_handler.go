@@ -0,0 +1,125 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/auth"
+	"gopherpay/internal/worker"
+)
+
+type poolStatsResponse struct {
+	Workers         int            `json:"workers"`
+	QueueDepth      int            `json:"queue_depth"`
+	QueueCapacity   int            `json:"queue_capacity"`
+	Lanes           map[string]int `json:"lanes,omitempty"`
+	InFlight        int64          `json:"in_flight"`
+	Processed       uint64         `json:"processed"`
+	Failed          uint64         `json:"failed"`
+	Abandoned       uint64         `json:"abandoned"`
+	AvgProcessingMs float64        `json:"avg_processing_ms"`
+	Saturated       bool           `json:"saturated"`
+}
+
+func toPoolStatsResponse(s worker.Stats) poolStatsResponse {
+	return poolStatsResponse{
+		Workers:         s.Workers,
+		QueueDepth:      s.QueueDepth,
+		QueueCapacity:   s.QueueCapacity,
+		Lanes:           laneDepths(s.LaneDepths),
+		InFlight:        s.InFlight,
+		Processed:       s.Processed,
+		Failed:          s.Failed,
+		Abandoned:       s.Abandoned,
+		AvgProcessingMs: float64(s.AvgProcessing) / float64(time.Millisecond),
+		Saturated:       s.Saturated(),
+	}
+}
+
+func laneDepths(depths map[worker.Priority]int) map[string]int {
+	if depths == nil {
+		return nil
+	}
+	out := make(map[string]int, len(depths))
+	for p, n := range depths {
+		out[p.String()] = n
+	}
+	return out
+}
+
+// DefaultMaxWorkers caps resizes unless SetMaxWorkers says otherwise.
+const DefaultMaxWorkers = 100
+
+// PoolHandler reports worker pool stats on GET and resizes the pool on PUT.
+// Both are restricted to operators.
+type PoolHandler struct {
+	pool       *worker.Pool
+	maxWorkers int
+}
+
+func NewPoolHandler(pool *worker.Pool) *PoolHandler {
+	return &PoolHandler{pool: pool, maxWorkers: DefaultMaxWorkers}
+}
+
+// SetMaxWorkers bounds the worker count a resize may ask for, so a typo
+// cannot start enough goroutines to exhaust the database connections.
+func (h *PoolHandler) SetMaxWorkers(n int) {
+	h.maxWorkers = n
+}
+
+type poolResizePayload struct {
+	Workers int `json:"workers"`
+}
+
+func (h *PoolHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+	if !principal.IsOperator() {
+		writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, "operator role required")
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var payload poolResizePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid request body")
+			return
+		}
+
+		if payload.Workers > h.maxWorkers {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest,
+				fmt.Sprintf("worker count must be at most %d, got %d", h.maxWorkers, payload.Workers))
+			return
+		}
+
+		err := h.pool.Resize(payload.Workers)
+		if errors.Is(err, worker.ErrResizeUnsupported) || errors.Is(err, worker.ErrAutoscaling) {
+			writeError(w, r, http.StatusConflict, apierror.CodeConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+
+	stats, err := h.pool.Stats(ctx)
+	if err != nil {
+		writeServiceError(w, r, err, "failed to read pool stats")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toPoolStatsResponse(stats))
+}
@@ -2,18 +2,20 @@ package http
 
 import (
 	"context"
-	"encoding/json"
+	"gopherpay/internal/apierror"
 	"gopherpay/internal/audit"
+	"gopherpay/internal/auth"
 	"gopherpay/internal/billing"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 type AccountsHandler struct {
-	repo *billing.MySQLRepository
+	repo billing.AccountReader
 }
 
-func NewAccountsHandler(repo *billing.MySQLRepository) *AccountsHandler {
+func NewAccountsHandler(repo billing.AccountReader) *AccountsHandler {
 	return &AccountsHandler{repo: repo}
 }
 
@@ -22,21 +24,74 @@ func (h *AccountsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	accounts, err := h.repo.GetAllAccounts(ctx)
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+
+	var (
+		accounts []billing.Account
+		err      error
+	)
+	if principal.IsOperator() {
+		accounts, err = h.repo.GetAllAccounts(ctx)
+	} else {
+		accounts, err = h.repo.GetAccountsByCustomer(ctx, principal.CustomerID)
+	}
 	if err != nil {
-		http.Error(w, "failed to fetch accounts", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "failed to fetch accounts")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(accounts)
+	writeList(w, r, accounts, toAccountResponse)
+}
+
+// AccountHandler serves a single account by the {id} path value.
+type AccountHandler struct {
+	repo billing.AccountReader
+}
+
+func NewAccountHandler(repo billing.AccountReader) *AccountHandler {
+	return &AccountHandler{repo: repo}
+}
+
+func (h *AccountHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+
+	accountID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid account id")
+		return
+	}
+
+	acc, err := h.repo.GetAccount(ctx, accountID)
+	if err != nil {
+		writeServiceError(w, r, err, "failed to fetch account")
+		return
+	}
+
+	if !principal.IsOperator() && (acc.CustomerID == nil || *acc.CustomerID != principal.CustomerID) {
+		writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, "account not owned by caller")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toAccountResponse(*acc))
 }
 
 type TransactionsHandler struct {
-	repo *billing.MySQLRepository
+	repo billing.AccountReader
 }
 
-func NewTransactionsHandler(repo *billing.MySQLRepository) *TransactionsHandler {
+func NewTransactionsHandler(repo billing.AccountReader) *TransactionsHandler {
 	return &TransactionsHandler{repo: repo}
 }
 
@@ -45,21 +100,86 @@ func (h *TransactionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	txns, err := h.repo.GetRecentTransactions(ctx)
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+
+	var (
+		txns []billing.Transaction
+		err  error
+	)
+	if principal.IsOperator() {
+		txns, err = h.repo.GetRecentTransactions(ctx)
+	} else {
+		txns, err = h.repo.GetRecentTransactionsByCustomer(ctx, principal.CustomerID)
+	}
+	if err != nil {
+		writeServiceError(w, r, err, "failed to fetch transactions")
+		return
+	}
+
+	writeList(w, r, txns, toTransactionResponse)
+}
+
+// TransferStatusHandler reports the outcome of a submitted transfer by the
+// {request_id} returned when it was accepted.
+type TransferStatusHandler struct {
+	repo billing.AccountReader
+}
+
+func NewTransferStatusHandler(repo billing.AccountReader) *TransferStatusHandler {
+	return &TransferStatusHandler{repo: repo}
+}
+
+func (h *TransferStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+
+	txn, err := h.repo.GetTransactionByRequestID(ctx, r.PathValue("request_id"))
 	if err != nil {
-		http.Error(w, "failed to fetch transactions", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "failed to fetch transfer")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(txns)
+	if !principal.IsOperator() {
+		owned, err := ownsEither(ctx, h.repo, principal.CustomerID, txn.FromAccountID, txn.ToAccountID)
+		if err != nil {
+			writeServiceError(w, r, err, "failed to verify account ownership")
+			return
+		}
+		if !owned {
+			writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, "transfer not visible to caller")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, toTransactionResponse(*txn))
+}
+
+func ownsEither(ctx context.Context, accounts AccountOwnership, customerID uint64, accountIDs ...uint64) (bool, error) {
+	for _, id := range accountIDs {
+		owned, err := accounts.IsAccountOwnedBy(ctx, id, customerID)
+		if err != nil || owned {
+			return owned, err
+		}
+	}
+	return false, nil
 }
 
 type AuditHandler struct {
-	repo *audit.MySQLRepository
+	repo audit.Reader
 }
 
-func NewAuditHandler(repo *audit.MySQLRepository) *AuditHandler {
+func NewAuditHandler(repo audit.Reader) *AuditHandler {
 	return &AuditHandler{repo: repo}
 }
 
@@ -68,12 +188,25 @@ func (h *AuditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	logs, err := h.repo.GetRecentAuditLogs(ctx)
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+
+	var (
+		logs []audit.AuditLog
+		err  error
+	)
+	if principal.IsOperator() {
+		logs, err = h.repo.GetRecentAuditLogs(ctx)
+	} else {
+		logs, err = h.repo.GetRecentAuditLogsByCustomer(ctx, principal.CustomerID)
+	}
 	if err != nil {
-		http.Error(w, "failed to fetch audit logs", http.StatusInternalServerError)
+		writeServiceError(w, r, err, "failed to fetch audit logs")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(logs)
+	writeList(w, r, logs, toAuditLogResponse)
 }
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/middleware"
+	"gopherpay/internal/openapi"
+)
+
+// Handlers are the endpoint implementations composed by NewRouter.
+type Handlers struct {
+	Transfer       http.Handler
+	TransferStatus http.Handler
+	Accounts       http.Handler
+	Account        http.Handler
+	Statement      http.Handler
+	Transactions   http.Handler
+	Audit          http.Handler
+	Pool           http.Handler
+	Health         http.Handler
+	Live           http.Handler
+	Ready          http.Handler
+	Metrics        http.Handler
+	Static         http.Handler
+}
+
+// Route is one versioned API endpoint. Name identifies the route to
+// per-route middleware such as rate limits.
+type Route struct {
+	Name    string
+	Method  string
+	Path    string
+	Handler http.Handler
+}
+
+func (r Route) Pattern() string {
+	return r.Method + " " + r.Path
+}
+
+// APIRoutes returns the /v1 route table.
+func APIRoutes(h Handlers) []Route {
+	return []Route{
+		{Name: "transfers.create", Method: http.MethodPost, Path: "/v1/transfers", Handler: h.Transfer},
+		{Name: "transfers.get", Method: http.MethodGet, Path: "/v1/transfers/{request_id}", Handler: h.TransferStatus},
+		{Name: "accounts.list", Method: http.MethodGet, Path: "/v1/accounts", Handler: h.Accounts},
+		{Name: "accounts.get", Method: http.MethodGet, Path: "/v1/accounts/{id}", Handler: h.Account},
+		{Name: "accounts.statement", Method: http.MethodGet, Path: "/v1/accounts/{id}/statement", Handler: h.Statement},
+		{Name: "transactions.list", Method: http.MethodGet, Path: "/v1/transactions", Handler: h.Transactions},
+		{Name: "audit.list", Method: http.MethodGet, Path: "/v1/audit", Handler: h.Audit},
+		{Name: "pool.get", Method: http.MethodGet, Path: "/v1/admin/pool", Handler: h.Pool},
+		{Name: "pool.resize", Method: http.MethodPut, Path: "/v1/admin/pool", Handler: h.Pool},
+	}
+}
+
+// legacyRoutes are the unversioned paths served before /v1. They remain as
+// deprecated aliases of the named route, keeping their original methods and
+// response format.
+var legacyRoutes = map[string]string{
+	"/transfer":     "transfers.create",
+	"/accounts":     "accounts.list",
+	"/transactions": "transactions.list",
+	"/audit":        "audit.list",
+}
+
+// RouterOptions tune NewRouter. ValidateResponses logs responses that do
+// not match the OpenAPI document through Logger.
+type RouterOptions struct {
+	ValidateResponses bool
+	Logger            *slog.Logger
+}
+
+// NewRouter registers every API route wrapped by wrap, the legacy aliases,
+// the health, liveness and readiness checks, metrics, the OpenAPI document
+// and the dashboard. Request bodies and path parameters are validated against the document before reaching
+// the handler, and an error is returned if the route table and the document
+// have drifted apart.
+func NewRouter(h Handlers, wrap func(Route) http.Handler, opts RouterOptions) (*http.ServeMux, error) {
+	spec, err := OpenAPISpec()
+	if err != nil {
+		return nil, err
+	}
+
+	routes := APIRoutes(h)
+
+	patterns := []string{"GET /health", "GET /livez", "GET /readyz", "GET /metrics", "GET /openapi.json"}
+	for _, route := range routes {
+		patterns = append(patterns, route.Pattern())
+	}
+	if err := spec.CheckRoutes(patterns); err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+
+	for _, route := range routes {
+		op, ok := spec.Operation(route.Method, route.Path)
+		if !ok {
+			return nil, fmt.Errorf("no openapi operation for %s", route.Pattern())
+		}
+
+		// Legacy aliases answer any method with the old body format, so
+		// they skip the validation of the documented /v1 operation.
+		for path, name := range legacyRoutes {
+			if name == route.Name {
+				legacy := Route{Name: route.Name, Path: path, Handler: legacyFormat(route.Handler)}
+				mux.Handle(path, middleware.Deprecated(route.Path)(wrap(legacy)))
+			}
+		}
+
+		route.Handler = openapi.ValidateRequests(spec, op)(route.Handler)
+		if opts.ValidateResponses {
+			route.Handler = openapi.ValidateResponses(spec, op, opts.Logger)(route.Handler)
+		}
+
+		mux.Handle(route.Pattern(), wrap(route))
+	}
+
+	mux.Handle("/v1/", middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusNotFound, apierror.CodeNotFound, "no such endpoint")
+	})))
+
+	mux.Handle("GET /health", h.Health)
+	mux.Handle("GET /livez", h.Live)
+	mux.Handle("GET /readyz", h.Ready)
+	mux.Handle("GET /metrics", h.Metrics)
+	mux.Handle("GET /openapi.json", openapi.Handler(openAPIDocument))
+	mux.Handle("/", h.Static)
+
+	return mux, nil
+}
@@ -0,0 +1,256 @@
+package http
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopherpay/internal/auth"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/health"
+	"gopherpay/internal/memstore"
+	"gopherpay/internal/middleware"
+	"gopherpay/internal/worker"
+)
+
+// testRole selects the principal the test router attaches to a request.
+const testRole = "X-Test-Role"
+
+type testEnv struct {
+	mux  *http.ServeMux
+	pool *worker.Pool
+}
+
+// newTestEnv builds the router over a memstore holding two accounts, the
+// first owned by customer 1, and one completed transfer with request ID
+// "seed". Jobs are queued in memory but not processed.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store := memstore.New()
+	from, err := store.CreateAccount(ctx, 10_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := store.CreateAccount(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer, err := store.CreateCustomer(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AssignAccount(ctx, customer, from); err != nil {
+		t.Fatal(err)
+	}
+
+	service := billing.NewService(store, store, logger)
+	if err := service.Transfer(ctx, billing.TransferRequest{RequestID: "seed", FromID: from, ToID: to, Amount: 250}); err != nil {
+		t.Fatal(err)
+	}
+
+	pool := worker.NewPool(worker.NewMemoryQueue(10), service, logger)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		pool.Shutdown(ctx)
+	})
+
+	checker := health.NewChecker(time.Second)
+
+	handlers := Handlers{
+		Transfer:       NewTransferHandler(pool, store, store),
+		TransferStatus: NewTransferStatusHandler(store),
+		Accounts:       NewAccountsHandler(store),
+		Account:        NewAccountHandler(store),
+		Statement:      NewStatementHandler(store),
+		Transactions:   NewTransactionsHandler(store),
+		Audit:          NewAuditHandler(store),
+		Pool:           NewPoolHandler(pool),
+		Health:         NewHealthHandler(store),
+		Live:           NewLivenessHandler(),
+		Ready:          NewReadinessHandler(checker),
+		Metrics:        http.NotFoundHandler(),
+		Static:         http.NotFoundHandler(),
+	}
+
+	wrap := func(route Route) http.Handler {
+		return middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Header.Get(testRole) {
+			case "operator":
+				r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "ops", Role: auth.RoleOperator}))
+			case "customer":
+				r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Subject: "alice", CustomerID: customer, Role: auth.RoleCustomer}))
+			}
+			route.Handler.ServeHTTP(w, r)
+		}))
+	}
+
+	mux, err := NewRouter(handlers, wrap, RouterOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testEnv{mux: mux, pool: pool}
+}
+
+func TestHandlersMatchOpenAPI(t *testing.T) {
+	env := newTestEnv(t)
+
+	spec, err := OpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		pattern    string // documented path the request is validated against
+		url        string
+		body       string
+		role       string
+		wantStatus int
+	}{
+		{"create transfer", "POST", "/v1/transfers", "/v1/transfers", `{"from_id":1,"to_id":2,"amount":100}`, "operator", http.StatusAccepted},
+		{"create transfer unauthenticated", "POST", "/v1/transfers", "/v1/transfers", `{"from_id":1,"to_id":2,"amount":100}`, "", http.StatusUnauthorized},
+		{"create transfer same account", "POST", "/v1/transfers", "/v1/transfers", `{"from_id":1,"to_id":1,"amount":100}`, "operator", http.StatusBadRequest},
+		{"create transfer missing field", "POST", "/v1/transfers", "/v1/transfers", `{"from_id":1,"amount":100}`, "operator", http.StatusBadRequest},
+		{"create transfer from foreign account", "POST", "/v1/transfers", "/v1/transfers", `{"from_id":2,"to_id":1,"amount":100}`, "customer", http.StatusForbidden},
+		{"get transfer", "GET", "/v1/transfers/{request_id}", "/v1/transfers/seed", "", "operator", http.StatusOK},
+		{"get unknown transfer", "GET", "/v1/transfers/{request_id}", "/v1/transfers/nope", "", "operator", http.StatusNotFound},
+		{"list accounts", "GET", "/v1/accounts", "/v1/accounts", "", "operator", http.StatusOK},
+		{"list own accounts", "GET", "/v1/accounts", "/v1/accounts", "", "customer", http.StatusOK},
+		{"list accounts unauthenticated", "GET", "/v1/accounts", "/v1/accounts", "", "", http.StatusUnauthorized},
+		{"get account", "GET", "/v1/accounts/{id}", "/v1/accounts/1", "", "customer", http.StatusOK},
+		{"get foreign account", "GET", "/v1/accounts/{id}", "/v1/accounts/2", "", "customer", http.StatusForbidden},
+		{"get unknown account", "GET", "/v1/accounts/{id}", "/v1/accounts/99", "", "operator", http.StatusNotFound},
+		{"get account bad id", "GET", "/v1/accounts/{id}", "/v1/accounts/abc", "", "operator", http.StatusBadRequest},
+		{"statement", "GET", "/v1/accounts/{id}/statement", "/v1/accounts/1/statement", "", "customer", http.StatusOK},
+		{"statement bad period", "GET", "/v1/accounts/{id}/statement", "/v1/accounts/1/statement?from=yesterday", "", "customer", http.StatusBadRequest},
+		{"list transactions", "GET", "/v1/transactions", "/v1/transactions", "", "operator", http.StatusOK},
+		{"list audit", "GET", "/v1/audit", "/v1/audit", "", "operator", http.StatusOK},
+		{"pool stats", "GET", "/v1/admin/pool", "/v1/admin/pool", "", "operator", http.StatusOK},
+		{"pool stats as customer", "GET", "/v1/admin/pool", "/v1/admin/pool", "", "customer", http.StatusForbidden},
+		{"resize pool", "PUT", "/v1/admin/pool", "/v1/admin/pool", `{"workers":2}`, "operator", http.StatusOK},
+		{"resize pool past the max", "PUT", "/v1/admin/pool", "/v1/admin/pool", `{"workers":100000}`, "operator", http.StatusBadRequest},
+		{"resize pool to zero", "PUT", "/v1/admin/pool", "/v1/admin/pool", `{"workers":0}`, "operator", http.StatusBadRequest},
+		{"health", "GET", "/health", "/health", "", "", http.StatusOK},
+		{"liveness", "GET", "/livez", "/livez", "", "", http.StatusOK},
+		{"readiness", "GET", "/readyz", "/readyz", "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.role != "" {
+				req.Header.Set(testRole, tt.role)
+			}
+
+			rec := httptest.NewRecorder()
+			env.mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			op, ok := spec.Operation(tt.method, tt.pattern)
+			if !ok {
+				t.Fatalf("no documented operation for %s %s", tt.method, tt.pattern)
+			}
+			if errs := spec.ValidateResponse(op, rec.Code, rec.Body.Bytes()); len(errs) > 0 {
+				t.Errorf("response does not match the spec: %v; body: %s", errs, rec.Body)
+			}
+		})
+	}
+}
+
+// The unversioned aliases keep the format and methods they had before /v1.
+func TestLegacyAliases(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantBody   string // prefix of the response body
+	}{
+		{"transfer", "POST", "/transfer", `{"from_id":1,"to_id":2,"amount":100}`, http.StatusAccepted, `{"status":"pending"}`},
+		{"transfer with PUT", "PUT", "/transfer", `{"from_id":1,"to_id":2,"amount":100}`, http.StatusAccepted, `{"status":"pending"}`},
+		{"transfer bad body", "POST", "/transfer", `nope`, http.StatusBadRequest, "invalid request body\n"},
+		{"accounts", "GET", "/accounts", "", http.StatusOK, `[{"ID":1,`},
+		{"transactions", "GET", "/transactions", "", http.StatusOK, `[{"ID":1,"RequestID":"seed",`},
+		{"audit", "GET", "/audit", "", http.StatusOK, `[`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set(testRole, "operator")
+
+			rec := httptest.NewRecorder()
+			env.mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if !strings.HasPrefix(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to start with %s", rec.Body, tt.wantBody)
+			}
+			if rec.Header().Get("Deprecation") != "true" {
+				t.Error("no Deprecation header")
+			}
+		})
+	}
+}
+
+// A manual resize is refused while the autoscaler owns the pool size.
+func TestResizeWhileAutoscaling(t *testing.T) {
+	env := newTestEnv(t)
+
+	spec, err := OpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, ok := spec.Operation("PUT", "/v1/admin/pool")
+	if !ok {
+		t.Fatal("no documented operation for PUT /v1/admin/pool")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go env.pool.Autoscale(ctx, worker.AutoscaleConfig{Min: 1, Max: 4, Interval: time.Hour})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req := httptest.NewRequest("PUT", "/v1/admin/pool", strings.NewReader(`{"workers":2}`))
+		req.Header.Set(testRole, "operator")
+
+		rec := httptest.NewRecorder()
+		env.mux.ServeHTTP(rec, req)
+
+		if rec.Code == http.StatusConflict {
+			if errs := spec.ValidateResponse(op, rec.Code, rec.Body.Bytes()); len(errs) > 0 {
+				t.Errorf("response does not match the spec: %v; body: %s", errs, rec.Body)
+			}
+			return
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 409; body: %s", rec.Code, rec.Body)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("resize still accepted while autoscaling")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
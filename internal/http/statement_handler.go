@@ -0,0 +1,92 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/auth"
+	"gopherpay/internal/billing"
+	"gopherpay/internal/replica"
+	"gopherpay/internal/statement"
+)
+
+// StatementHandler serves the statement of the {id} account for the period
+// given by the from and to query parameters, streamed as JSON.
+type StatementHandler struct {
+	repo billing.AccountReader
+}
+
+func NewStatementHandler(repo billing.AccountReader) *StatementHandler {
+	return &StatementHandler{repo: repo}
+}
+
+func (h *StatementHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	// A statement can run for as long as its period is long, so there is no
+	// deadline beyond the client's. Its reads are pinned to one database so
+	// the opening balance and the lines agree.
+	ctx := replica.Pin(r.Context())
+
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+
+	accountID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid account id")
+		return
+	}
+
+	q := r.URL.Query()
+	from, to, err := statement.ParsePeriod(q.Get("from"), q.Get("to"), time.Now().UTC())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	acc, err := h.repo.GetAccount(ctx, accountID)
+	if err != nil {
+		writeServiceError(w, r, err, "failed to fetch account")
+		return
+	}
+
+	if !principal.IsOperator() && (acc.CustomerID == nil || *acc.CustomerID != principal.CustomerID) {
+		writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, "account not owned by caller")
+		return
+	}
+
+	out := &sentWriter{w: w}
+	sw, err := statement.NewWriter("json", out)
+	if err != nil {
+		writeServiceError(w, r, err, "failed to write statement")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := statement.Generate(ctx, h.repo, accountID, from, to, sw); err != nil {
+		if !out.sent {
+			writeServiceError(w, r, err, "failed to generate statement")
+			return
+		}
+		// The 200 and part of the body are already out. Abort the
+		// connection so the client sees a truncated response rather than
+		// a statement that looks complete.
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// sentWriter records whether anything has reached the client.
+type sentWriter struct {
+	w    io.Writer
+	sent bool
+}
+
+func (s *sentWriter) Write(b []byte) (int, error) {
+	s.sent = true
+	return s.w.Write(b)
+}
@@ -1,69 +1,206 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"gopherpay/internal/apierror"
 	"gopherpay/internal/audit"
+	"gopherpay/internal/auth"
 	"gopherpay/internal/billing"
 	"gopherpay/internal/middleware"
 	"gopherpay/internal/worker"
 )
 
+// AccountOwnership reports whether a customer owns an account.
+type AccountOwnership interface {
+	IsAccountOwnedBy(ctx context.Context, accountID, customerID uint64) (bool, error)
+}
+
+// TransferAccounts is what the transfer handler needs to know about the
+// source account: who owns it and which priority its class implies.
+type TransferAccounts interface {
+	AccountOwnership
+	GetAccountClass(ctx context.Context, accountID uint64) (billing.AccountClass, error)
+}
+
 type TransferHandler struct {
 	pool      *worker.Pool
 	auditRepo audit.Repository
+	accounts  TransferAccounts
+	maxWait   time.Duration
 }
 
-func NewTransferHandler(pool *worker.Pool, auditRepo audit.Repository) *TransferHandler {
+func NewTransferHandler(pool *worker.Pool, auditRepo audit.Repository, accounts TransferAccounts) *TransferHandler {
 	return &TransferHandler{
 		pool:      pool,
 		auditRepo: auditRepo,
+		accounts:  accounts,
+		maxWait:   time.Minute,
+	}
+}
+
+// SetMaxWait bounds how long an accepted transfer may wait in the queue
+// before it is failed as expired instead of executed late. Clients can ask
+// for less with an X-Transfer-Timeout header, in seconds.
+func (h *TransferHandler) SetMaxWait(d time.Duration) {
+	h.maxWait = d
+}
+
+// deadline returns when a transfer accepted now must have started.
+func (h *TransferHandler) deadline(r *http.Request) (time.Time, error) {
+	wait := h.maxWait
+	if raw := r.Header.Get("X-Transfer-Timeout"); raw != "" {
+		secs, err := strconv.Atoi(raw)
+		if err != nil || secs < 1 {
+			return time.Time{}, errors.New("X-Transfer-Timeout must be a positive number of seconds")
+		}
+		wait = min(wait, time.Duration(secs)*time.Second)
 	}
+	return time.Now().Add(wait), nil
 }
 
 type transferRequestPayload struct {
-	FromID uint64 `json:"from_id"`
-	ToID   uint64 `json:"to_id"`
-	Amount int64  `json:"amount"`
+	FromID   uint64 `json:"from_id"`
+	ToID     uint64 `json:"to_id"`
+	Amount   int64  `json:"amount"`
+	Priority string `json:"priority"` // optional, defaults from the account class
 }
 
 func (h *TransferHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
+	reqID := middleware.GetRequestID(r.Context())
+
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "unauthorized")
+		return
+	}
+
 	var payload transferRequestPayload
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 
-		reqID := middleware.GetRequestID(r.Context())
 		msg := "invalid json payload"
 
 		// log audit
 		h.auditRepo.Log(r.Context(), &audit.AuditLog{
 			RequestID: reqID,
+			Subject:   principal.Subject,
 			Action:    "TRANSFER",
 			Status:    "FAILED",
 			Message:   &msg,
 		})
 
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid request body")
 		return
 	}
 
-	reqID := middleware.GetRequestID(r.Context())
+	req := billing.TransferRequest{
+		RequestID: reqID,
+		Subject:   principal.Subject,
+		FromID:    payload.FromID,
+		ToID:      payload.ToID,
+		Amount:    payload.Amount,
+	}
+
+	// Reject requests that can never succeed before they take a queue slot.
+	if err := req.Validate(); err != nil {
+		writeServiceError(w, r, err, "invalid transfer")
+		return
+	}
+
+	// Customers may only move money out of accounts they own.
+	if !principal.IsOperator() {
+		owned, err := h.accounts.IsAccountOwnedBy(r.Context(), payload.FromID, principal.CustomerID)
+		if err != nil {
+			writeServiceError(w, r, err, "failed to verify account ownership")
+			return
+		}
+		if !owned {
+			msg := "source account not owned by caller"
+			h.auditRepo.Log(r.Context(), &audit.AuditLog{
+				RequestID: reqID,
+				Subject:   principal.Subject,
+				Action:    "TRANSFER",
+				Status:    "FAILED",
+				Message:   &msg,
+			})
+
+			writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, "source account not owned by caller")
+			return
+		}
+	}
+
+	priority, err := h.resolvePriority(r.Context(), payload, principal.IsOperator())
+	switch {
+	case errors.Is(err, worker.ErrInvalidPriority):
+		writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	case errors.Is(err, worker.ErrPriorityNotAllowed):
+		writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, err.Error())
+		return
+	case err != nil:
+		writeServiceError(w, r, err, "failed to resolve transfer priority")
+		return
+	}
+
+	deadline, err := h.deadline(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
 
 	job := worker.TransferJob{
-		Request: billing.TransferRequest{
-			RequestID: reqID,
-			FromID:    payload.FromID,
-			ToID:      payload.ToID,
-			Amount:    payload.Amount,
-		},
+		Request:  req,
+		Priority: priority,
+		Deadline: deadline,
 	}
 
-	if !h.pool.Submit(job) {
-		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	// Only acknowledge once the job is durably queued
+	if err := h.pool.Submit(r.Context(), job); err != nil {
+		if errors.Is(err, worker.ErrQueueFull) {
+			writeError(w, r, http.StatusTooManyRequests, apierror.CodeQueueFull, "transfer queue is full, retry later")
+			return
+		}
+		if errors.Is(err, billing.ErrRequestConflict) {
+			writeServiceError(w, r, err, "request id conflict")
+			return
+		}
+		writeError(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "failed to queue transfer")
 		return
 	}
 
-	w.WriteHeader(http.StatusAccepted)
-	w.Write([]byte(`{"status":"pending"}`))
+	w.Header().Set("Location", "/v1/transfers/"+reqID)
+	if isLegacy(r) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"pending"}`))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, transferAcceptedResponse{
+		RequestID: reqID,
+		Status:    "pending",
+		Priority:  priority.String(),
+	})
+}
+
+// resolvePriority looks up the source account's class only when it can
+// matter. Unknown accounts get the default lane and fail during processing
+// like any other transfer from a missing account.
+func (h *TransferHandler) resolvePriority(ctx context.Context, payload transferRequestPayload, operator bool) (worker.Priority, error) {
+	if payload.Priority == "low" || payload.Priority == "normal" {
+		return worker.ParsePriority(payload.Priority)
+	}
+
+	class, err := h.accounts.GetAccountClass(ctx, payload.FromID)
+	if errors.Is(err, billing.ErrAccountNotFound) {
+		class = billing.ClassStandard
+	} else if err != nil {
+		return 0, err
+	}
+
+	return worker.ResolvePriority(payload.Priority, class, operator)
 }
@@ -0,0 +1,89 @@
+// Package memstore keeps accounts, transactions, audit entries and
+// credentials in memory, implementing the billing, audit and auth
+// repositories for demos and hermetic tests.
+//
+// It behaves like one InnoDB database as far as Service.Transfer can tell:
+// writes become visible to others only on Commit, locking reads and writes
+// hold row locks until the transaction ends, a lock cycle fails the
+// transaction that closes it with dberr.ErrDeadlock (rolling it back), and
+// a waiter gives up with dberr.ErrLockWaitTimeout after the lock wait
+// timeout. Both errors are classified as transient, so the service's retry
+// path runs exactly as it would against MySQL.
+package memstore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/auth"
+	"gopherpay/internal/billing"
+)
+
+// recentLimit matches the LIMIT of the MySQL "recent" queries.
+const recentLimit = 50
+
+type Store struct {
+	mu       sync.Mutex
+	lockWait time.Duration
+
+	accounts     map[uint64]*billing.Account
+	transactions map[uint64]*billing.Transaction
+	byRequest    map[string]uint64
+	auditLogs    []audit.AuditLog
+	customers    map[uint64]*auth.Customer
+	credentials  map[uint64]*auth.Credential
+
+	// Row locks by key, e.g. "accounts/1"
+	locks map[string]*rowLock
+
+	nextAccountID    uint64
+	nextTxnID        uint64
+	nextAuditID      uint64
+	nextCustomerID   uint64
+	nextCredentialID uint64
+}
+
+func New() *Store {
+	return &Store{
+		lockWait:     50 * time.Second, // innodb_lock_wait_timeout default
+		accounts:     make(map[uint64]*billing.Account),
+		transactions: make(map[uint64]*billing.Transaction),
+		byRequest:    make(map[string]uint64),
+		customers:    make(map[uint64]*auth.Customer),
+		credentials:  make(map[uint64]*auth.Credential),
+		locks:        make(map[string]*rowLock),
+	}
+}
+
+// SetLockWaitTimeout sets how long a transaction waits for a row lock
+// before failing with dberr.ErrLockWaitTimeout.
+func (s *Store) SetLockWaitTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockWait = d
+}
+
+// PingContext always succeeds; it lets the store stand in for *sql.DB in
+// health checks.
+func (s *Store) PingContext(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// CreateAccount adds a standard account with the given opening balance.
+func (s *Store) CreateAccount(ctx context.Context, balance int64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextAccountID++
+	now := time.Now()
+	s.accounts[s.nextAccountID] = &billing.Account{
+		ID:        s.nextAccountID,
+		Class:     billing.ClassStandard,
+		Balance:   balance,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return s.nextAccountID, nil
+}
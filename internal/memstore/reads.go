@@ -0,0 +1,302 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/auth"
+	"gopherpay/internal/billing"
+)
+
+// Reads outside a transaction see committed rows only.
+
+func (s *Store) GetAllAccounts(ctx context.Context) ([]billing.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accountsWhere(func(*billing.Account) bool { return true }), nil
+}
+
+func (s *Store) GetAccount(ctx context.Context, accountID uint64) (*billing.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return nil, billing.ErrAccountNotFound
+	}
+	out := *acc
+	return &out, nil
+}
+
+func (s *Store) GetAccountsByCustomer(ctx context.Context, customerID uint64) ([]billing.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accountsWhere(func(acc *billing.Account) bool {
+		return ownedBy(acc, customerID)
+	}), nil
+}
+
+func (s *Store) IsAccountOwnedBy(ctx context.Context, accountID, customerID uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	return ok && ownedBy(acc, customerID), nil
+}
+
+func (s *Store) GetAccountClass(ctx context.Context, accountID uint64) (billing.AccountClass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return "", billing.ErrAccountNotFound
+	}
+	return acc.Class, nil
+}
+
+func (s *Store) SetAccountClass(ctx context.Context, accountID uint64, class billing.AccountClass) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return billing.ErrAccountNotFound
+	}
+	acc.Class = class
+	acc.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *Store) GetRecentTransactions(ctx context.Context) ([]billing.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.transactionsWhere(func(*billing.Transaction) bool { return true }), nil
+}
+
+func (s *Store) GetRecentTransactionsByCustomer(ctx context.Context, customerID uint64) ([]billing.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.transactionsWhere(func(txn *billing.Transaction) bool {
+		return s.touches(txn, customerID)
+	}), nil
+}
+
+func (s *Store) GetTransactionByRequestID(ctx context.Context, requestID string) (*billing.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.byRequest[requestID]
+	if !ok {
+		return nil, billing.ErrTransactionNotFound
+	}
+	out := *s.transactions[id]
+	return &out, nil
+}
+
+func (s *Store) BalanceAt(ctx context.Context, accountID uint64, at time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return 0, fmt.Errorf("failed to compute balance of account %d: %w", accountID, billing.ErrAccountNotFound)
+	}
+	bal := acc.Balance
+	for _, txn := range s.postings(accountID, at, time.Time{}) {
+		if txn.ToAccountID == accountID {
+			bal -= txn.Amount
+		} else {
+			bal += txn.Amount
+		}
+	}
+	return bal, nil
+}
+
+// ForEachPosting copies the matching rows before calling fn, so fn runs
+// without the store's mutex.
+func (s *Store) ForEachPosting(ctx context.Context, accountID uint64, from, to time.Time, fn func(billing.Transaction) error) error {
+	s.mu.Lock()
+	txns := s.postings(accountID, from, to)
+	s.mu.Unlock()
+
+	for _, txn := range txns {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(txn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postings returns the SUCCESS transfers on accountID created in
+// [from, to), oldest first. A zero to has no upper bound.
+func (s *Store) postings(accountID uint64, from, to time.Time) []billing.Transaction {
+	var out []billing.Transaction
+	for _, txn := range s.transactions {
+		if txn.Status != billing.StatusSuccess ||
+			(txn.FromAccountID != accountID && txn.ToAccountID != accountID) ||
+			txn.CreatedAt.Before(from) || (!to.IsZero() && !txn.CreatedAt.Before(to)) {
+			continue
+		}
+		out = append(out, *txn)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (s *Store) accountsWhere(keep func(*billing.Account) bool) []billing.Account {
+	var out []billing.Account
+	for _, acc := range s.accounts {
+		if keep(acc) {
+			out = append(out, *acc)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// transactionsWhere returns the newest matching transactions first, at
+// most recentLimit of them.
+func (s *Store) transactionsWhere(keep func(*billing.Transaction) bool) []billing.Transaction {
+	var out []billing.Transaction
+	for _, txn := range s.transactions {
+		if keep(txn) {
+			out = append(out, *txn)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	if len(out) > recentLimit {
+		out = out[:recentLimit]
+	}
+	return out
+}
+
+// touches reports whether either side of txn is owned by the customer.
+func (s *Store) touches(txn *billing.Transaction, customerID uint64) bool {
+	from, to := s.accounts[txn.FromAccountID], s.accounts[txn.ToAccountID]
+	return (from != nil && ownedBy(from, customerID)) || (to != nil && ownedBy(to, customerID))
+}
+
+func ownedBy(acc *billing.Account, customerID uint64) bool {
+	return acc.CustomerID != nil && *acc.CustomerID == customerID
+}
+
+// Log appends an audit entry. Like the MySQL repository it is not part of
+// any transfer transaction.
+func (s *Store) Log(ctx context.Context, entry *audit.AuditLog) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to insert audit log: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextAuditID++
+	row := *entry
+	row.ID = s.nextAuditID
+	row.CreatedAt = time.Now()
+	s.auditLogs = append(s.auditLogs, row)
+	return nil
+}
+
+func (s *Store) GetRecentAuditLogs(ctx context.Context) ([]audit.AuditLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.auditLogsWhere(func(audit.AuditLog) bool { return true }), nil
+}
+
+// GetRecentAuditLogsByCustomer returns audit entries for transfers that
+// touched an account owned by the customer.
+func (s *Store) GetRecentAuditLogsByCustomer(ctx context.Context, customerID uint64) ([]audit.AuditLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.auditLogsWhere(func(entry audit.AuditLog) bool {
+		id, ok := s.byRequest[entry.RequestID]
+		return ok && s.touches(s.transactions[id], customerID)
+	}), nil
+}
+
+func (s *Store) auditLogsWhere(keep func(audit.AuditLog) bool) []audit.AuditLog {
+	var out []audit.AuditLog
+	for i := len(s.auditLogs) - 1; i >= 0 && len(out) < recentLimit; i-- {
+		if keep(s.auditLogs[i]) {
+			out = append(out, s.auditLogs[i])
+		}
+	}
+	return out
+}
+
+func (s *Store) FindCredential(ctx context.Context, kind auth.CredentialKind, identifier string) (*auth.Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cred := range s.credentials {
+		if cred.Kind == kind && cred.Identifier == identifier && cred.RevokedAt == nil {
+			out := *cred
+			return &out, nil
+		}
+	}
+	return nil, auth.ErrCredentialNotFound
+}
+
+func (s *Store) CreateCustomer(ctx context.Context, name string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextCustomerID++
+	s.customers[s.nextCustomerID] = &auth.Customer{ID: s.nextCustomerID, Name: name, CreatedAt: time.Now()}
+	return s.nextCustomerID, nil
+}
+
+func (s *Store) AssignAccount(ctx context.Context, customerID, accountID uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return fmt.Errorf("account %d not found", accountID)
+	}
+	if _, ok := s.customers[customerID]; !ok {
+		return fmt.Errorf("failed to assign account %d: customer %d not found", accountID, customerID)
+	}
+	acc.CustomerID = &customerID
+	acc.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *Store) CreateCredential(ctx context.Context, cred *auth.Credential) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.credentials {
+		if existing.Kind == cred.Kind && existing.Identifier == cred.Identifier {
+			return 0, fmt.Errorf("failed to insert credential: %s %q already exists", cred.Kind, cred.Identifier)
+		}
+	}
+
+	s.nextCredentialID++
+	row := *cred
+	row.ID = s.nextCredentialID
+	row.CreatedAt = time.Now()
+	s.credentials[row.ID] = &row
+	return row.ID, nil
+}
+
+var (
+	_ billing.Store    = (*Store)(nil)
+	_ audit.Repository = (*Store)(nil)
+	_ audit.Reader     = (*Store)(nil)
+	_ auth.Repository  = (*Store)(nil)
+)
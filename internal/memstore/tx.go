@@ -0,0 +1,377 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/dberr"
+)
+
+type rowLock struct {
+	owner    *memTx
+	released chan struct{} // closed when owner ends
+}
+
+// memTx buffers its writes until Commit. Every field is guarded by the
+// store's mutex.
+type memTx struct {
+	store      *Store
+	done       bool
+	held       []string
+	waitingFor *memTx // owner of the lock this transaction is blocked on
+
+	balances map[uint64]int64
+	versions map[uint64]uint64 // account versions after tx's updates
+	inserted map[uint64]*billing.Transaction
+	statuses map[uint64]statusUpdate
+}
+
+type statusUpdate struct {
+	status billing.TransactionStatus
+	errMsg *string
+}
+
+func (s *Store) BeginTx(ctx context.Context) (billing.Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &memTx{
+		store:    s,
+		balances: make(map[uint64]int64),
+		versions: make(map[uint64]uint64),
+		inserted: make(map[uint64]*billing.Transaction),
+		statuses: make(map[uint64]statusUpdate),
+	}, nil
+}
+
+func (t *memTx) Commit() error {
+	s := t.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.done {
+		return sql.ErrTxDone
+	}
+
+	now := time.Now()
+	for id, bal := range t.balances {
+		acc := s.accounts[id]
+		acc.Balance = bal
+		acc.Version = t.versions[id]
+		acc.UpdatedAt = now
+	}
+	for id, txn := range t.inserted {
+		s.transactions[id] = txn
+		s.byRequest[txn.RequestID] = id
+	}
+	for id, u := range t.statuses {
+		txn := s.transactions[id]
+		txn.Status = u.status
+		txn.ErrorMessage = u.errMsg
+		txn.UpdatedAt = now
+	}
+
+	t.end()
+	return nil
+}
+
+func (t *memTx) Rollback() error {
+	s := t.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.end()
+	return nil
+}
+
+// end releases every lock. The caller holds the store's mutex.
+func (t *memTx) end() {
+	for _, key := range t.held {
+		close(t.store.locks[key].released)
+		delete(t.store.locks, key)
+	}
+	t.held = nil
+	t.waitingFor = nil
+	t.done = true
+}
+
+// lock takes the row lock for key, waiting for its holder to finish. On
+// return without error the store's mutex is held, so the caller can read
+// and write rows; it must unlock it.
+func (t *memTx) lock(ctx context.Context, key string) error {
+	s := t.store
+	var timer *time.Timer
+
+	for {
+		s.mu.Lock()
+		if t.done {
+			s.mu.Unlock()
+			return sql.ErrTxDone
+		}
+
+		l := s.locks[key]
+		if l == nil {
+			s.locks[key] = &rowLock{owner: t, released: make(chan struct{})}
+			t.held = append(t.held, key)
+		}
+		if l == nil || l.owner == t {
+			t.waitingFor = nil
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		}
+
+		// Waiting would close a cycle: InnoDB rolls back the requester.
+		for o := l.owner; o != nil; o = o.waitingFor {
+			if o == t {
+				t.end()
+				s.mu.Unlock()
+				return fmt.Errorf("lock on %s: %w", key, dberr.ErrDeadlock)
+			}
+		}
+
+		t.waitingFor = l.owner
+		released := l.released
+		if timer == nil {
+			timer = time.NewTimer(s.lockWait)
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-timer.C:
+			t.stopWaiting()
+			return fmt.Errorf("lock on %s: %w", key, dberr.ErrLockWaitTimeout)
+		case <-ctx.Done():
+			timer.Stop()
+			t.stopWaiting()
+			return ctx.Err()
+		}
+	}
+}
+
+func (t *memTx) stopWaiting() {
+	t.store.mu.Lock()
+	t.waitingFor = nil
+	t.store.mu.Unlock()
+}
+
+func (s *Store) txOf(tx billing.Tx) (*memTx, error) {
+	t, ok := tx.(*memTx)
+	if !ok || t.store != s {
+		return nil, billing.ErrForeignTx
+	}
+	return t, nil
+}
+
+func accountKey(id uint64) string        { return fmt.Sprintf("accounts/%d", id) }
+func transactionKey(id uint64) string    { return fmt.Sprintf("transactions/%d", id) }
+func requestKey(requestID string) string { return "transactions/request_id/" + requestID }
+
+// GetAccountForUpdate locks the account row until tx ends.
+func (s *Store) GetAccountForUpdate(ctx context.Context, tx billing.Tx, accountID uint64) (*billing.Account, error) {
+	t, err := s.txOf(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.lock(ctx, accountKey(accountID)); err != nil {
+		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, err)
+	}
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, billing.ErrAccountNotFound)
+	}
+	return t.account(acc), nil
+}
+
+// ReadAccount is a non-locking read, like GetAccountBalance.
+func (s *Store) ReadAccount(ctx context.Context, tx billing.Tx, accountID uint64) (*billing.Account, error) {
+	t, err := s.txOf(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return nil, fmt.Errorf("failed to fetch account %d: %w", accountID, billing.ErrAccountNotFound)
+	}
+	return t.account(acc), nil
+}
+
+// account returns a copy of acc as tx sees it. The caller holds the
+// store's mutex.
+func (t *memTx) account(acc *billing.Account) *billing.Account {
+	out := *acc
+	if bal, ok := t.balances[acc.ID]; ok {
+		out.Balance = bal
+		out.Version = t.versions[acc.ID]
+	}
+	return &out
+}
+
+// GetAccountBalance is a consistent, non-locking read: it sees committed
+// balances plus tx's own updates.
+func (s *Store) GetAccountBalance(ctx context.Context, tx billing.Tx, accountID uint64) (int64, error) {
+	t, err := s.txOf(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if bal, ok := t.balances[accountID]; ok {
+		return bal, nil
+	}
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return 0, fmt.Errorf("failed to fetch balance for account %d: %w", accountID, billing.ErrAccountNotFound)
+	}
+	return acc.Balance, nil
+}
+
+func (s *Store) UpdateAccountBalance(ctx context.Context, tx billing.Tx, accountID uint64, newBalance int64) error {
+	t, err := s.txOf(tx)
+	if err != nil {
+		return err
+	}
+	if err := t.lock(ctx, accountKey(accountID)); err != nil {
+		return fmt.Errorf("failed to update balance for account %d: %w", accountID, err)
+	}
+	defer s.mu.Unlock()
+
+	// Like an UPDATE matching no rows, a missing account is not an error.
+	if acc, ok := s.accounts[accountID]; ok {
+		t.setBalance(acc, newBalance)
+	}
+	return nil
+}
+
+// UpdateAccountBalanceAtVersion waits for the row lock like an UPDATE, then
+// compares against the latest version, including tx's own updates.
+func (s *Store) UpdateAccountBalanceAtVersion(ctx context.Context, tx billing.Tx, accountID uint64, newBalance int64, version uint64) (bool, error) {
+	t, err := s.txOf(tx)
+	if err != nil {
+		return false, err
+	}
+	if err := t.lock(ctx, accountKey(accountID)); err != nil {
+		return false, fmt.Errorf("failed to update balance for account %d: %w", accountID, err)
+	}
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok || t.account(acc).Version != version {
+		return false, nil
+	}
+	t.setBalance(acc, newBalance)
+	return true, nil
+}
+
+// setBalance buffers a balance update and bumps the version tx sees. The
+// caller holds the store's mutex and the row lock.
+func (t *memTx) setBalance(acc *billing.Account, newBalance int64) {
+	t.versions[acc.ID] = t.account(acc).Version + 1
+	t.balances[acc.ID] = newBalance
+}
+
+// FindTransactionByRequestID locks the request ID, even when no row exists
+// yet, the way InnoDB's next-key lock does for SELECT ... FOR UPDATE on a
+// unique index.
+func (s *Store) FindTransactionByRequestID(ctx context.Context, tx billing.Tx, requestID string) (*billing.Transaction, error) {
+	t, err := s.txOf(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.lock(ctx, requestKey(requestID)); err != nil {
+		return nil, fmt.Errorf("failed to find transaction: %w", err)
+	}
+	defer s.mu.Unlock()
+
+	for _, txn := range t.inserted {
+		if txn.RequestID == requestID {
+			return t.view(txn), nil
+		}
+	}
+	id, ok := s.byRequest[requestID]
+	if !ok {
+		return nil, billing.ErrTransactionNotFound
+	}
+	return t.view(s.transactions[id]), nil
+}
+
+func (s *Store) InsertTransaction(ctx context.Context, tx billing.Tx, txn *billing.Transaction) (uint64, error) {
+	t, err := s.txOf(tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.lock(ctx, requestKey(txn.RequestID)); err != nil {
+		return 0, fmt.Errorf("failed to insert transaction: %w", err)
+	}
+	defer s.mu.Unlock()
+
+	_, committed := s.byRequest[txn.RequestID]
+	for _, pending := range t.inserted {
+		committed = committed || pending.RequestID == txn.RequestID
+	}
+	if committed {
+		return 0, fmt.Errorf("failed to insert transaction %s: %w", txn.RequestID, dberr.ErrDuplicate)
+	}
+
+	// Like AUTO_INCREMENT, ids are not reused after a rollback.
+	s.nextTxnID++
+	now := time.Now()
+	row := *txn
+	row.ID = s.nextTxnID
+	row.CreatedAt = now
+	row.UpdatedAt = now
+	t.inserted[row.ID] = &row
+
+	key := transactionKey(row.ID)
+	s.locks[key] = &rowLock{owner: t, released: make(chan struct{})}
+	t.held = append(t.held, key)
+
+	return row.ID, nil
+}
+
+func (s *Store) UpdateTransactionStatus(ctx context.Context, tx billing.Tx, txnID uint64, status billing.TransactionStatus, errMsg *string) error {
+	t, err := s.txOf(tx)
+	if err != nil {
+		return err
+	}
+	if err := t.lock(ctx, transactionKey(txnID)); err != nil {
+		return fmt.Errorf("failed to update transaction status: %w", err)
+	}
+	defer s.mu.Unlock()
+
+	if txn, ok := t.inserted[txnID]; ok {
+		txn.Status = status
+		txn.ErrorMessage = errMsg
+		return nil
+	}
+	if _, ok := s.transactions[txnID]; ok {
+		t.statuses[txnID] = statusUpdate{status: status, errMsg: errMsg}
+	}
+	return nil
+}
+
+// view returns a copy of txn as tx sees it.
+func (t *memTx) view(txn *billing.Transaction) *billing.Transaction {
+	out := *txn
+	if u, ok := t.statuses[txn.ID]; ok {
+		out.Status = u.status
+		out.ErrorMessage = u.errMsg
+	}
+	return &out
+}
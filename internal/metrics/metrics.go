@@ -0,0 +1,190 @@
+// Package metrics exposes transfer, worker pool, HTTP and database metrics
+// in the Prometheus text format. Everything is registered on a private
+// registry, so the handler can be scraped without a Prometheus server and
+// nothing leaks into the global default registry.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/audit"
+	"gopherpay/internal/worker"
+)
+
+const namespace = "gopherpay"
+
+// latencyBuckets span a fast in-memory commit up to a transfer that sat in
+// the queue for a minute.
+var latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	transfers        *prometheus.CounterVec
+	transferDuration *prometheus.HistogramVec
+	stepDuration     *prometheus.HistogramVec
+	retries          prometheus.Counter
+	rejections       *prometheus.CounterVec
+	auditFailures    prometheus.Counter
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+}
+
+// New registers the metrics plus Go runtime, process and sql.DBStats
+// collectors for db. db may be nil when no database is in use.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		transfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfers_total",
+			Help:      "Processed transfers by outcome and error code.",
+		}, []string{"outcome", "error"}),
+
+		transferDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transfer_duration_seconds",
+			Help:      "Time from a transfer being queued to it finishing, by outcome.",
+			Buckets:   latencyBuckets,
+		}, []string{"outcome"}),
+
+		stepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transfer_step_duration_seconds",
+			Help:      "Time spent in each step of billing.Service.Transfer.",
+			Buckets:   latencyBuckets,
+		}, []string{"step"}),
+
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "transfer_retries_total",
+			Help:      "Transfer attempts retried after a transient database error or version conflict.",
+		}),
+
+		rejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queue_rejections_total",
+			Help:      "Transfers that could not be queued, by priority and reason.",
+		}, []string{"priority", "reason"}),
+
+		auditFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "audit_write_failures_total",
+			Help:      "Audit log entries that failed to be written.",
+		}),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route.",
+			Buckets:   latencyBuckets,
+		}, []string{"route", "method", "code"}),
+	}
+
+	m.registry.MustRegister(
+		m.transfers,
+		m.transferDuration,
+		m.stepDuration,
+		m.retries,
+		m.rejections,
+		m.auditFailures,
+		m.httpRequests,
+		m.httpDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+	}
+
+	return m
+}
+
+// WatchPool exports queue depth, capacity and worker gauges for pool,
+// sampled on every scrape.
+func (m *Metrics) WatchPool(pool *worker.Pool) {
+	m.registry.MustRegister(&poolCollector{pool: pool})
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveStep implements billing.Observer.
+func (m *Metrics) ObserveStep(step string, d time.Duration) {
+	m.stepDuration.WithLabelValues(step).Observe(d.Seconds())
+}
+
+// ObserveRetry implements billing.Observer.
+func (m *Metrics) ObserveRetry() {
+	m.retries.Inc()
+}
+
+// ObserveTransfer records a finished job; register it with
+// worker.Pool.OnComplete. The error label is the REST error code, so its
+// cardinality stays bounded.
+func (m *Metrics) ObserveTransfer(job worker.TransferJob, err error) {
+	outcome, code := "success", "none"
+	if err != nil {
+		_, c, _ := apierror.FromServiceError(err)
+		outcome, code = "failed", string(c)
+	}
+
+	m.transfers.WithLabelValues(outcome, code).Inc()
+	if !job.EnqueuedAt.IsZero() {
+		m.transferDuration.WithLabelValues(outcome).Observe(time.Since(job.EnqueuedAt).Seconds())
+	}
+}
+
+// ObserveRejection records a job Submit refused; register it with
+// worker.Pool.OnReject.
+func (m *Metrics) ObserveRejection(job worker.TransferJob, err error) {
+	reason := "unavailable"
+	if errors.Is(err, worker.ErrQueueFull) {
+		reason = "queue_full"
+	}
+	m.rejections.WithLabelValues(job.Priority.String(), reason).Inc()
+}
+
+// InstrumentRoute counts and times requests to one named route.
+func (m *Metrics) InstrumentRoute(route string, next http.Handler) http.Handler {
+	labels := prometheus.Labels{"route": route}
+	return promhttp.InstrumentHandlerDuration(m.httpDuration.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(m.httpRequests.MustCurryWith(labels), next))
+}
+
+// Audit wraps repo so failed writes are counted. Callers mostly ignore
+// audit errors, so this is the only place they become visible.
+func (m *Metrics) Audit(repo audit.Repository) audit.Repository {
+	return &countingAudit{Repository: repo, failures: m.auditFailures}
+}
+
+type countingAudit struct {
+	audit.Repository
+	failures prometheus.Counter
+}
+
+func (a *countingAudit) Log(ctx context.Context, entry *audit.AuditLog) error {
+	err := a.Repository.Log(ctx, entry)
+	if err != nil {
+		a.failures.Inc()
+	}
+	return err
+}
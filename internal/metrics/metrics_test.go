@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/memstore"
+	"gopherpay/internal/worker"
+)
+
+// scrape fetches the handler's text exposition and returns each sample by
+// its name and labels as written, e.g. `x_total{outcome="success"}`.
+func scrape(t *testing.T, h http.Handler) map[string]float64 {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d", rec.Code)
+	}
+
+	samples := make(map[string]float64)
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad sample %q: %v", line, err)
+		}
+		samples[line[:i]] = v
+	}
+	return samples
+}
+
+func TestTransferMetrics(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.DiscardHandler)
+
+	store := memstore.New()
+	from, _ := store.CreateAccount(ctx, 1000)
+	to, _ := store.CreateAccount(ctx, 0)
+
+	m := New(nil)
+
+	service := billing.NewService(store, m.Audit(store), logger)
+	service.SetObserver(m)
+
+	pool := worker.NewPool(worker.NewMemoryQueue(2), service, logger)
+	var wg sync.WaitGroup
+	pool.OnComplete(func(job worker.TransferJob, err error) {
+		m.ObserveTransfer(job, err)
+		wg.Done()
+	})
+	pool.OnReject(m.ObserveRejection)
+	m.WatchPool(pool)
+
+	// Submit goes through an instrumented route, as it does in the server.
+	submit := m.InstrumentRoute("transfers.create", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		amount, _ := strconv.ParseInt(r.URL.Query().Get("amount"), 10, 64)
+		job := worker.TransferJob{Request: billing.TransferRequest{
+			RequestID: r.URL.Query().Get("id"),
+			FromID:    from,
+			ToID:      to,
+			Amount:    amount,
+		}}
+		if err := pool.Submit(r.Context(), job); err != nil {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	wg.Add(2)
+	for _, q := range []string{"id=ok&amount=100", "id=short&amount=5000", "id=full&amount=1"} {
+		submit.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/transfers?"+q, nil))
+	}
+
+	queued := scrape(t, m.Handler())
+	if got := queued[`gopherpay_queue_depth{priority="all"}`]; got != 2 {
+		t.Errorf("queue depth = %v, want 2", got)
+	}
+	if got := queued[`gopherpay_queue_capacity`]; got != 2 {
+		t.Errorf("queue capacity = %v, want 2", got)
+	}
+
+	pool.Start(1)
+	wg.Wait()
+	pool.Shutdown(ctx)
+
+	samples := scrape(t, m.Handler())
+
+	want := map[string]float64{
+		`gopherpay_transfers_total{error="none",outcome="success"}`:                                        1,
+		`gopherpay_transfers_total{error="insufficient_funds",outcome="failed"}`:                           1,
+		`gopherpay_transfer_duration_seconds_count{outcome="success"}`:                                     1,
+		`gopherpay_transfer_duration_seconds_count{outcome="failed"}`:                                      1,
+		`gopherpay_transfer_duration_seconds_bucket{outcome="success",le="+Inf"}`:                          1,
+		`gopherpay_transfer_step_duration_seconds_count{step="request_lock"}`:                              2,
+		`gopherpay_transfer_step_duration_seconds_count{step="lock_wait"}`:                                 2,
+		`gopherpay_transfer_step_duration_seconds_count{step="balance_update"}`:                            1,
+		`gopherpay_transfer_step_duration_seconds_count{step="commit"}`:                                    2,
+		`gopherpay_queue_rejections_total{priority="normal",reason="queue_full"}`:                          1,
+		`gopherpay_http_requests_total{code="202",method="post",route="transfers.create"}`:                 2,
+		`gopherpay_http_requests_total{code="429",method="post",route="transfers.create"}`:                 1,
+		`gopherpay_http_request_duration_seconds_count{code="202",method="post",route="transfers.create"}`: 2,
+		`gopherpay_queue_depth{priority="all"}`:                                                            0,
+		`gopherpay_pool_abandoned_total`:                                                                   0,
+		`gopherpay_transfer_retries_total`:                                                                 0,
+		`gopherpay_audit_write_failures_total`:                                                             0,
+	}
+	for sample, v := range want {
+		got, ok := samples[sample]
+		if !ok {
+			t.Errorf("%s missing", sample)
+			continue
+		}
+		if got != v {
+			t.Errorf("%s = %v, want %v", sample, got, v)
+		}
+	}
+
+	// Bucket counts are cumulative, so every bucket of the success
+	// histogram holds 0 or 1 observations and they never decrease.
+	prev := 0.0
+	for _, le := range []string{"0.001", "0.01", "0.1", "1", "10", "60", "+Inf"} {
+		got := samples[`gopherpay_transfer_duration_seconds_bucket{outcome="success",le="`+le+`"}`]
+		if got < prev || got > 1 {
+			t.Errorf("bucket le=%s = %v after %v", le, got, prev)
+		}
+		prev = got
+	}
+}
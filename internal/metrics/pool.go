@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"gopherpay/internal/worker"
+)
+
+var (
+	queueDepthDesc = prometheus.NewDesc(namespace+"_queue_depth",
+		"Jobs waiting in the transfer queue, by priority lane.", []string{"priority"}, nil)
+	queueCapacityDesc = prometheus.NewDesc(namespace+"_queue_capacity",
+		"Total capacity of the transfer queue.", nil, nil)
+	workersDesc = prometheus.NewDesc(namespace+"_pool_workers",
+		"Current number of workers or lanes.", nil, nil)
+	inFlightDesc = prometheus.NewDesc(namespace+"_pool_in_flight",
+		"Transfers currently being processed.", nil, nil)
+	abandonedDesc = prometheus.NewDesc(namespace+"_pool_abandoned_total",
+		"Transfers failed without running (expired or aborted).", nil, nil)
+)
+
+// poolCollector samples worker.Pool.Stats at scrape time, so queue depth is
+// never stale and no background goroutine is needed.
+type poolCollector struct {
+	pool *worker.Pool
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- queueCapacityDesc
+	ch <- workersDesc
+	ch <- inFlightDesc
+	ch <- abandonedDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	stats, err := c.pool.Stats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(queueDepthDesc, err)
+		return
+	}
+
+	if stats.LaneDepths != nil {
+		for p, n := range stats.LaneDepths {
+			ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(n), p.String())
+		}
+	} else {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(stats.QueueDepth), "all")
+	}
+	ch <- prometheus.MustNewConstMetric(queueCapacityDesc, prometheus.GaugeValue, float64(stats.QueueCapacity))
+	ch <- prometheus.MustNewConstMetric(workersDesc, prometheus.GaugeValue, float64(stats.Workers))
+	ch <- prometheus.MustNewConstMetric(inFlightDesc, prometheus.GaugeValue, float64(stats.InFlight))
+	ch <- prometheus.MustNewConstMetric(abandonedDesc, prometheus.CounterValue, float64(stats.Abandoned))
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/auth"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// Authenticate resolves the caller to a principal and stores it in the
+// request context. Callers present either a JWT in the Authorization header
+// or an API key in X-API-Key. Requests without valid credentials are
+// rejected with 401.
+func Authenticate(authenticator *auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			principal, err := authenticator.Authenticate(r.Context(), r.Header.Get(APIKeyHeader), bearerToken(r))
+
+			switch {
+			case err == nil:
+			case errors.Is(err, auth.ErrMissingCredentials), errors.Is(err, auth.ErrInvalidCredentials):
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gopherpay"`)
+				writeError(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, err.Error())
+				return
+			case errors.Is(err, auth.ErrUnboundCredential):
+				writeError(w, r, http.StatusForbidden, apierror.CodeForbidden, err.Error())
+				return
+			default:
+				writeError(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "authentication unavailable")
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	return auth.BearerToken(r.Header.Get("Authorization"))
+}
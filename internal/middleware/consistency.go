@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gopherpay/internal/replica"
+)
+
+// ReadConsistencyHeader set to "primary" sends a request's reads to the
+// primary database rather than a replica, for clients that must see a
+// write they just made, such as polling the transfer they just submitted.
+const ReadConsistencyHeader = "X-Read-Consistency"
+
+func ReadConsistency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(ReadConsistencyHeader) == "primary" {
+			r = r.WithContext(replica.WithPrimary(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
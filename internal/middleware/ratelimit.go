@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/auth"
+	"gopherpay/internal/ratelimit"
+)
+
+// RateLimit enforces limit per authenticated subject (an API key or JWT
+// subject) on one route, so it should run inside Authenticate. Requests
+// without a principal are left to RateLimitIP. If the store fails the
+// request is let through rather than turning a limiter outage into an API
+// outage.
+func RateLimit(store ratelimit.Store, route string, limit ratelimit.Limit, logger *slog.Logger) func(http.Handler) http.Handler {
+	return rateLimit(store, limit, logger, func(r *http.Request) (string, bool) {
+		p, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			return "", false
+		}
+		return route + ":" + p.Subject, true
+	})
+}
+
+// RateLimitIP enforces limit per remote IP across every route it wraps. It
+// runs before Authenticate, so a client guessing credentials is throttled
+// before each guess costs a lookup.
+func RateLimitIP(store ratelimit.Store, limit ratelimit.Limit, logger *slog.Logger) func(http.Handler) http.Handler {
+	return rateLimit(store, limit, logger, func(r *http.Request) (string, bool) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return "ip:" + host, true
+	})
+}
+
+func rateLimit(store ratelimit.Store, limit ratelimit.Limit, logger *slog.Logger, clientKey func(*http.Request) (string, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			key, ok := clientKey(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := store.Take(r.Context(), key, limit)
+			if err != nil {
+				logger.Error("rate limiter unavailable",
+					"request_id", GetRequestID(r.Context()),
+					"error", err,
+				)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter.Seconds())))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter.Seconds())))
+				writeError(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ceilSeconds(s float64) int {
+	return int(math.Ceil(s))
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopherpay/internal/auth"
+	"gopherpay/internal/ratelimit"
+)
+
+// stubStore answers every Take with res and err.
+type stubStore struct {
+	res   ratelimit.Result
+	err   error
+	taken []string
+}
+
+func (s *stubStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	s.taken = append(s.taken, key)
+	return s.res, s.err
+}
+
+func TestRateLimit(t *testing.T) {
+	limit := ratelimit.Limit{Rate: 1, Burst: 5}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name           string
+		store          *stubStore
+		principal      bool
+		wantStatus     int
+		wantKey        string
+		wantRetryAfter string
+	}{
+		{
+			name:       "allowed",
+			store:      &stubStore{res: ratelimit.Result{Allowed: true, Limit: 5, Remaining: 4}},
+			principal:  true,
+			wantStatus: http.StatusNoContent,
+			wantKey:    "transfers.create:alice",
+		},
+		{
+			name:           "rejected",
+			store:          &stubStore{res: ratelimit.Result{Limit: 5, RetryAfter: 1500 * time.Millisecond}},
+			principal:      true,
+			wantStatus:     http.StatusTooManyRequests,
+			wantKey:        "transfers.create:alice",
+			wantRetryAfter: "2",
+		},
+		{
+			name:       "store down lets the request through",
+			store:      &stubStore{err: errors.New("connection refused")},
+			principal:  true,
+			wantStatus: http.StatusNoContent,
+			wantKey:    "transfers.create:alice",
+		},
+		{
+			name:       "no principal is left to the IP limit",
+			store:      &stubStore{res: ratelimit.Result{Limit: 5}},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := RateLimit(tt.store, "transfers.create", limit, slog.New(slog.DiscardHandler))(ok)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/transfers", nil)
+			if tt.principal {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "alice"}))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			switch {
+			case tt.wantKey == "" && len(tt.store.taken) > 0:
+				t.Errorf("took a token for %v", tt.store.taken)
+			case tt.wantKey != "" && (len(tt.store.taken) != 1 || tt.store.taken[0] != tt.wantKey):
+				t.Errorf("keys taken = %v, want [%s]", tt.store.taken, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestRateLimitIPKeysByHost(t *testing.T) {
+	store := &stubStore{res: ratelimit.Result{Allowed: true, Limit: 5}}
+	h := RateLimitIP(store, ratelimit.Limit{Rate: 1, Burst: 5}, slog.New(slog.DiscardHandler))(http.NotFoundHandler())
+
+	for _, addr := range []string{"192.0.2.7:1234", "192.0.2.7:5678"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/accounts", nil)
+		req.RemoteAddr = addr
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(store.taken) != 2 || store.taken[0] != "ip:192.0.2.7" || store.taken[1] != "ip:192.0.2.7" {
+		t.Fatalf("keys taken = %v, want both ports under ip:192.0.2.7", store.taken)
+	}
+}
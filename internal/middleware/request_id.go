@@ -4,7 +4,12 @@ import (
 	"context"
 	"net/http"
 
+	"gopherpay/internal/apierror"
+
 	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type contextKey string
@@ -20,15 +25,65 @@ func RequestID(next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), RequestIDKey, reqID)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request_id", reqID))
 		w.Header().Set("X-Request-ID", reqID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// Trace starts a server span named after route, continuing any trace
+// context sent by the caller. RequestID tags the span once it runs, so it
+// should be wrapped by Trace.
+func Trace(route string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, route)
+	}
+}
+
 func GetRequestID(ctx context.Context) string {
 	if val := ctx.Value(RequestIDKey); val != nil {
 		return val.(string)
 	}
 	return ""
 }
+
+// writeError sends the standard error envelope tagged with the request ID.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code apierror.Code, message string) {
+	apierror.Write(w, GetRequestID(r.Context()), status, code, message, nil)
+}
+
+// Deprecated marks a legacy route. Responses advertise the successor path so
+// clients can migrate before the alias is removed.
+func Deprecated(successor string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithRequestID stores a request ID for transports that do not go through
+// the RequestID middleware, such as gRPC.
+func WithRequestID(ctx context.Context, reqID string) context.Context {
+	return context.WithValue(ctx, RequestIDKey, reqID)
+}
+
+// RequestIDPropagator carries the request ID through the transfer queue so
+// worker logs and audit entries can be tied back to the original request.
+type RequestIDPropagator struct{}
+
+func (RequestIDPropagator) Inject(ctx context.Context, carrier map[string]string) {
+	if reqID := GetRequestID(ctx); reqID != "" {
+		carrier["request_id"] = reqID
+	}
+}
+
+func (RequestIDPropagator) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if reqID := carrier["request_id"]; reqID != "" {
+		return WithRequestID(ctx, reqID)
+	}
+	return ctx
+}
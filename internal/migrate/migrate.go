@@ -0,0 +1,474 @@
+// Package migrate applies the numbered schema migrations embedded in the
+// migrations package and records each one, with a checksum of its up file,
+// in schema_migrations. MySQL, Postgres and SQLite each have their own
+// set.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("applied migration no longer matches its file")
+	ErrUnknownVersion   = errors.New("database has a migration this build does not know")
+	ErrInvalidVersion   = errors.New("no such migration version")
+	ErrLocked           = errors.New("another migration is running")
+)
+
+// lockName serialises migrations across processes, e.g. several servers
+// started with auto-migrate at once.
+const lockName = "gopherpay_schema_migrations"
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 of Up, hex encoded
+}
+
+// Status is one migration and whether it has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Drift     bool // applied with a different checksum
+}
+
+type applied struct {
+	version   int
+	checksum  string
+	appliedAt time.Time
+}
+
+// Load reads NNN_name.up.sql / NNN_name.down.sql pairs from fsys, sorted by
+// version. Every version needs both files.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", e.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			sum := sha256.Sum256(body)
+			mig.Up = string(body)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %03d_%s needs both an up and a down file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Dialect is the kind of database being migrated.
+type Dialect int
+
+const (
+	MySQL Dialect = iota
+	Postgres
+	SQLite
+)
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// SetDialect selects the locking and SQL syntax of the database. The
+// default is MySQL.
+func (m *Migrator) SetDialect(d Dialect) {
+	m.dialect = d
+}
+
+// bind rewrites MySQL "?" placeholders as Postgres "$n" ones.
+func (m *Migrator) bind(query string) string {
+	if m.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Latest returns the highest known version, which a fully migrated
+// database is at.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if _, err := m.db.ExecContext(ctx, createTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	done, err := loadApplied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Migration: mig}
+		if a, ok := done[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.appliedAt
+			s.Drift = a.checksum != mig.Checksum
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Verify fails if an applied migration's file has changed since it ran, or
+// if the database has a version this build has no file for.
+func (m *Migrator) Verify(ctx context.Context) error {
+	if _, err := m.db.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	done, err := loadApplied(ctx, m.db)
+	if err != nil {
+		return err
+	}
+	return m.verify(done)
+}
+
+func (m *Migrator) verify(done map[int]applied) error {
+	for version, a := range done {
+		mig, ok := m.find(version)
+		if !ok {
+			return fmt.Errorf("version %d: %w", version, ErrUnknownVersion)
+		}
+		if a.checksum != mig.Checksum {
+			return fmt.Errorf("version %d (%s): %w", version, mig.Name, ErrChecksumMismatch)
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(done); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := done[m.migrations[i].Version]; ok {
+				ran = append(ran, m.migrations[i])
+				return m.revert(ctx, conn, m.migrations[i])
+			}
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// To migrates up or down until exactly the migrations numbered at most
+// target are applied. It returns the migrations it ran, in order.
+func (m *Migrator) To(ctx context.Context, target int) ([]Migration, error) {
+	if target != 0 {
+		if _, ok := m.find(target); !ok {
+			return nil, fmt.Errorf("version %d: %w", target, ErrInvalidVersion)
+		}
+	}
+
+	var ran []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := loadApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(done); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; ok && mig.Version > target {
+				ran = append(ran, mig)
+				if err := m.revert(ctx, conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; !ok && mig.Version <= target {
+				ran = append(ran, mig)
+				if err := m.apply(ctx, conn, mig); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Baseline records every migration up to version as applied without
+// running it, for databases that were migrated by hand before this runner
+// existed.
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	if _, ok := m.find(version); !ok {
+		return fmt.Errorf("version %d: %w", version, ErrInvalidVersion)
+	}
+
+	insert := `INSERT IGNORE INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`
+	switch m.dialect {
+	case Postgres:
+		insert = `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	case SQLite:
+		insert = `INSERT OR IGNORE INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			_, err := conn.ExecContext(ctx, insert, mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+			}
+		}
+		return nil
+	})
+}
+
+// withLock runs fn on one connection holding a named lock (a MySQL
+// GET_LOCK or a Postgres advisory lock), after making sure
+// schema_migrations exists. SQLite has no named locks; its connections
+// begin transactions with BEGIN IMMEDIATE, so each migration's transaction
+// holds the database write lock instead.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	switch m.dialect {
+	case Postgres:
+		lockCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		_, err := conn.ExecContext(lockCtx, `SELECT pg_advisory_lock(hashtext($1))`, lockName)
+		cancel()
+		if err != nil && ctx.Err() == nil && errors.Is(lockCtx.Err(), context.DeadlineExceeded) {
+			return ErrLocked
+		}
+		if err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock(hashtext($1))`, lockName)
+	case MySQL:
+		var got sql.NullInt64
+		if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 30)`, lockName).Scan(&got); err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		if got.Int64 != 1 {
+			return ErrLocked
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT RELEASE_LOCK(?)`, lockName)
+	}
+
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func loadApplied(ctx context.Context, q querier) (map[int]applied, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int]applied)
+	for rows.Next() {
+		var a applied
+		if err := rows.Scan(&a.version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		done[a.version] = a
+	}
+	return done, rows.Err()
+}
+
+// MySQL commits DDL implicitly, so a migration is not atomic there: if a
+// statement fails, the ones before it stay applied and the version is not
+// recorded. Keep migrations small. Postgres runs each migration and its
+// record in one transaction, as does SQLite.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	return m.run(ctx, conn, func(ex execer) error {
+		if err := execAll(ctx, ex, mig.Up); err != nil {
+			return fmt.Errorf("migration %03d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+		_, err := ex.ExecContext(ctx,
+			m.bind(`INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)`),
+			mig.Version, mig.Name, mig.Checksum)
+		if err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", mig.Version, err)
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	return m.run(ctx, conn, func(ex execer) error {
+		if err := execAll(ctx, ex, mig.Down); err != nil {
+			return fmt.Errorf("reverting %03d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+		_, err := ex.ExecContext(ctx, m.bind(`DELETE FROM schema_migrations WHERE version = ?`), mig.Version)
+		if err != nil {
+			return fmt.Errorf("failed to unrecord migration %d: %w", mig.Version, err)
+		}
+		return nil
+	})
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// run calls fn on conn, inside a transaction where DDL is transactional.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, fn func(ex execer) error) error {
+	if m.dialect == MySQL {
+		return fn(conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execAll(ctx context.Context, ex execer, script string) error {
+	for _, stmt := range Statements(script) {
+		if _, err := ex.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\n%s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// Statements splits a migration file into statements, since the driver
+// runs one per call. A statement ends at a line ending in ';'; lines
+// starting with "--" are dropped.
+func Statements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(cur.String()), ";"))
+			cur.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(cur.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
@@ -0,0 +1,187 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"gopherpay/internal/config"
+	"gopherpay/internal/migrate"
+)
+
+// testMigrations creates tables a, b and c in versions 1, 2 and 3.
+func testMigrations() fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, m := range []struct{ file, table string }{
+		{"001_create_a", "a"},
+		{"002_create_b", "b"},
+		{"003_create_c", "c"},
+	} {
+		fsys[m.file+".up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE " + m.table + " (id INTEGER PRIMARY KEY);\n")}
+		fsys[m.file+".down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE " + m.table + ";\n")}
+	}
+	return fsys
+}
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := config.OpenSQLite(config.StorageConfig{Path: filepath.Join(t.TempDir(), "migrate.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB, fsys fstest.MapFS) *migrate.Migrator {
+	t.Helper()
+
+	m, err := migrate.New(db, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.SetDialect(migrate.SQLite)
+	return m
+}
+
+func versions(migs []migrate.Migration) []int {
+	out := make([]int, len(migs))
+	for i, m := range migs {
+		out[i] = m.Version
+	}
+	return out
+}
+
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name IN ('a', 'b', 'c') ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestUpAndTo(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	m := newMigrator(t, db, testMigrations())
+
+	ran, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(ran); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("up ran %v, want [1 2 3]", got)
+	}
+
+	ran, err = m.To(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(ran); !reflect.DeepEqual(got, []int{3, 2}) {
+		t.Fatalf("to 1 ran %v, want [3 2]", got)
+	}
+	if got := tables(t, db); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("tables after to 1 = %v, want [a]", got)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ran, err = m.To(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(ran); !reflect.DeepEqual(got, []int{3, 2, 1}) {
+		t.Fatalf("to 0 ran %v, want [3 2 1]", got)
+	}
+	if got := tables(t, db); len(got) != 0 {
+		t.Fatalf("tables after to 0 = %v, want none", got)
+	}
+
+	if _, err := m.To(ctx, 7); !errors.Is(err, migrate.ErrInvalidVersion) {
+		t.Fatalf("to 7: err = %v, want ErrInvalidVersion", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		edit    func(fsys fstest.MapFS)
+		wantErr error
+	}{
+		{"unchanged", func(fstest.MapFS) {}, nil},
+		{"edited up file", func(fsys fstest.MapFS) {
+			fsys["002_create_b.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE b (id INTEGER PRIMARY KEY, note TEXT);\n")}
+		}, migrate.ErrChecksumMismatch},
+		{"edited down file", func(fsys fstest.MapFS) {
+			fsys["002_create_b.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE IF EXISTS b;\n")}
+		}, nil},
+		{"missing applied version", func(fsys fstest.MapFS) {
+			delete(fsys, "003_create_c.up.sql")
+			delete(fsys, "003_create_c.down.sql")
+		}, migrate.ErrUnknownVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openDB(t)
+			if _, err := newMigrator(t, db, testMigrations()).Up(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			fsys := testMigrations()
+			tt.edit(fsys)
+			m := newMigrator(t, db, fsys)
+
+			if err := m.Verify(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify: err = %v, want %v", err, tt.wantErr)
+			}
+			// Migrating refuses to run against a drifted database too.
+			if _, err := m.To(ctx, 1); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("to 1: err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"one", "CREATE TABLE a (id INT);\n", []string{"CREATE TABLE a (id INT)"}},
+		{"several", "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"multi-line", "CREATE TABLE a (\n    id INT\n);\n", []string{"CREATE TABLE a (\n    id INT\n)"}},
+		{"comments and blank lines", "-- create a\n\nCREATE TABLE a (id INT);\n  -- done\n", []string{"CREATE TABLE a (id INT)"}},
+		{"no trailing semicolon", "CREATE TABLE a (id INT);\nDROP TABLE b", []string{"CREATE TABLE a (id INT)", "DROP TABLE b"}},
+		{"empty", "-- nothing\n\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := migrate.Statements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
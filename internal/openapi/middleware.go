@@ -0,0 +1,90 @@
+package openapi
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"gopherpay/internal/apierror"
+	"gopherpay/internal/middleware"
+)
+
+const maxBodyBytes = 1 << 20
+
+// ValidateRequests rejects requests whose path parameters or JSON body do
+// not match op, before they reach the handler. The body is buffered and
+// replaced so the handler can decode it again.
+func ValidateRequests(spec *Spec, op *Operation) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			problems := spec.ValidateParams(op, r.PathValue)
+
+			if op.RequestBody != nil {
+				body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+				if err != nil {
+					apierror.Write(w, middleware.GetRequestID(r.Context()), http.StatusBadRequest,
+						apierror.CodeInvalidRequest, "failed to read request body", nil)
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+
+				problems = append(problems, spec.ValidateRequestBody(op, body)...)
+			}
+
+			if len(problems) > 0 {
+				apierror.Write(w, middleware.GetRequestID(r.Context()), http.StatusBadRequest,
+					apierror.CodeInvalidRequest, "request does not match the api schema", problems)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// ValidateResponses logs responses that drift from op. It copies every body
+// so it is meant for development and staging rather than production.
+func ValidateResponses(spec *Spec, op *Operation, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if problems := spec.ValidateResponse(op, rec.status, rec.body.Bytes()); len(problems) > 0 {
+				logger.Error("response does not match openapi spec",
+					"request_id", middleware.GetRequestID(r.Context()),
+					"operation", op.OperationID,
+					"status", rec.status,
+					"problems", problems,
+				)
+			}
+		})
+	}
+}
+
+// Handler serves the raw document.
+func Handler(raw []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(raw)
+	})
+}
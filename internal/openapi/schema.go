@@ -0,0 +1,160 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema (as used by OpenAPI 3.1) that the
+// GopherPay spec relies on.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 schemaType         `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+}
+
+// schemaType accepts both "type": "string" and "type": ["string", "null"].
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+// FieldError describes one schema violation. Field is a JSON pointer style
+// path such as "/amount".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type validator struct {
+	spec   *Spec
+	errors []FieldError
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	if path == "" {
+		path = "/"
+	}
+	v.errors = append(v.errors, FieldError{Field: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate checks value, as decoded by encoding/json with UseNumber, against s.
+func (v *validator) validate(s *Schema, value any, path string) {
+	s = v.spec.resolve(s)
+	if s == nil {
+		return
+	}
+
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return matchesType(t, value) }) {
+		v.fail(path, "must be of type %s", strings.Join(s.Type, " or "))
+		return
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		v.fail(path, "must be one of %v", s.Enum)
+	}
+
+	switch val := value.(type) {
+	case json.Number:
+		n, _ := val.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			v.fail(path, "must be >= %v", *s.Minimum)
+		}
+		if s.ExclusiveMinimum != nil && n <= *s.ExclusiveMinimum {
+			v.fail(path, "must be > %v", *s.ExclusiveMinimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			v.fail(path, "must be <= %v", *s.Maximum)
+		}
+
+	case string:
+		if s.MinLength != nil && len(val) < *s.MinLength {
+			v.fail(path, "must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(val) > *s.MaxLength {
+			v.fail(path, "must be at most %d characters", *s.MaxLength)
+		}
+
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				v.fail(path+"/"+name, "is required")
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(val)) {
+			child := val[name]
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					v.fail(path+"/"+name, "is not allowed")
+				}
+				continue
+			}
+			v.validate(prop, child, path+"/"+name)
+		}
+
+	case []any:
+		if s.Items != nil {
+			for i, item := range val {
+				v.validate(s.Items, item, fmt.Sprintf("%s/%d", path, i))
+			}
+		}
+	}
+}
+
+func matchesType(t string, value any) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f) && !strings.ContainsAny(n.String(), ".eE")
+	}
+	return false
+}
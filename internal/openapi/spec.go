@@ -0,0 +1,206 @@
+// Package openapi loads an OpenAPI 3.1 document and validates HTTP traffic
+// against it.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Spec struct {
+	OpenAPI    string                          `json:"openapi"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Parameters  []Parameter         `json:"parameters"`
+	RequestBody *RequestBody        `json:"requestBody"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+const jsonMediaType = "application/json"
+
+func Load(raw []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("invalid openapi document: %w", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.1") {
+		return nil, fmt.Errorf("unsupported openapi version %q", spec.OpenAPI)
+	}
+	return &spec, nil
+}
+
+// Operation returns the operation for a method and a path template such as
+// "/v1/accounts/{id}", which is also the net/http pattern syntax.
+func (s *Spec) Operation(method, path string) (*Operation, bool) {
+	op, ok := s.Paths[path][strings.ToLower(method)]
+	if !ok {
+		return nil, false
+	}
+	return &op, true
+}
+
+// CheckRoutes compares "METHOD /path" patterns served by the router with the
+// operations documented in the spec and reports any mismatch in either
+// direction.
+func (s *Spec) CheckRoutes(patterns []string) error {
+	served := make(map[string]bool, len(patterns))
+	for _, p := range patterns {
+		served[p] = true
+	}
+
+	var problems []string
+	for _, p := range patterns {
+		method, path, _ := strings.Cut(p, " ")
+		if _, ok := s.Operation(method, path); !ok {
+			problems = append(problems, "undocumented route "+p)
+		}
+	}
+	for path, ops := range s.Paths {
+		for method := range ops {
+			p := strings.ToUpper(method) + " " + path
+			if !served[p] {
+				problems = append(problems, "documented route not served "+p)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("openapi spec drift: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+		if !ok {
+			return nil
+		}
+		schema = s.Components.Schemas[name]
+	}
+	return schema
+}
+
+// ValidateParams checks path parameters resolved by the router.
+func (s *Spec) ValidateParams(op *Operation, pathValue func(string) string) []FieldError {
+	v := &validator{spec: s}
+
+	for _, p := range op.Parameters {
+		if p.In != "path" {
+			continue
+		}
+
+		raw := pathValue(p.Name)
+		var value any = raw
+
+		if schema := s.resolve(p.Schema); schema != nil && len(schema.Type) == 1 && schema.Type[0] == "integer" {
+			if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+				if _, err := strconv.ParseUint(raw, 10, 64); err != nil {
+					v.fail("/"+p.Name, "must be of type integer")
+					continue
+				}
+			}
+			value = json.Number(raw)
+		}
+
+		v.validate(p.Schema, value, "/"+p.Name)
+	}
+
+	return v.errors
+}
+
+// ValidateRequestBody checks a JSON request body.
+func (s *Spec) ValidateRequestBody(op *Operation, body []byte) []FieldError {
+	if op.RequestBody == nil {
+		return nil
+	}
+
+	v := &validator{spec: s}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			v.fail("/", "request body is required")
+		}
+		return v.errors
+	}
+
+	value, err := decode(body)
+	if err != nil {
+		v.fail("/", "invalid json: %v", err)
+		return v.errors
+	}
+
+	v.validate(op.RequestBody.Content[jsonMediaType].Schema, value, "")
+	return v.errors
+}
+
+// ValidateResponse checks a JSON response body against the documented
+// response for status, falling back to the "default" response.
+func (s *Spec) ValidateResponse(op *Operation, status int, body []byte) []FieldError {
+	v := &validator{spec: s}
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		v.fail("/", "undocumented status %d", status)
+		return v.errors
+	}
+
+	media, ok := resp.Content[jsonMediaType]
+	if !ok {
+		return nil
+	}
+
+	value, err := decode(body)
+	if err != nil {
+		v.fail("/", "invalid json: %v", err)
+		return v.errors
+	}
+
+	v.validate(media.Schema, value, "")
+	return v.errors
+}
+
+func decode(body []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit describes a token bucket: Burst tokens at most, refilled at Rate
+// tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking one token from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token is available, zero if allowed
+}
+
+// Store keeps token buckets keyed by client. Take must be atomic per key so
+// the same Store can be shared by many goroutines, or many nodes in the case
+// of a networked implementation.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// bucketState computes a token bucket transition. It is shared by the
+// in-memory store and mirrors the Lua script used by RedisStore.
+func bucketState(tokens float64, elapsed time.Duration, limit Limit) (Result, float64) {
+	burst := float64(limit.Burst)
+
+	tokens += elapsed.Seconds() * limit.Rate
+	if tokens > burst {
+		tokens = burst
+	}
+
+	res := Result{Limit: limit.Burst}
+
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+
+	res.Remaining = int(tokens)
+	res.ResetAfter = secondsToDuration((burst - tokens) / limit.Rate)
+
+	return res, tokens
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
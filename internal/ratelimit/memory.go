@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryStore is a single-node Store. Buckets idle for longer than idleTTL
+// are evicted lazily.
+type MemoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	idleTTL  time.Duration
+	lastScan time.Time
+	now      func() time.Time
+}
+
+func NewMemoryStore(idleTTL time.Duration) *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		idleTTL: idleTTL,
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.evictIdle(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	res, tokens := bucketState(b.tokens, now.Sub(b.last), limit)
+	b.tokens = tokens
+	b.last = now
+
+	return res, nil
+}
+
+// evictIdle must be called with s.mu held.
+func (s *MemoryStore) evictIdle(now time.Time) {
+	if now.Sub(s.lastScan) < s.idleTTL {
+		return
+	}
+	s.lastScan = now
+
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > s.idleTTL {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock is a MemoryStore clock the test moves by hand.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore(idleTTL time.Duration) (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore(idleTTL)
+	s.now = clock.now
+	return s, clock
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 3} // a token every 500ms
+
+	type take struct {
+		after         time.Duration // clock advance before the take
+		key           string
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{"burst then reject", []take{
+			{0, "a", true, 2, 0},
+			{0, "a", true, 1, 0},
+			{0, "a", true, 0, 0},
+			{0, "a", false, 0, 500 * time.Millisecond},
+		}},
+		{"partial refill", []take{
+			{0, "a", true, 2, 0},
+			{0, "a", true, 1, 0},
+			{0, "a", true, 0, 0},
+			{250 * time.Millisecond, "a", false, 0, 250 * time.Millisecond},
+			{250 * time.Millisecond, "a", true, 0, 0},
+		}},
+		{"refill is capped at burst", []take{
+			{0, "a", true, 2, 0},
+			{time.Hour, "a", true, 2, 0},
+			{0, "a", true, 1, 0},
+		}},
+		{"keys are isolated", []take{
+			{0, "a", true, 2, 0},
+			{0, "a", true, 1, 0},
+			{0, "a", true, 0, 0},
+			{0, "a", false, 0, 500 * time.Millisecond},
+			{0, "b", true, 2, 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock := newTestStore(time.Hour * 24)
+			for i, tk := range tt.takes {
+				clock.advance(tk.after)
+				res, err := s.Take(context.Background(), tk.key, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.Allowed != tk.wantAllowed || res.Remaining != tk.wantRemaining || res.RetryAfter != tk.wantRetry {
+					t.Fatalf("take %d (%s): got allowed=%v remaining=%d retry=%v, want %v %d %v",
+						i, tk.key, res.Allowed, res.Remaining, res.RetryAfter, tk.wantAllowed, tk.wantRemaining, tk.wantRetry)
+				}
+				if res.Limit != limit.Burst {
+					t.Fatalf("take %d: limit = %d, want %d", i, res.Limit, limit.Burst)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreResetAfter(t *testing.T) {
+	s, _ := newTestStore(time.Hour)
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for range 3 {
+		s.Take(context.Background(), "a", limit)
+	}
+	res, _ := s.Take(context.Background(), "a", limit)
+	if res.ResetAfter != 1500*time.Millisecond {
+		t.Fatalf("reset after = %v, want 1.5s for three tokens at 2/s", res.ResetAfter)
+	}
+}
+
+func TestMemoryStoreEvictsIdleBuckets(t *testing.T) {
+	s, clock := newTestStore(time.Minute)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	s.Take(context.Background(), "idle", limit)
+	clock.advance(30 * time.Second)
+	s.Take(context.Background(), "busy", limit)
+
+	clock.advance(45 * time.Second) // idle is 75s old, busy 45s
+	s.Take(context.Background(), "other", limit)
+
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("idle bucket was not evicted")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("recent bucket was evicted")
+	}
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RedisClient is the subset of a Redis client used by RedisStore. It is
+// satisfied by a thin adapter over go-redis (client.Eval(...).Result()) or
+// any server speaking the Redis scripting commands.
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// tokenBucketScript performs the same transition as bucketState atomically
+// on the server. It returns {allowed, remaining, reset_ms, retry_ms}.
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - last) / 1000 * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate * 1000)
+end
+
+local reset = math.ceil((burst - tokens) / rate * 1000)
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'last', now)
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+
+return {allowed, math.floor(tokens), reset, retry}
+`
+
+// RedisStore shares buckets between nodes through Redis.
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := s.client.Eval(ctx, tokenBucketScript, []string{s.prefix + key},
+		strconv.FormatFloat(limit.Rate, 'f', -1, 64),
+		limit.Burst,
+		time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit script failed: %w", err)
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != 4 {
+		return Result{}, fmt.Errorf("unexpected rate limit reply %T", reply)
+	}
+
+	ints := make([]int64, len(values))
+	for i, v := range values {
+		n, ok := v.(int64)
+		if !ok {
+			return Result{}, fmt.Errorf("unexpected rate limit reply element %T", v)
+		}
+		ints[i] = n
+	}
+
+	return Result{
+		Allowed:    ints[0] == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(ints[1]),
+		ResetAfter: time.Duration(ints[2]) * time.Millisecond,
+		RetryAfter: time.Duration(ints[3]) * time.Millisecond,
+	}, nil
+}
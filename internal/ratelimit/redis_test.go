@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis returns a canned Eval reply and records the call.
+type fakeRedis struct {
+	reply any
+	err   error
+
+	keys []string
+	args []any
+}
+
+func (f *fakeRedis) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+	f.keys, f.args = keys, args
+	return f.reply, f.err
+}
+
+func TestRedisStoreTake(t *testing.T) {
+	limit := Limit{Rate: 2.5, Burst: 10}
+
+	tests := []struct {
+		name    string
+		reply   any
+		err     error
+		want    Result
+		wantErr string
+	}{
+		{
+			name:  "allowed",
+			reply: []any{int64(1), int64(9), int64(400), int64(0)},
+			want:  Result{Allowed: true, Limit: 10, Remaining: 9, ResetAfter: 400 * time.Millisecond},
+		},
+		{
+			name:  "rejected",
+			reply: []any{int64(0), int64(0), int64(4000), int64(250)},
+			want:  Result{Limit: 10, ResetAfter: 4 * time.Second, RetryAfter: 250 * time.Millisecond},
+		},
+		{name: "script error", err: errors.New("NOSCRIPT"), wantErr: "rate limit script failed: NOSCRIPT"},
+		{name: "not an array", reply: "OK", wantErr: "unexpected rate limit reply string"},
+		{name: "short array", reply: []any{int64(1), int64(9)}, wantErr: "unexpected rate limit reply []interface {}"},
+		{name: "non-integer element", reply: []any{int64(1), "9", int64(400), int64(0)}, wantErr: "unexpected rate limit reply element string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeRedis{reply: tt.reply, err: tt.err}
+			s := NewRedisStore(client, "rl:")
+
+			got, err := s.Take(context.Background(), "ip:10.0.0.1", limit)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+
+			if len(client.keys) != 1 || client.keys[0] != "rl:ip:10.0.0.1" {
+				t.Errorf("keys = %v, want the prefixed client key", client.keys)
+			}
+			if client.args[0] != "2.5" || client.args[1] != 10 {
+				t.Errorf("args = %v, want rate 2.5 and burst 10", client.args)
+			}
+		})
+	}
+}
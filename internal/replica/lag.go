@@ -0,0 +1,101 @@
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrNotReplicating means the server is not applying changes from a
+// primary: it is a primary itself, or replication has stopped.
+var ErrNotReplicating = errors.New("not replicating")
+
+// MySQLLag reads Seconds_Behind_Source from SHOW REPLICA STATUS, falling
+// back to SHOW SLAVE STATUS and Seconds_Behind_Master on servers older
+// than 8.0.22.
+func MySQLLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	status, err := showStatus(ctx, db, `SHOW REPLICA STATUS`)
+	if err != nil {
+		var fallbackErr error
+		status, fallbackErr = showStatus(ctx, db, `SHOW SLAVE STATUS`)
+		if fallbackErr != nil {
+			return 0, fmt.Errorf("failed to read replica status: %w", err)
+		}
+	}
+	if status == nil {
+		return 0, ErrNotReplicating
+	}
+
+	seconds, ok := status["Seconds_Behind_Source"]
+	if !ok {
+		seconds = status["Seconds_Behind_Master"]
+	}
+	// NULL while the SQL or I/O thread is stopped.
+	if !seconds.Valid {
+		return 0, ErrNotReplicating
+	}
+	n, err := strconv.ParseInt(seconds.String, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected replication lag %q", seconds.String)
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+// showStatus returns the single row of a SHOW ... STATUS statement by
+// column name, or nil if it returned no row. Its columns vary between
+// versions, so they are read by name.
+func showStatus(ctx context.Context, db *sql.DB, query string) (map[string]sql.NullString, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]sql.NullString, len(cols))
+	for i, col := range cols {
+		status[col] = values[i]
+	}
+	return status, nil
+}
+
+// PostgresLag measures how long ago the standby replayed its last
+// transaction. A standby that has replayed everything it received counts
+// as current, so an idle primary does not look like lag.
+func PostgresLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	query := `
+        SELECT pg_is_in_recovery(),
+               CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+                    ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+               END
+    `
+
+	var recovering bool
+	var seconds float64
+	if err := db.QueryRowContext(ctx, query).Scan(&recovering, &seconds); err != nil {
+		return 0, fmt.Errorf("failed to read replica status: %w", err)
+	}
+	if !recovering {
+		return 0, ErrNotReplicating
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,225 @@
+// Package replica routes read-only queries to read replicas of the primary
+// database. A background check takes a replica out of rotation while it is
+// unreachable or lagging more than the configured limit, and reads go to
+// the primary whenever no replica is healthy, so the replicas are never
+// needed for correctness.
+//
+// Replicas trail the primary, so a read routed to one may not see a write
+// that has just committed. Reads that must, such as a client polling the
+// transfer it just submitted, can be forced to the primary with
+// WithPrimary. A sequence of reads that must agree with each other can be
+// kept on one database with Pin.
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Querier runs read-only queries. *sql.DB and *Set both implement it.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// LagFunc reports how far db, a replica, is behind its primary.
+type LagFunc func(ctx context.Context, db *sql.DB) (time.Duration, error)
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryOnly reports whether ctx was marked by WithPrimary.
+func PrimaryOnly(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
+
+type pinKey struct{}
+
+type pin struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+// Pin returns a context whose reads all go to the database the first of
+// them is routed to, so a later query never lands on a replica that is
+// further behind than the one an earlier query saw.
+func Pin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinKey{}, &pin{})
+}
+
+// Status is the outcome of a replica's most recent check.
+type Status struct {
+	Name      string
+	Healthy   bool
+	Lag       time.Duration
+	Err       error
+	CheckedAt time.Time
+}
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Set is a primary and its replicas. Replicas start out of rotation until
+// their first check passes.
+type Set struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+
+	lag    LagFunc
+	maxLag time.Duration
+}
+
+func New(primary *sql.DB) *Set {
+	return &Set{primary: primary}
+}
+
+// Add registers a replica under name, typically its address. Replicas are
+// added at startup, before the set is used.
+func (s *Set) Add(name string, db *sql.DB) {
+	r := &replica{name: name, db: db}
+	r.status = Status{Name: name, Err: errors.New("not checked yet")}
+	s.replicas = append(s.replicas, r)
+}
+
+// SetLagCheck makes checks fail a replica that lag reports as more than
+// max behind. Without it, a replica only has to answer a ping.
+func (s *Set) SetLagCheck(lag LagFunc, max time.Duration) {
+	s.lag = lag
+	s.maxLag = max
+}
+
+// Len returns the number of replicas.
+func (s *Set) Len() int {
+	return len(s.replicas)
+}
+
+// DB returns the pool a read under ctx should use: the next healthy
+// replica in turn, or the primary if ctx is marked by WithPrimary or no
+// replica is healthy. Under a context from Pin, every call returns the
+// database the first one picked.
+func (s *Set) DB(ctx context.Context) *sql.DB {
+	if p, ok := ctx.Value(pinKey{}).(*pin); ok {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.db == nil {
+			p.db = s.pick(ctx)
+		}
+		return p.db
+	}
+	return s.pick(ctx)
+}
+
+func (s *Set) pick(ctx context.Context) *sql.DB {
+	if len(s.replicas) == 0 || PrimaryOnly(ctx) {
+		return s.primary
+	}
+
+	start := s.next.Add(1)
+	for i := range s.replicas {
+		r := s.replicas[(start+uint64(i))%uint64(len(s.replicas))]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+	return s.primary
+}
+
+func (s *Set) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return s.DB(ctx).QueryContext(ctx, query, args...)
+}
+
+func (s *Set) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return s.DB(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// Check checks every replica once, concurrently, and updates which are in
+// rotation.
+func (s *Set) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.check(ctx, r)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Set) check(ctx context.Context, r *replica) {
+	st := Status{Name: r.name, CheckedAt: time.Now()}
+
+	st.Err = r.db.PingContext(ctx)
+	if st.Err == nil && s.lag != nil {
+		st.Lag, st.Err = s.lag(ctx, r.db)
+		if st.Err == nil && st.Lag > s.maxLag {
+			st.Err = fmt.Errorf("replica is %s behind, limit %s", st.Lag.Round(time.Second), s.maxLag)
+		}
+	}
+	st.Healthy = st.Err == nil
+
+	r.mu.Lock()
+	r.status = st
+	r.mu.Unlock()
+	r.healthy.Store(st.Healthy)
+}
+
+// Run checks the replicas every interval, each check bounded by the
+// interval, until ctx is done. The first check runs immediately.
+func (s *Set) Run(ctx context.Context, interval time.Duration) {
+	if len(s.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		s.Check(checkCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Statuses returns the latest check of every replica, in the order they
+// were added.
+func (s *Set) Statuses() []Status {
+	out := make([]Status, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		r.mu.Lock()
+		out = append(out, r.status)
+		r.mu.Unlock()
+	}
+	return out
+}
+
+// Close closes the replicas' pools. The primary is left to its owner.
+func (s *Set) Close() error {
+	var errs []error
+	for _, r := range s.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
+
+	"gopherpay/internal/replica"
 )
 
-// TransactionDetail combines transaction, account, and audit data
+// TransactionDetail combines transaction and account data
 type TransactionDetail struct {
 	TransactionID uint64
 	RequestID     string
@@ -18,7 +22,6 @@ type TransactionDetail struct {
 	FromBalance   int64
 	ToBalance     int64
 	CreatedAt     string
-	AuditLogs     []AuditEntry
 }
 
 type Summary struct {
@@ -34,18 +37,93 @@ type Summary struct {
 }
 
 type AuditEntry struct {
+	RequestID string
 	Action    string
 	Status    string
 	Message   *string
 	Timestamp string
 }
 
-// GetUserTransactionsWithAudit fetches transactions with account info and audit logs for a user
-func GetUserTransactionsWithAudit(ctx context.Context, db *sql.DB, userID uint64) ([]TransactionDetail, error) {
+// Dialect is the kind of database a report reads. The queries are written
+// with ? placeholders and rebound for Postgres.
+type Dialect int
+
+const (
+	MySQL Dialect = iota
+	Postgres
+	SQLite
+)
+
+// rebind rewrites the ? placeholders of query for d.
+func (d Dialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// timeArg binds t so it compares correctly with created_at, which SQLite
+// stores as CURRENT_TIMESTAMP text.
+func (d Dialect) timeArg(t time.Time) any {
+	if d == SQLite {
+		return t.UTC().Format("2006-01-02 15:04:05")
+	}
+	return t.UTC()
+}
+
+// Filter selects the transactions a report covers: those to or from
+// AccountID created in [From, To) with one of Statuses. A zero From or To
+// leaves that end open, and no Statuses means any status.
+type Filter struct {
+	AccountID uint64
+	From      time.Time
+	To        time.Time
+	Statuses  []string
+}
+
+// where returns the condition on transactions aliased t matching f, and
+// its arguments.
+func (f Filter) where(d Dialect) (string, []any) {
+	conds := []string{"(t.from_account_id = ? OR t.to_account_id = ?)"}
+	args := []any{f.AccountID, f.AccountID}
+
+	if !f.From.IsZero() {
+		conds = append(conds, "t.created_at >= ?")
+		args = append(args, d.timeArg(f.From))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "t.created_at < ?")
+		args = append(args, d.timeArg(f.To))
+	}
+	if len(f.Statuses) > 0 {
+		conds = append(conds, "t.status IN (?"+strings.Repeat(", ?", len(f.Statuses)-1)+")")
+		for _, s := range f.Statuses {
+			args = append(args, s)
+		}
+	}
+
+	return strings.Join(conds, " AND "), args
+}
 
-	// Step 1: Get all transactions for user (as sender or receiver)
+// ForEachTransaction calls fn with each transaction matching f, newest
+// first, reading rows as fn consumes them. It stops at the first error fn
+// returns.
+func ForEachTransaction(ctx context.Context, db replica.Querier, d Dialect, f Filter, fn func(TransactionDetail) error) error {
+
+	where, args := f.where(d)
 	txnQuery := `
-        SELECT 
+        SELECT
             t.id,
             t.request_id,
             t.from_account_id,
@@ -53,103 +131,93 @@ func GetUserTransactionsWithAudit(ctx context.Context, db *sql.DB, userID uint64
             t.amount,
             t.status,
             t.error_message,
-			t.from_balance,
-			t.to_balance,
+            t.from_balance,
+            t.to_balance,
             t.created_at
         FROM transactions t
-        WHERE t.from_account_id = ? OR t.to_account_id = ?
-        ORDER BY t.created_at DESC
+        WHERE ` + where + `
+        ORDER BY t.created_at DESC, t.id DESC
     `
 
-	rows, err := db.QueryContext(ctx, txnQuery, userID, userID)
+	rows, err := db.QueryContext(ctx, d.rebind(txnQuery), args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query transactions: %w", err)
+		return fmt.Errorf("failed to query transactions: %w", err)
 	}
 	defer rows.Close()
 
-	var details []TransactionDetail
-
 	for rows.Next() {
 		var (
-			txnID     uint64
-			requestID string
-			fromID    uint64
-			toID      uint64
-			amount    int64
-			status    string
-			errorMsg  sql.NullString
-			fromBal   sql.NullInt64
-			toBal     sql.NullInt64
-			createdAt string
+			detail   TransactionDetail
+			errorMsg sql.NullString
+			fromBal  sql.NullInt64
+			toBal    sql.NullInt64
 		)
 
 		if err := rows.Scan(
-			&txnID,
-			&requestID,
-			&fromID,
-			&toID,
-			&amount,
-			&status,
+			&detail.TransactionID,
+			&detail.RequestID,
+			&detail.FromAccountID,
+			&detail.ToAccountID,
+			&detail.Amount,
+			&detail.Status,
 			&errorMsg,
 			&fromBal,
 			&toBal,
-			&createdAt,
+			&detail.CreatedAt,
 		); err != nil {
-			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+			return fmt.Errorf("failed to scan transaction: %w", err)
 		}
 
-		// ---- fetch audit logs ----
-		auditQuery := `
-			SELECT action, status, message, created_at
-			FROM audit_logs
-			WHERE request_id = ?
-			ORDER BY created_at ASC
-		`
-
-		auditRows, err := db.QueryContext(ctx, auditQuery, requestID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query audit logs: %w", err)
+		detail.ErrorMessage = nullStringToPtr(errorMsg)
+		detail.FromBalance = fromBal.Int64
+		detail.ToBalance = toBal.Int64
+
+		if err := fn(detail); err != nil {
+			return err
 		}
+	}
+
+	return rows.Err()
+}
 
-		var audits []AuditEntry
-		for auditRows.Next() {
-			var (
-				action    string
-				auditStat string
-				message   sql.NullString
-				timestamp string
-			)
-
-			if err := auditRows.Scan(&action, &auditStat, &message, &timestamp); err != nil {
-				auditRows.Close()
-				return nil, fmt.Errorf("failed to scan audit log: %w", err)
-			}
-
-			audits = append(audits, AuditEntry{
-				Action:    action,
-				Status:    auditStat,
-				Message:   nullStringToPtr(message),
-				Timestamp: timestamp,
-			})
+// ForEachAuditEntry calls fn with the audit logs of every transaction
+// matching f, in ForEachTransaction's order and oldest first within a
+// transaction. One joined query reads them all, rather than one query per
+// transaction.
+func ForEachAuditEntry(ctx context.Context, db replica.Querier, d Dialect, f Filter, fn func(AuditEntry) error) error {
+
+	where, args := f.where(d)
+	auditQuery := `
+        SELECT a.request_id, a.action, a.status, a.message, a.created_at
+        FROM transactions t
+        JOIN audit_logs a ON a.request_id = t.request_id
+        WHERE ` + where + `
+        ORDER BY t.created_at DESC, t.id DESC, a.created_at ASC, a.id ASC
+    `
+
+	rows, err := db.QueryContext(ctx, d.rebind(auditQuery), args...)
+	if err != nil {
+		return fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry   AuditEntry
+			message sql.NullString
+		)
+
+		if err := rows.Scan(&entry.RequestID, &entry.Action, &entry.Status, &message, &entry.Timestamp); err != nil {
+			return fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		entry.Message = nullStringToPtr(message)
+
+		if err := fn(entry); err != nil {
+			return err
 		}
-		auditRows.Close()
-
-		details = append(details, TransactionDetail{
-			TransactionID: txnID,
-			RequestID:     requestID,
-			FromAccountID: fromID,
-			ToAccountID:   toID,
-			Amount:        amount,
-			Status:        status,
-			ErrorMessage:  nullStringToPtr(errorMsg),
-			FromBalance:   fromBal.Int64,
-			ToBalance:     toBal.Int64,
-			CreatedAt:     createdAt,
-			AuditLogs:     audits,
-		})
 	}
 
-	return details, rows.Err()
+	return rows.Err()
 }
 
 // Helper to convert sql.NullString to *string
@@ -160,18 +228,19 @@ func nullStringToPtr(ns sql.NullString) *string {
 	return nil
 }
 
-func GetUserSummary(ctx context.Context, db *sql.DB, userID uint64) (*Summary, error) {
+// GetUserSummary totals the transactions matching f.
+func GetUserSummary(ctx context.Context, db replica.Querier, d Dialect, f Filter) (*Summary, error) {
+	where, args := f.where(d)
 	query := `
-		SELECT 
+		SELECT
 			COUNT(*) as total_txns,
-			SUM(CASE WHEN status = 'SUCCESS' THEN 1 ELSE 0 END) as success_count,
-			SUM(CASE WHEN status = 'FAILED' THEN 1 ELSE 0 END) as failed_count,
-			COALESCE(SUM(CASE WHEN from_account_id = ? THEN amount ELSE 0 END), 0) as total_sent,
-			COALESCE(SUM(CASE WHEN to_account_id = ? THEN amount ELSE 0 END), 0) as total_received,
+			SUM(CASE WHEN t.status = 'SUCCESS' THEN 1 ELSE 0 END) as success_count,
+			SUM(CASE WHEN t.status = 'FAILED' THEN 1 ELSE 0 END) as failed_count,
+			COALESCE(SUM(CASE WHEN t.from_account_id = ? THEN t.amount ELSE 0 END), 0) as total_sent,
+			COALESCE(SUM(CASE WHEN t.to_account_id = ? THEN t.amount ELSE 0 END), 0) as total_received,
 			MAX(t.created_at) as last_txn
 		FROM transactions t
-		WHERE t.from_account_id = ? OR t.to_account_id = ?
-	`
+		WHERE ` + where
 
 	var (
 		total, success, failed sql.NullInt64
@@ -179,7 +248,7 @@ func GetUserSummary(ctx context.Context, db *sql.DB, userID uint64) (*Summary, e
 		lastTxn                sql.NullString
 	)
 
-	err := db.QueryRowContext(ctx, query, userID, userID, userID, userID).Scan(
+	err := db.QueryRowContext(ctx, d.rebind(query), append([]any{f.AccountID, f.AccountID}, args...)...).Scan(
 		&total, &success, &failed, &sent, &received, &lastTxn,
 	)
 	if err != nil {
@@ -199,7 +268,7 @@ func GetUserSummary(ctx context.Context, db *sql.DB, userID uint64) (*Summary, e
 
 	// Get account creation time (use the first account involved in transactions)
 	accountQuery := `
-		SELECT MIN(created_at) FROM accounts 
+		SELECT MIN(created_at) FROM accounts
 		WHERE id IN (
 			SELECT DISTINCT from_account_id FROM transactions WHERE from_account_id = ?
 			UNION
@@ -208,7 +277,7 @@ func GetUserSummary(ctx context.Context, db *sql.DB, userID uint64) (*Summary, e
 	`
 
 	var accountCreated sql.NullString
-	db.QueryRowContext(ctx, accountQuery, userID, userID).Scan(&accountCreated)
+	db.QueryRowContext(ctx, d.rebind(accountQuery), f.AccountID, f.AccountID).Scan(&accountCreated)
 
 	return &Summary{
 		TotalTransactions: totalVal,
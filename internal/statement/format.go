@@ -0,0 +1,192 @@
+package statement
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Formats are the names NewWriter accepts.
+var Formats = []string{"csv", "json", "text"}
+
+// NewWriter returns a Writer rendering format to out. Output is buffered
+// and flushed by End, so nothing reaches out if the statement fails before
+// the buffer first fills.
+func NewWriter(format string, out io.Writer) (Writer, error) {
+	buf := bufio.NewWriter(out)
+	switch format {
+	case "csv":
+		return &csvWriter{buf: buf, csv: csv.NewWriter(buf)}, nil
+	case "json":
+		return &jsonWriter{buf: buf}, nil
+	case "text":
+		return &textWriter{buf: buf}, nil
+	}
+	return nil, fmt.Errorf("unknown statement format %q (want csv, json or text)", format)
+}
+
+func description(l Line) string {
+	if l.Debit != 0 {
+		return fmt.Sprintf("Transfer to account %d", l.Counterparty)
+	}
+	return fmt.Sprintf("Transfer from account %d", l.Counterparty)
+}
+
+func amount(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// csvWriter writes one row per line between an opening and a closing
+// balance row.
+type csvWriter struct {
+	buf *bufio.Writer
+	csv *csv.Writer
+}
+
+func (w *csvWriter) Begin(h Header) error {
+	w.csv.Write([]string{"posted_at", "transaction_id", "request_id", "description", "debit", "credit", "balance"})
+	return w.row(h.From, "", "", "Opening balance", "", "", h.OpeningBalance)
+}
+
+func (w *csvWriter) Line(l Line) error {
+	return w.row(l.PostedAt, strconv.FormatUint(l.TransactionID, 10), l.RequestID,
+		description(l), amount(l.Debit), amount(l.Credit), l.Balance)
+}
+
+func (w *csvWriter) End(s Summary) error {
+	w.row(s.To, "", "", "Closing balance", amount(s.TotalDebits), amount(s.TotalCredits), s.ClosingBalance)
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+func (w *csvWriter) row(at time.Time, txnID, requestID, desc, debit, credit string, balance int64) error {
+	w.csv.Write([]string{at.UTC().Format(time.RFC3339), txnID, requestID, desc, debit, credit, strconv.FormatInt(balance, 10)})
+	return w.csv.Error()
+}
+
+// jsonWriter streams one JSON object whose lines array is written element
+// by element.
+type jsonWriter struct {
+	buf   *bufio.Writer
+	lines int
+}
+
+type jsonHeader struct {
+	AccountID      uint64    `json:"account_id"`
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	OpeningBalance int64     `json:"opening_balance"`
+}
+
+type jsonLine struct {
+	TransactionID uint64    `json:"transaction_id"`
+	RequestID     string    `json:"request_id"`
+	PostedAt      time.Time `json:"posted_at"`
+	Counterparty  uint64    `json:"counterparty_account_id"`
+	Debit         int64     `json:"debit"`
+	Credit        int64     `json:"credit"`
+	Balance       int64     `json:"balance"`
+}
+
+type jsonTotals struct {
+	Lines          int64 `json:"line_count"`
+	TotalDebits    int64 `json:"total_debits"`
+	TotalCredits   int64 `json:"total_credits"`
+	ClosingBalance int64 `json:"closing_balance"`
+}
+
+// Begin writes the header object without its closing brace and opens the
+// lines array; End closes the array and appends the totals object without
+// its opening brace.
+func (w *jsonWriter) Begin(h Header) error {
+	b, err := json.Marshal(jsonHeader{
+		AccountID:      h.AccountID,
+		From:           h.From.UTC(),
+		To:             h.To.UTC(),
+		OpeningBalance: h.OpeningBalance,
+	})
+	if err != nil {
+		return err
+	}
+	w.buf.Write(b[:len(b)-1])
+	_, err = w.buf.WriteString(`,"lines":[`)
+	return err
+}
+
+func (w *jsonWriter) Line(l Line) error {
+	b, err := json.Marshal(jsonLine{
+		TransactionID: l.TransactionID,
+		RequestID:     l.RequestID,
+		PostedAt:      l.PostedAt.UTC(),
+		Counterparty:  l.Counterparty,
+		Debit:         l.Debit,
+		Credit:        l.Credit,
+		Balance:       l.Balance,
+	})
+	if err != nil {
+		return err
+	}
+	if w.lines > 0 {
+		w.buf.WriteByte(',')
+	}
+	w.lines++
+	_, err = w.buf.Write(b)
+	return err
+}
+
+func (w *jsonWriter) End(s Summary) error {
+	b, err := json.Marshal(jsonTotals{
+		Lines:          s.Lines,
+		TotalDebits:    s.TotalDebits,
+		TotalCredits:   s.TotalCredits,
+		ClosingBalance: s.ClosingBalance,
+	})
+	if err != nil {
+		return err
+	}
+	w.buf.WriteString(`],`)
+	w.buf.Write(b[1:])
+	w.buf.WriteByte('\n')
+	return w.buf.Flush()
+}
+
+// textWriter prints fixed-width columns. It does not align them with
+// text/tabwriter, which would hold every line until the end.
+type textWriter struct {
+	buf *bufio.Writer
+}
+
+const textRow = "%-20s  %10s  %-36s  %-30s  %14s  %14s  %14s\n"
+
+func (w *textWriter) Begin(h Header) error {
+	fmt.Fprintf(w.buf, "Statement of account %d\n", h.AccountID)
+	fmt.Fprintf(w.buf, "Period: %s to %s (amounts in paise)\n\n",
+		h.From.UTC().Format(time.RFC3339), h.To.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w.buf, textRow, "POSTED", "TXN", "REFERENCE", "DESCRIPTION", "DEBIT", "CREDIT", "BALANCE")
+	_, err := fmt.Fprintf(w.buf, textRow, h.From.UTC().Format(time.RFC3339), "", "", "Opening balance", "", "",
+		strconv.FormatInt(h.OpeningBalance, 10))
+	return err
+}
+
+func (w *textWriter) Line(l Line) error {
+	_, err := fmt.Fprintf(w.buf, textRow, l.PostedAt.UTC().Format(time.RFC3339), strconv.FormatUint(l.TransactionID, 10),
+		l.RequestID, description(l), amount(l.Debit), amount(l.Credit), strconv.FormatInt(l.Balance, 10))
+	return err
+}
+
+func (w *textWriter) End(s Summary) error {
+	fmt.Fprintf(w.buf, textRow, s.To.UTC().Format(time.RFC3339), "", "", "Closing balance", "", "",
+		strconv.FormatInt(s.ClosingBalance, 10))
+	fmt.Fprintf(w.buf, "\n%d posting(s), %d debited, %d credited\n", s.Lines, s.TotalDebits, s.TotalCredits)
+	return w.buf.Flush()
+}
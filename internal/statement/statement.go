@@ -0,0 +1,152 @@
+// Package statement builds bank-style account statements: the opening
+// balance, every posted debit and credit with the running balance after
+// it, and the closing balance. Only SUCCESS transfers are posted.
+//
+// Lines are read from the database and handed to a Writer one at a time,
+// so a period with millions of transfers is never held in memory.
+package statement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopherpay/internal/billing"
+)
+
+// Epoch is where a statement starts when no from bound is given, the
+// earliest time every storage backend can compare against.
+var Epoch = time.Unix(0, 0).UTC()
+
+// Reader is the part of billing.AccountReader a statement is built from.
+type Reader interface {
+	BalanceAt(ctx context.Context, accountID uint64, at time.Time) (int64, error)
+	ForEachPosting(ctx context.Context, accountID uint64, from, to time.Time, fn func(billing.Transaction) error) error
+}
+
+// Header opens a statement for the period [From, To).
+type Header struct {
+	AccountID      uint64
+	From           time.Time
+	To             time.Time
+	OpeningBalance int64
+}
+
+// Line is one posted transfer. Exactly one of Debit and Credit is set.
+type Line struct {
+	TransactionID uint64
+	RequestID     string
+	PostedAt      time.Time
+	Counterparty  uint64 // the other account of the transfer
+	Debit         int64
+	Credit        int64
+	Balance       int64 // after this line
+}
+
+// Summary closes a statement.
+type Summary struct {
+	Header
+	Lines          int64
+	TotalDebits    int64
+	TotalCredits   int64
+	ClosingBalance int64
+}
+
+// Writer renders a statement as it is generated: Begin once, Line for
+// each posting in order, then End.
+type Writer interface {
+	Begin(h Header) error
+	Line(l Line) error
+	End(s Summary) error
+}
+
+// Generate writes accountID's statement for [from, to) to w.
+//
+// The opening balance is the account's current balance less the transfers
+// posted since from, so balances that did not arrive by transfer, such as
+// seeded ones, are accounted for. The closing balance is the opening
+// balance plus the lines, so the two always agree with what was written
+// even while new transfers are being posted.
+func Generate(ctx context.Context, r Reader, accountID uint64, from, to time.Time, w Writer) (Summary, error) {
+	opening, err := r.BalanceAt(ctx, accountID, from)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	sum := Summary{
+		Header:         Header{AccountID: accountID, From: from, To: to, OpeningBalance: opening},
+		ClosingBalance: opening,
+	}
+	if err := w.Begin(sum.Header); err != nil {
+		return sum, err
+	}
+
+	err = r.ForEachPosting(ctx, accountID, from, to, func(txn billing.Transaction) error {
+		l := Line{
+			TransactionID: txn.ID,
+			RequestID:     txn.RequestID,
+			PostedAt:      txn.CreatedAt,
+		}
+		if txn.FromAccountID == accountID {
+			l.Counterparty, l.Debit = txn.ToAccountID, txn.Amount
+			sum.ClosingBalance -= txn.Amount
+			sum.TotalDebits += txn.Amount
+		} else {
+			l.Counterparty, l.Credit = txn.FromAccountID, txn.Amount
+			sum.ClosingBalance += txn.Amount
+			sum.TotalCredits += txn.Amount
+		}
+		l.Balance = sum.ClosingBalance
+		sum.Lines++
+		return w.Line(l)
+	})
+	if err != nil {
+		return sum, err
+	}
+
+	return sum, w.End(sum)
+}
+
+// ErrInvalidPeriod is returned by ParsePeriod for bounds it cannot use.
+var ErrInvalidPeriod = errors.New("invalid period")
+
+// ParsePeriod parses the from and to bounds of a statement. Each is an
+// RFC 3339 time or a YYYY-MM-DD date in UTC; a date given as to includes
+// that whole day. An empty from starts at Epoch and an empty to ends at
+// now.
+func ParsePeriod(from, to string, now time.Time) (time.Time, time.Time, error) {
+	start, end := Epoch, now
+	var err error
+	if from != "" {
+		if start, err = parseBound(from, false); err != nil {
+			return start, end, err
+		}
+	}
+	if to != "" {
+		if end, err = parseBound(to, true); err != nil {
+			return start, end, err
+		}
+	}
+	if start.Before(Epoch) {
+		return start, end, fmt.Errorf("%w: from must not be before %s", ErrInvalidPeriod, Epoch.Format(time.DateOnly))
+	}
+	if !start.Before(end) {
+		return start, end, fmt.Errorf("%w: from must be before to", ErrInvalidPeriod)
+	}
+	return start, end, nil
+}
+
+func parseBound(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("%w: %q is neither YYYY-MM-DD nor an RFC 3339 time", ErrInvalidPeriod, s)
+	}
+	return t.UTC(), nil
+}
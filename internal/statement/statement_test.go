@@ -0,0 +1,175 @@
+package statement
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopherpay/internal/billing"
+)
+
+// fakeReader serves a fixed opening balance and a list of postings.
+type fakeReader struct {
+	opening  int64
+	postings []billing.Transaction
+
+	balanceAt time.Time // the time BalanceAt was asked for
+}
+
+func (r *fakeReader) BalanceAt(ctx context.Context, accountID uint64, at time.Time) (int64, error) {
+	r.balanceAt = at
+	return r.opening, nil
+}
+
+func (r *fakeReader) ForEachPosting(ctx context.Context, accountID uint64, from, to time.Time, fn func(billing.Transaction) error) error {
+	for _, txn := range r.postings {
+		if txn.FromAccountID != accountID && txn.ToAccountID != accountID {
+			continue
+		}
+		if txn.CreatedAt.Before(from) || !txn.CreatedAt.Before(to) {
+			continue
+		}
+		if err := fn(txn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recorder keeps everything written to it.
+type recorder struct {
+	header  Header
+	lines   []Line
+	summary Summary
+	calls   []string
+}
+
+func (w *recorder) Begin(h Header) error {
+	w.header = h
+	w.calls = append(w.calls, "begin")
+	return nil
+}
+
+func (w *recorder) Line(l Line) error {
+	w.lines = append(w.lines, l)
+	w.calls = append(w.calls, "line")
+	return nil
+}
+
+func (w *recorder) End(s Summary) error {
+	w.summary = s
+	w.calls = append(w.calls, "end")
+	return nil
+}
+
+func TestGenerate(t *testing.T) {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour) }
+
+	r := &fakeReader{
+		opening: 1000,
+		postings: []billing.Transaction{
+			{ID: 1, RequestID: "before", FromAccountID: 7, ToAccountID: 2, Amount: 50, CreatedAt: day.Add(-time.Hour)},
+			{ID: 2, RequestID: "out", FromAccountID: 7, ToAccountID: 2, Amount: 300, CreatedAt: at(1)},
+			{ID: 3, RequestID: "in", FromAccountID: 3, ToAccountID: 7, Amount: 120, CreatedAt: at(2)},
+			{ID: 4, RequestID: "other", FromAccountID: 2, ToAccountID: 3, Amount: 999, CreatedAt: at(3)},
+			{ID: 5, RequestID: "out-again", FromAccountID: 7, ToAccountID: 3, Amount: 20, CreatedAt: at(4)},
+			{ID: 6, RequestID: "after", FromAccountID: 2, ToAccountID: 7, Amount: 10, CreatedAt: at(24)},
+		},
+	}
+	w := &recorder{}
+
+	sum, err := Generate(context.Background(), r, 7, day, at(24), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.balanceAt.Equal(day) {
+		t.Errorf("opening balance read at %s, want %s", r.balanceAt, day)
+	}
+	wantHeader := Header{AccountID: 7, From: day, To: at(24), OpeningBalance: 1000}
+	if w.header != wantHeader {
+		t.Errorf("header = %+v, want %+v", w.header, wantHeader)
+	}
+
+	wantLines := []Line{
+		{TransactionID: 2, RequestID: "out", PostedAt: at(1), Counterparty: 2, Debit: 300, Balance: 700},
+		{TransactionID: 3, RequestID: "in", PostedAt: at(2), Counterparty: 3, Credit: 120, Balance: 820},
+		{TransactionID: 5, RequestID: "out-again", PostedAt: at(4), Counterparty: 3, Debit: 20, Balance: 800},
+	}
+	if !reflect.DeepEqual(w.lines, wantLines) {
+		t.Errorf("lines = %+v\nwant %+v", w.lines, wantLines)
+	}
+
+	wantSum := Summary{Header: wantHeader, Lines: 3, TotalDebits: 320, TotalCredits: 120, ClosingBalance: 800}
+	if sum != wantSum {
+		t.Errorf("summary = %+v, want %+v", sum, wantSum)
+	}
+	if w.summary != wantSum {
+		t.Errorf("written summary = %+v, want %+v", w.summary, wantSum)
+	}
+	if want := []string{"begin", "line", "line", "line", "end"}; !reflect.DeepEqual(w.calls, want) {
+		t.Errorf("calls = %v, want %v", w.calls, want)
+	}
+}
+
+func TestGenerateEmptyPeriod(t *testing.T) {
+	r := &fakeReader{opening: 450}
+	w := &recorder{}
+
+	sum, err := Generate(context.Background(), r, 7, Epoch, Epoch.Add(time.Hour), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Lines != 0 || sum.ClosingBalance != 450 {
+		t.Errorf("summary = %+v, want no lines and a closing balance of 450", sum)
+	}
+	if want := []string{"begin", "end"}; !reflect.DeepEqual(w.calls, want) {
+		t.Errorf("calls = %v, want %v", w.calls, want)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     [2]time.Time
+		wantErr  bool
+	}{
+		{"defaults", "", "", [2]time.Time{Epoch, now}, false},
+		{"date-only to includes the day", "2026-03-01", "2026-03-01", [2]time.Time{date(2026, 3, 1), date(2026, 3, 2)}, false},
+		{"date range", "2026-02-01", "2026-02-28", [2]time.Time{date(2026, 2, 1), date(2026, 3, 1)}, false},
+		{"RFC 3339 to is exact", "2026-03-01", "2026-03-01T10:00:00Z", [2]time.Time{date(2026, 3, 1), date(2026, 3, 1).Add(10 * time.Hour)}, false},
+		{"RFC 3339 converted to UTC", "2026-03-01T02:00:00+02:00", "", [2]time.Time{date(2026, 3, 1), now}, false},
+		{"from at Epoch", "1970-01-01", "1970-01-01", [2]time.Time{Epoch, date(1970, 1, 2)}, false},
+		{"from before Epoch", "1969-12-31", "2026-03-01", [2]time.Time{}, true},
+		{"from before Epoch by a second", "1969-12-31T23:59:59Z", "", [2]time.Time{}, true},
+		{"from after to", "2026-03-02", "2026-03-01", [2]time.Time{}, true},
+		{"from equal to", "2026-03-01T10:00:00Z", "2026-03-01T10:00:00Z", [2]time.Time{}, true},
+		{"from after now", "2026-04-01", "", [2]time.Time{}, true},
+		{"malformed", "March 1st", "", [2]time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := ParsePeriod(tt.from, tt.to, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPeriod) {
+					t.Fatalf("err = %v, want ErrInvalidPeriod", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !start.Equal(tt.want[0]) || !end.Equal(tt.want[1]) {
+				t.Errorf("period = [%s, %s), want [%s, %s)", start, end, tt.want[0], tt.want[1])
+			}
+		})
+	}
+}
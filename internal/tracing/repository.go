@@ -0,0 +1,130 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"gopherpay/internal/audit"
+	"gopherpay/internal/billing"
+)
+
+var tracer = otel.Tracer("gopherpay/internal/tracing")
+
+// startQuery opens a client span for one repository call.
+func startQuery(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("db.system.name", "mysql"))
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Wallet traces every call billing.Service makes to repo.
+func Wallet(repo billing.WalletRepository) billing.WalletRepository {
+	return &wallet{repo: repo}
+}
+
+type wallet struct {
+	repo billing.WalletRepository
+}
+
+func (w *wallet) BeginTx(ctx context.Context) (billing.Tx, error) {
+	ctx, span := startQuery(ctx, "db.BeginTx")
+	tx, err := w.repo.BeginTx(ctx)
+	end(span, err)
+	return tx, err
+}
+
+func (w *wallet) GetAccountForUpdate(ctx context.Context, tx billing.Tx, accountID uint64) (*billing.Account, error) {
+	ctx, span := startQuery(ctx, "db.GetAccountForUpdate", attribute.Int64("account_id", int64(accountID)))
+	acc, err := w.repo.GetAccountForUpdate(ctx, tx, accountID)
+	end(span, err)
+	return acc, err
+}
+
+func (w *wallet) GetAccountBalance(ctx context.Context, tx billing.Tx, accountID uint64) (int64, error) {
+	ctx, span := startQuery(ctx, "db.GetAccountBalance", attribute.Int64("account_id", int64(accountID)))
+	bal, err := w.repo.GetAccountBalance(ctx, tx, accountID)
+	end(span, err)
+	return bal, err
+}
+
+func (w *wallet) UpdateAccountBalance(ctx context.Context, tx billing.Tx, accountID uint64, newBalance int64) error {
+	ctx, span := startQuery(ctx, "db.UpdateAccountBalance", attribute.Int64("account_id", int64(accountID)))
+	err := w.repo.UpdateAccountBalance(ctx, tx, accountID, newBalance)
+	end(span, err)
+	return err
+}
+
+func (w *wallet) ReadAccount(ctx context.Context, tx billing.Tx, accountID uint64) (*billing.Account, error) {
+	ctx, span := startQuery(ctx, "db.ReadAccount", attribute.Int64("account_id", int64(accountID)))
+	acc, err := w.repo.ReadAccount(ctx, tx, accountID)
+	end(span, err)
+	return acc, err
+}
+
+func (w *wallet) UpdateAccountBalanceAtVersion(ctx context.Context, tx billing.Tx, accountID uint64, newBalance int64, version uint64) (bool, error) {
+	ctx, span := startQuery(ctx, "db.UpdateAccountBalanceAtVersion",
+		attribute.Int64("account_id", int64(accountID)), attribute.Int64("version", int64(version)))
+	ok, err := w.repo.UpdateAccountBalanceAtVersion(ctx, tx, accountID, newBalance, version)
+	end(span, err)
+	return ok, err
+}
+
+func (w *wallet) FindTransactionByRequestID(ctx context.Context, tx billing.Tx, requestID string) (*billing.Transaction, error) {
+	ctx, span := startQuery(ctx, "db.FindTransactionByRequestID", attribute.String("request_id", requestID))
+	txn, err := w.repo.FindTransactionByRequestID(ctx, tx, requestID)
+	// Not finding one is the normal first attempt, not a failure.
+	if errors.Is(err, billing.ErrTransactionNotFound) {
+		end(span, nil)
+	} else {
+		end(span, err)
+	}
+	return txn, err
+}
+
+func (w *wallet) InsertTransaction(ctx context.Context, tx billing.Tx, txn *billing.Transaction) (uint64, error) {
+	ctx, span := startQuery(ctx, "db.InsertTransaction", attribute.String("request_id", txn.RequestID))
+	id, err := w.repo.InsertTransaction(ctx, tx, txn)
+	end(span, err)
+	return id, err
+}
+
+func (w *wallet) UpdateTransactionStatus(ctx context.Context, tx billing.Tx, txnID uint64, status billing.TransactionStatus, errMsg *string) error {
+	ctx, span := startQuery(ctx, "db.UpdateTransactionStatus",
+		attribute.Int64("txn_id", int64(txnID)),
+		attribute.String("status", string(status)))
+	err := w.repo.UpdateTransactionStatus(ctx, tx, txnID, status, errMsg)
+	end(span, err)
+	return err
+}
+
+// Audit traces audit log writes.
+func Audit(repo audit.Repository) audit.Repository {
+	return &auditLog{repo: repo}
+}
+
+type auditLog struct {
+	repo audit.Repository
+}
+
+func (a *auditLog) Log(ctx context.Context, entry *audit.AuditLog) error {
+	ctx, span := startQuery(ctx, "db.AuditLog",
+		attribute.String("request_id", entry.RequestID),
+		attribute.String("action", entry.Action))
+	err := a.repo.Log(ctx, entry)
+	end(span, err)
+	return err
+}
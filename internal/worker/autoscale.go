@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"time"
+)
+
+// AutoscaleConfig bounds automatic resizing of a shared pool.
+type AutoscaleConfig struct {
+	Min, Max int
+	Interval time.Duration // how often to re-evaluate
+
+	// MaxLatency stops scale-up once average processing time over the last
+	// interval exceeds it: slow jobs usually mean the database is the
+	// bottleneck, and more workers only add lock contention.
+	MaxLatency time.Duration
+}
+
+// Autoscale adjusts the worker count until ctx is done. Each interval it
+// adds a worker while jobs are waiting beyond one per worker, and removes
+// one once the queue is empty and fewer than half the workers are busy.
+// While it runs, Resize returns ErrAutoscaling.
+func (p *Pool) Autoscale(ctx context.Context, cfg AutoscaleConfig) {
+	p.mu.Lock()
+	p.autoscaling = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.autoscaling = false
+		p.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	lastProcessed := p.counters.processed.Load()
+	lastNanos := p.counters.totalNanos.Load()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats, err := p.Stats(ctx)
+		if err != nil {
+			p.logger.Warn("autoscale skipped", "error", err)
+			continue
+		}
+
+		processed, nanos := p.counters.processed.Load(), p.counters.totalNanos.Load()
+		var recent time.Duration
+		if processed > lastProcessed {
+			recent = time.Duration((nanos - lastNanos) / int64(processed-lastProcessed))
+		}
+		lastProcessed, lastNanos = processed, nanos
+
+		target := stats.Workers
+		switch {
+		case stats.QueueDepth > stats.Workers && (cfg.MaxLatency == 0 || recent <= cfg.MaxLatency):
+			target++
+		case stats.QueueDepth == 0 && stats.InFlight < int64(stats.Workers/2):
+			target--
+		}
+		target = max(cfg.Min, min(cfg.Max, target))
+
+		if target != stats.Workers {
+			if err := p.resize(target, true); err != nil {
+				p.logger.Warn("autoscale resize failed", "error", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package worker
+
+import "sync"
+
+// lane is the FIFO of claimed jobs waiting for one dispatch lane. push never
+// blocks, so a lane stuck behind a slow account does not hold up the
+// dispatcher, and jobs for the other lanes keep flowing.
+type lane struct {
+	mu     sync.Mutex
+	jobs   []TransferJob
+	closed bool
+	ready  chan struct{}
+}
+
+func newLane() *lane {
+	return &lane{ready: make(chan struct{}, 1)}
+}
+
+func (l *lane) push(job TransferJob) {
+	l.mu.Lock()
+	l.jobs = append(l.jobs, job)
+	l.mu.Unlock()
+	l.signal()
+}
+
+// close lets pop return false once the jobs already pushed are taken.
+func (l *lane) close() {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	l.signal()
+}
+
+// pop waits for the next job in push order. It returns false once the lane
+// is closed and empty.
+func (l *lane) pop() (TransferJob, bool) {
+	for {
+		l.mu.Lock()
+		if len(l.jobs) > 0 {
+			job := l.jobs[0]
+			l.jobs[0] = TransferJob{}
+			l.jobs = l.jobs[1:]
+			l.mu.Unlock()
+			return job, true
+		}
+		closed := l.closed
+		l.mu.Unlock()
+
+		if closed {
+			return TransferJob{}, false
+		}
+		<-l.ready
+	}
+}
+
+func (l *lane) signal() {
+	select {
+	case l.ready <- struct{}{}:
+	default:
+	}
+}
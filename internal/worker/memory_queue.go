@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryQueue is a bounded in-process queue. Jobs are lost if the process
+// dies, so it is meant for tests, demos and single-node setups that accept
+// that risk. On Close, already queued jobs are still handed out.
+type MemoryQueue struct {
+	mu     sync.RWMutex
+	jobs   chan TransferJob
+	closed bool
+}
+
+func NewMemoryQueue(capacity int) *MemoryQueue {
+	return &MemoryQueue{jobs: make(chan TransferJob, capacity)}
+}
+
+func (q *MemoryQueue) Enqueue(ctx context.Context, job TransferJob) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *MemoryQueue) Claim(ctx context.Context) (TransferJob, error) {
+	select {
+	case job, ok := <-q.jobs:
+		if !ok {
+			return TransferJob{}, ErrQueueClosed
+		}
+		return job, nil
+	case <-ctx.Done():
+		return TransferJob{}, ctx.Err()
+	}
+}
+
+func (q *MemoryQueue) TryClaim(ctx context.Context) (TransferJob, bool, error) {
+	select {
+	case job, ok := <-q.jobs:
+		if !ok {
+			return TransferJob{}, false, ErrQueueClosed
+		}
+		return job, true, nil
+	default:
+		return TransferJob{}, false, nil
+	}
+}
+
+func (q *MemoryQueue) Release(ctx context.Context, job TransferJob) error {
+	return q.Enqueue(ctx, job)
+}
+
+func (q *MemoryQueue) Complete(ctx context.Context, job TransferJob) error {
+	return nil
+}
+
+func (q *MemoryQueue) Depth(ctx context.Context) (int, error) {
+	return len(q.jobs), nil
+}
+
+func (q *MemoryQueue) Capacity() int {
+	return cap(q.jobs)
+}
+
+func (q *MemoryQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+}
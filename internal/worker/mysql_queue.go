@@ -0,0 +1,266 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/dberr"
+)
+
+// MySQLQueue stores jobs in the transfer_jobs table so accepted transfers
+// survive a crash. Workers claim rows with SELECT ... FOR UPDATE SKIP LOCKED,
+// so several processes can share one table. A claimed job that is not
+// completed within the lease (because its worker died) is handed out again.
+//
+// Each MySQLQueue serves one priority; NewMySQLQueue returns the normal lane
+// and Lane derives the others, all sharing the same table.
+type MySQLQueue struct {
+	db           *sql.DB
+	priority     Priority
+	capacity     int
+	lease        time.Duration
+	pollInterval time.Duration
+	wake         chan struct{}
+	closed       atomic.Bool
+}
+
+func NewMySQLQueue(db *sql.DB, capacity int, lease time.Duration) *MySQLQueue {
+	return &MySQLQueue{
+		db:           db,
+		capacity:     capacity,
+		lease:        lease,
+		pollInterval: 500 * time.Millisecond,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Lane returns a queue over the same table holding only jobs of priority p,
+// with its own capacity.
+func (q *MySQLQueue) Lane(p Priority, capacity int) *MySQLQueue {
+	return &MySQLQueue{
+		db:           q.db,
+		priority:     p,
+		capacity:     capacity,
+		lease:        q.lease,
+		pollInterval: q.pollInterval,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+func (q *MySQLQueue) Enqueue(ctx context.Context, job TransferJob) error {
+	if q.closed.Load() {
+		return ErrQueueClosed
+	}
+
+	// The capacity check is advisory: concurrent enqueues may overshoot it
+	// slightly, which is fine for backpressure purposes.
+	depth, err := q.Depth(ctx)
+	if err != nil {
+		return err
+	}
+	if depth >= q.capacity {
+		return ErrQueueFull
+	}
+
+	query := `
+        INSERT INTO transfer_jobs (request_id, subject, from_account_id, to_account_id,
+        amount, deadline, priority, metadata, status)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'QUEUED')
+    `
+
+	var deadline sql.NullTime
+	if !job.Deadline.IsZero() {
+		deadline = sql.NullTime{Time: job.Deadline, Valid: true}
+	}
+
+	var metadata []byte
+	if len(job.Metadata) > 0 {
+		if metadata, err = json.Marshal(job.Metadata); err != nil {
+			return fmt.Errorf("failed to encode job metadata: %w", err)
+		}
+	}
+
+	_, err = q.db.ExecContext(ctx, query,
+		job.Request.RequestID,
+		job.Request.Subject,
+		job.Request.FromID,
+		job.Request.ToID,
+		job.Request.Amount,
+		deadline,
+		q.priority.String(),
+		metadata,
+	)
+	if dberr.IsDuplicate(err) {
+		return q.requeue(ctx, job)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// requeue handles an Enqueue whose request ID is already in the table.
+func (q *MySQLQueue) requeue(ctx context.Context, job TransferJob) error {
+	var queued billing.TransferRequest
+	err := q.db.QueryRowContext(ctx,
+		`SELECT from_account_id, to_account_id, amount FROM transfer_jobs WHERE request_id = ?`,
+		job.Request.RequestID,
+	).Scan(&queued.FromID, &queued.ToID, &queued.Amount)
+	// Completed since the insert failed: queue it again, and the worker
+	// checks it against the recorded transfer.
+	if errors.Is(err, sql.ErrNoRows) {
+		return q.Enqueue(ctx, job)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read queued job %s: %w", job.Request.RequestID, err)
+	}
+	return checkRequeue(queued, job)
+}
+
+func (q *MySQLQueue) Claim(ctx context.Context) (TransferJob, error) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, ok, err := q.TryClaim(ctx)
+		if err != nil || ok {
+			return job, err
+		}
+
+		select {
+		case <-q.wake:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return TransferJob{}, ctx.Err()
+		}
+	}
+}
+
+func (q *MySQLQueue) TryClaim(ctx context.Context) (TransferJob, bool, error) {
+	// Unclaimed rows stay in the table for the next start, so there is
+	// nothing to drain after Close.
+	if q.closed.Load() {
+		return TransferJob{}, false, ErrQueueClosed
+	}
+
+	job, err := q.claimOne(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TransferJob{}, false, nil
+	}
+	if err != nil {
+		return TransferJob{}, false, err
+	}
+	return job, true, nil
+}
+
+func (q *MySQLQueue) claimOne(ctx context.Context) (TransferJob, error) {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return TransferJob{}, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        SELECT id, request_id, subject, from_account_id, to_account_id, amount, deadline, metadata,
+               created_at
+        FROM transfer_jobs
+        WHERE priority = ?
+          AND (status = 'QUEUED'
+               OR (status = 'CLAIMED' AND claimed_at < NOW(3) - INTERVAL ? SECOND))
+        ORDER BY id ASC
+        LIMIT 1
+        FOR UPDATE SKIP LOCKED
+    `
+
+	var (
+		id       uint64
+		job      TransferJob
+		deadline sql.NullTime
+		metadata []byte
+	)
+	err = tx.QueryRowContext(ctx, query, q.priority.String(), int(q.lease.Seconds())).Scan(
+		&id,
+		&job.Request.RequestID,
+		&job.Request.Subject,
+		&job.Request.FromID,
+		&job.Request.ToID,
+		&job.Request.Amount,
+		&deadline,
+		&metadata,
+		&job.EnqueuedAt,
+	)
+	if err != nil {
+		return TransferJob{}, err
+	}
+	if deadline.Valid {
+		job.Deadline = deadline.Time
+	}
+	job.Priority = q.priority
+	// Metadata is best effort: a job is still processed without it.
+	_ = json.Unmarshal(metadata, &job.Metadata)
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE transfer_jobs
+        SET status = 'CLAIMED', claimed_at = NOW(3), attempts = attempts + 1
+        WHERE id = ?
+    `, id)
+	if err != nil {
+		return TransferJob{}, fmt.Errorf("failed to claim job %d: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return TransferJob{}, fmt.Errorf("failed to commit job claim: %w", err)
+	}
+
+	return job, nil
+}
+
+// Release puts a claimed job back to QUEUED without counting the attempt.
+// It works after Close, so jobs can be handed back during shutdown.
+func (q *MySQLQueue) Release(ctx context.Context, job TransferJob) error {
+	_, err := q.db.ExecContext(ctx, `
+        UPDATE transfer_jobs
+        SET status = 'QUEUED', claimed_at = NULL, attempts = GREATEST(attempts, 1) - 1
+        WHERE request_id = ?
+    `, job.Request.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to release job %s: %w", job.Request.RequestID, err)
+	}
+	return nil
+}
+
+func (q *MySQLQueue) Complete(ctx context.Context, job TransferJob) error {
+	_, err := q.db.ExecContext(ctx, `DELETE FROM transfer_jobs WHERE request_id = ?`, job.Request.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to complete job %s: %w", job.Request.RequestID, err)
+	}
+	return nil
+}
+
+func (q *MySQLQueue) Depth(ctx context.Context) (int, error) {
+	var depth int
+	if err := q.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transfer_jobs WHERE priority = ?`, q.priority.String()).Scan(&depth); err != nil {
+		return 0, fmt.Errorf("failed to read queue depth: %w", err)
+	}
+	return depth, nil
+}
+
+func (q *MySQLQueue) Capacity() int {
+	return q.capacity
+}
+
+func (q *MySQLQueue) Close() {
+	q.closed.Store(true)
+}
@@ -2,62 +2,403 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"gopherpay/internal/billing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TransferJob struct {
-	Request billing.TransferRequest
+	Request    billing.TransferRequest
+	Deadline   time.Time // zero means no deadline
+	Priority   Priority
+	EnqueuedAt time.Time // set by Submit
+
+	// Metadata carries request-scoped context values (request ID, trace
+	// context) from the submitting request to the worker. See Propagator.
+	Metadata map[string]string
 }
 
+// Dispatch selects how claimed jobs are handed to workers.
+type Dispatch int
+
+const (
+	// DispatchShared lets any idle worker take the next job.
+	DispatchShared Dispatch = iota
+	// DispatchByAccount hashes each job's sender account onto a fixed worker
+	// lane. Transfers from the same account run one at a time and in queue
+	// order, so a hot account no longer has several workers queueing on its
+	// row lock.
+	DispatchByAccount
+)
+
+var ErrResizeUnsupported = errors.New("per-account dispatch cannot be resized at runtime")
+
+// ErrAutoscaling is returned by Resize while Autoscale runs, since its next
+// tick would undo the new size.
+var ErrAutoscaling = errors.New("the pool is autoscaling and cannot be resized by hand")
+
+var tracer = otel.Tracer("gopherpay/internal/worker")
+
 type Pool struct {
-	jobs    chan TransferJob
-	service *billing.Service
-	logger  *slog.Logger
-	wg      sync.WaitGroup
+	queue       Queue
+	service     *billing.Service
+	logger      *slog.Logger
+	wg          sync.WaitGroup
+	onComplete  []func(TransferJob, error)
+	onReject    []func(TransferJob, error)
+	propagators []Propagator
+	dispatch    Dispatch
+	counters    counters
+
+	// base is the parent of every job context; abort cancels it when
+	// Shutdown runs out of time.
+	base  context.Context
+	abort context.CancelFunc
+
+	mu          sync.Mutex
+	stops       []context.CancelFunc // one per shared worker
+	lanes       int
+	autoscaling bool
 }
 
-func NewPool(bufferSize int, service *billing.Service, logger *slog.Logger) *Pool {
+func NewPool(queue Queue, service *billing.Service, logger *slog.Logger) *Pool {
+	base, abort := context.WithCancel(context.Background())
 	return &Pool{
-		jobs:    make(chan TransferJob, bufferSize),
+		queue:   queue,
 		service: service,
 		logger:  logger,
+		base:    base,
+		abort:   abort,
 	}
 }
 
+// OnReject registers fn to be called when Submit fails to queue a job, for
+// example with ErrQueueFull. It must be called before Start.
+func (p *Pool) OnReject(fn func(job TransferJob, err error)) {
+	p.onReject = append(p.onReject, fn)
+}
+
+// AddPropagator registers prop to carry context values through the queue.
+// It must be called before Start.
+func (p *Pool) AddPropagator(prop Propagator) {
+	p.propagators = append(p.propagators, prop)
+}
+
+// OnComplete registers fn to be called after each job is processed, with
+// the error returned by the service. It must be called before Start.
+func (p *Pool) OnComplete(fn func(job TransferJob, err error)) {
+	p.onComplete = append(p.onComplete, fn)
+}
+
+// SetDispatch chooses the dispatch mode. It must be called before Start.
+func (p *Pool) SetDispatch(mode Dispatch) {
+	p.dispatch = mode
+}
+
 func (p *Pool) Start(workerCount int) {
-	for i := 0; i < workerCount; i++ {
+	if p.dispatch == DispatchByAccount {
+		p.startLanes(workerCount)
+		return
+	}
+
+	_ = p.Resize(workerCount)
+}
+
+// Resize grows or shrinks the number of shared workers. Removed workers
+// finish the job they are running before exiting. Lanes are fixed because
+// changing their count would reorder transfers from the same account, and
+// an autoscaling pool is sized by Autoscale alone.
+func (p *Pool) Resize(workerCount int) error {
+	return p.resize(workerCount, false)
+}
+
+func (p *Pool) resize(workerCount int, autoscale bool) error {
+	if workerCount < 1 {
+		return fmt.Errorf("worker count must be at least 1, got %d", workerCount)
+	}
+	if p.dispatch == DispatchByAccount {
+		return ErrResizeUnsupported
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.autoscaling && !autoscale {
+		return ErrAutoscaling
+	}
+
+	for len(p.stops) < workerCount {
+		ctx, stop := context.WithCancel(context.Background())
+		p.stops = append(p.stops, stop)
+
 		p.wg.Add(1)
-		go p.worker()
+		go p.worker(ctx)
+	}
+	for len(p.stops) > workerCount {
+		last := len(p.stops) - 1
+		p.stops[last]()
+		p.stops = p.stops[:last]
+	}
+
+	p.logger.Info("worker pool resized", "workers", workerCount)
+	return nil
+}
+
+// Workers returns the current number of workers or lanes.
+func (p *Pool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.dispatch == DispatchByAccount {
+		return p.lanes
 	}
+	return len(p.stops)
 }
 
-func (p *Pool) worker() {
+// claimAhead is how many claimed jobs, per lane on average, may wait on the
+// lanes between them. A hot lane can use the whole allowance without
+// blocking dispatch to the others; once it is spent the dispatcher stops
+// claiming, so unstarted jobs stay in the queue rather than outliving their
+// lease in memory.
+const claimAhead = 64
+
+func (p *Pool) startLanes(laneCount int) {
+	p.mu.Lock()
+	p.lanes = laneCount
+	p.mu.Unlock()
+
+	slots := make(chan struct{}, laneCount*claimAhead)
+
+	lanes := make([]*lane, laneCount)
+	for i := range lanes {
+		lanes[i] = newLane()
+
+		p.wg.Add(1)
+		go func(l *lane) {
+			defer p.wg.Done()
+			for {
+				job, ok := l.pop()
+				if !ok {
+					return
+				}
+				p.process(job)
+				<-slots
+			}
+		}(lanes[i])
+	}
+
+	// A single dispatcher claims jobs so that per-account order matches the
+	// queue order.
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() {
+			for _, l := range lanes {
+				l.close()
+			}
+		}()
+
+		for {
+			slots <- struct{}{}
+
+			job, err := p.queue.Claim(context.Background())
+			if errors.Is(err, ErrQueueClosed) {
+				return
+			}
+			if err != nil {
+				<-slots
+				p.logger.Error("failed to claim job", "error", err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			lanes[laneFor(job.Request.FromID, laneCount)].push(job)
+		}
+	}()
+}
+
+// laneFor spreads account IDs over n lanes with a Fibonacci hash, so
+// sequential IDs do not all land on neighbouring lanes.
+func laneFor(accountID uint64, n int) int {
+	return int(((accountID * 11400714819323198485) >> 32) % uint64(n))
+}
+
+// worker processes jobs until the queue is closed or ctx is cancelled by
+// Resize.
+func (p *Pool) worker(ctx context.Context) {
 	defer p.wg.Done()
 
-	for job := range p.jobs {
-		err := p.service.Transfer(context.Background(), job.Request)
+	for {
+		job, err := p.queue.Claim(ctx)
+		if errors.Is(err, ErrQueueClosed) || (err != nil && ctx.Err() != nil) {
+			return
+		}
 		if err != nil {
-			p.logger.Error("transfer processing failed",
-				"request_id", job.Request.RequestID,
-				"error", err,
-			)
+			p.logger.Error("failed to claim job", "error", err)
+			time.Sleep(time.Second)
+			continue
 		}
+
+		p.process(job)
 	}
 }
 
-func (p *Pool) Submit(job TransferJob) bool {
-	select {
-	case p.jobs <- job:
-		return true
-	default:
-		return false
+func (p *Pool) process(job TransferJob) {
+	// The span continues the trace of the request that submitted the job,
+	// when a trace propagator is registered.
+	ctx, span := tracer.Start(p.jobContext(job), "worker.process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("request_id", job.Request.RequestID),
+			attribute.String("priority", job.Priority.String()),
+		))
+	defer span.End()
+	if !job.EnqueuedAt.IsZero() {
+		span.SetAttributes(attribute.Float64("queue_wait_seconds", time.Since(job.EnqueuedAt).Seconds()))
+	}
+
+	// Once Shutdown has given up waiting, jobs that have not started are
+	// handed back to a durable queue for the next start, or failed.
+	if p.base.Err() != nil {
+		p.release(ctx, job)
+		return
+	}
+
+	// A job that waited in the queue past its deadline is failed rather
+	// than executed late.
+	if !job.Deadline.IsZero() && !time.Now().Before(job.Deadline) {
+		p.counters.abandoned.Add(1)
+		p.finish(ctx, job, p.service.Abandon(ctx, job.Request, billing.ErrTransferExpired))
+		return
+	}
+
+	cancel := context.CancelFunc(func() {})
+	if !job.Deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, job.Deadline)
+	}
+
+	p.counters.inFlight.Add(1)
+	start := time.Now()
+
+	err := p.service.Transfer(ctx, job.Request)
+	cancel()
+
+	p.counters.record(time.Since(start), err)
+
+	// A transfer cancelled by Shutdown has no row, so completing the job
+	// would lose it. It is released like one that never started.
+	if err != nil && p.base.Err() != nil && errors.Is(err, context.Canceled) {
+		p.release(ctx, job)
+		return
+	}
+	p.finish(ctx, job, err)
+}
+
+// release hands an aborted job back to a durable queue, or fails it with
+// billing.ErrTransferAborted when the queue cannot take it back.
+func (p *Pool) release(ctx context.Context, job TransferJob) {
+	if err := p.queue.Release(context.Background(), job); err == nil {
+		return
+	}
+	p.counters.abandoned.Add(1)
+	p.finish(ctx, job, p.service.Abandon(ctx, job.Request, billing.ErrTransferAborted))
+}
+
+// jobContext rebuilds the submitting request's context values on top of
+// the pool's base context.
+func (p *Pool) jobContext(job TransferJob) context.Context {
+	ctx := p.base
+	for _, prop := range p.propagators {
+		ctx = prop.Extract(ctx, job.Metadata)
 	}
+	return ctx
 }
 
-func (p *Pool) Shutdown() {
-	close(p.jobs)
-	p.wg.Wait()
+func (p *Pool) finish(ctx context.Context, job TransferJob, err error) {
+	if err != nil {
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	for _, fn := range p.onComplete {
+		fn(job, err)
+	}
+
+	if err != nil {
+		p.logger.Error("transfer processing failed",
+			"request_id", job.Request.RequestID,
+			"subject", job.Request.Subject,
+			"error", err,
+		)
+	}
+
+	// A job is only removed from the queue once the service has finished
+	// with it, so a crash before this point replays it on restart.
+	if err := p.queue.Complete(context.Background(), job); err != nil {
+		p.logger.Error("failed to complete job",
+			"request_id", job.Request.RequestID,
+			"error", err,
+		)
+	}
+}
+
+// Submit durably queues job. It returns ErrQueueFull when the queue is at
+// capacity; any other error means the job was not recorded. Context values
+// known to the registered propagators are copied into the job.
+func (p *Pool) Submit(ctx context.Context, job TransferJob) error {
+	if len(p.propagators) > 0 {
+		if job.Metadata == nil {
+			job.Metadata = make(map[string]string)
+		}
+		for _, prop := range p.propagators {
+			prop.Inject(ctx, job.Metadata)
+		}
+	}
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now()
+	}
+
+	err := p.queue.Enqueue(ctx, job)
+	if err != nil {
+		for _, fn := range p.onReject {
+			fn(job, err)
+		}
+	}
+	return err
+}
+
+// Shutdown stops accepting jobs and waits for queued and in-flight ones to
+// finish. If ctx ends first, in-flight transfers are cancelled and, like the
+// jobs that have not started, released back to a durable queue or failed
+// with billing.ErrTransferAborted; Shutdown then returns ctx.Err() once
+// every worker has exited.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.queue.Close()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	p.logger.Warn("worker pool drain timed out, aborting remaining jobs")
+	p.abort()
+	<-done
+	return ctx.Err()
 }
@@ -0,0 +1,438 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/memstore"
+)
+
+func newTestPool(t testing.TB, store *memstore.Store, capacity int, dispatch Dispatch) *Pool {
+	t.Helper()
+
+	logger := slog.New(slog.DiscardHandler)
+	pool := NewPool(NewMemoryQueue(capacity), billing.NewService(store, store, logger), logger)
+	pool.SetDispatch(dispatch)
+	return pool
+}
+
+func createAccounts(t testing.TB, store *memstore.Store, n int) []uint64 {
+	t.Helper()
+
+	ids := make([]uint64, n)
+	for i := range ids {
+		id, err := store.CreateAccount(context.Background(), int64(1)<<40)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// A lane stuck behind a locked account must not stop the dispatcher from
+// handing jobs to the other lanes.
+func TestHotLaneDoesNotBlockDispatch(t *testing.T) {
+	const lanes = 4
+
+	store := memstore.New()
+	accounts := createAccounts(t, store, 20)
+
+	hot := accounts[0]
+	var cold, sink uint64
+	for _, id := range accounts[1:] {
+		if laneFor(id, lanes) == laneFor(hot, lanes) {
+			continue
+		}
+		if cold == 0 {
+			cold = id
+		} else if sink == 0 {
+			sink = id
+		}
+	}
+	if cold == 0 || sink == 0 {
+		t.Fatal("no accounts off the hot lane")
+	}
+
+	// Hold the hot account's row lock so its transfers wait in their lane.
+	ctx := context.Background()
+	tx, err := store.BeginTx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetAccountForUpdate(ctx, tx, hot); err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	pool := newTestPool(t, store, 100, DispatchByAccount)
+
+	done := make(chan string, 100)
+	pool.OnComplete(func(job TransferJob, err error) {
+		done <- job.Request.RequestID
+	})
+	pool.Start(lanes)
+	defer func() {
+		tx.Rollback()
+		pool.Shutdown(context.Background())
+	}()
+
+	for i := range 20 {
+		req := billing.TransferRequest{RequestID: fmt.Sprintf("hot-%d", i), FromID: hot, ToID: sink, Amount: 1}
+		if err := pool.Submit(ctx, TransferJob{Request: req}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := billing.TransferRequest{RequestID: "cold", FromID: cold, ToID: sink, Amount: 1}
+	if err := pool.Submit(ctx, TransferJob{Request: req}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case id := <-done:
+		if id != "cold" {
+			t.Fatalf("%s finished while the hot account was locked", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cold transfer was not dispatched while the hot lane was blocked")
+	}
+}
+
+// Transfers from one account must run in submission order.
+func TestLanesKeepAccountOrder(t *testing.T) {
+	store := memstore.New()
+	accounts := createAccounts(t, store, 10)
+
+	pool := newTestPool(t, store, 1000, DispatchByAccount)
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[uint64][]int)
+		wg   sync.WaitGroup
+	)
+	pool.OnComplete(func(job TransferJob, err error) {
+		if err != nil {
+			t.Errorf("%s: %v", job.Request.RequestID, err)
+		}
+		var seq int
+		fmt.Sscanf(job.Request.RequestID, "seq-%d", &seq)
+
+		mu.Lock()
+		seen[job.Request.FromID] = append(seen[job.Request.FromID], seq)
+		mu.Unlock()
+		wg.Done()
+	})
+	pool.Start(3)
+
+	for i := range 1000 {
+		from, to := accounts[rand.IntN(len(accounts))], accounts[0]
+		for to == from {
+			to = accounts[rand.IntN(len(accounts))]
+		}
+		req := billing.TransferRequest{RequestID: fmt.Sprintf("seq-%d", i), FromID: from, ToID: to, Amount: 1}
+
+		wg.Add(1)
+		if err := pool.Submit(context.Background(), TransferJob{Request: req}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	pool.Shutdown(context.Background())
+
+	for account, seqs := range seen {
+		for i := 1; i < len(seqs); i++ {
+			if seqs[i] < seqs[i-1] {
+				t.Fatalf("account %d ran seq-%d after seq-%d", account, seqs[i], seqs[i-1])
+			}
+		}
+	}
+}
+
+// A job that waited in the queue past its deadline is failed with
+// billing.ErrTransferExpired without moving any money.
+func TestPoolDropsExpiredJob(t *testing.T) {
+	store := memstore.New()
+	accounts := createAccounts(t, store, 2)
+
+	pool := newTestPool(t, store, 10, DispatchShared)
+
+	done := make(chan error, 2)
+	pool.OnComplete(func(job TransferJob, err error) {
+		done <- err
+	})
+
+	ctx := context.Background()
+	for _, job := range []TransferJob{
+		{Request: billing.TransferRequest{RequestID: "expired", FromID: accounts[0], ToID: accounts[1], Amount: 100}, Deadline: time.Now().Add(-time.Second)},
+		{Request: billing.TransferRequest{RequestID: "on-time", FromID: accounts[0], ToID: accounts[1], Amount: 1}, Deadline: time.Now().Add(time.Minute)},
+	} {
+		if err := pool.Submit(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool.Start(1)
+
+	if err := <-done; !errors.Is(err, billing.ErrTransferExpired) {
+		t.Fatalf("expired job: err = %v, want ErrTransferExpired", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("on-time job: %v", err)
+	}
+	pool.Shutdown(ctx)
+
+	acc, err := store.GetAccount(ctx, accounts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Balance != int64(1)<<40+1 {
+		t.Errorf("receiver balance = %d, want only the on-time transfer added", acc.Balance)
+	}
+	txn, err := store.GetTransactionByRequestID(ctx, "expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txn.Status != billing.StatusFailed {
+		t.Errorf("expired transfer status = %s, want FAILED", txn.Status)
+	}
+
+	stats, err := pool.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Abandoned != 1 {
+		t.Errorf("abandoned = %d, want 1", stats.Abandoned)
+	}
+}
+
+// releasingQueue is a MemoryQueue that, like a durable queue, takes jobs
+// back after Close.
+type releasingQueue struct {
+	*MemoryQueue
+
+	mu       sync.Mutex
+	released []string
+}
+
+func (q *releasingQueue) Release(ctx context.Context, job TransferJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.released = append(q.released, job.Request.RequestID)
+	return nil
+}
+
+// When Shutdown runs out of time, the transfer in flight and the jobs
+// claimed behind it are handed back to the queue, or failed with
+// billing.ErrTransferAborted when the queue cannot take them.
+func TestPoolShutdownReleasesClaimedJobs(t *testing.T) {
+	const jobs = 5
+
+	for _, durable := range []bool{true, false} {
+		t.Run(fmt.Sprintf("durable=%t", durable), func(t *testing.T) {
+			ctx := context.Background()
+			store := memstore.New()
+			accounts := createAccounts(t, store, 2)
+			hot, sink := accounts[0], accounts[1]
+
+			logger := slog.New(slog.DiscardHandler)
+			var queue Queue = NewMemoryQueue(jobs)
+			if durable {
+				queue = &releasingQueue{MemoryQueue: queue.(*MemoryQueue)}
+			}
+			pool := NewPool(queue, billing.NewService(store, store, logger), logger)
+			pool.SetDispatch(DispatchByAccount)
+
+			var (
+				mu        sync.Mutex
+				completed = make(map[string]error)
+			)
+			pool.OnComplete(func(job TransferJob, err error) {
+				mu.Lock()
+				completed[job.Request.RequestID] = err
+				mu.Unlock()
+			})
+
+			// Hold the sender's row lock so the first transfer waits on it
+			// and the rest wait behind it on its lane.
+			tx, err := store.BeginTx(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			if _, err := store.GetAccountForUpdate(ctx, tx, hot); err != nil {
+				t.Fatal(err)
+			}
+
+			pool.Start(1)
+			for i := range jobs {
+				req := billing.TransferRequest{RequestID: fmt.Sprintf("held-%d", i), FromID: hot, ToID: sink, Amount: 1}
+				if err := pool.Submit(ctx, TransferJob{Request: req}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Wait until every job is claimed and the first is running.
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				stats, err := pool.Stats(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stats.QueueDepth == 0 && stats.InFlight == 1 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("jobs not claimed: %+v", stats)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			if err := pool.Shutdown(shutdownCtx); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("shutdown: err = %v, want DeadlineExceeded", err)
+			}
+
+			if durable {
+				released := queue.(*releasingQueue).released
+				if len(released) != jobs {
+					t.Errorf("released %v, want all %d jobs", released, jobs)
+				}
+				if len(completed) != 0 {
+					t.Errorf("completed %v, want none", completed)
+				}
+			} else {
+				if len(completed) != jobs {
+					t.Errorf("completed %v, want all %d jobs", completed, jobs)
+				}
+				for id, err := range completed {
+					if !errors.Is(err, billing.ErrTransferAborted) {
+						t.Errorf("%s: err = %v, want ErrTransferAborted", id, err)
+					}
+				}
+			}
+
+			tx.Rollback()
+			acc, err := store.GetAccount(ctx, sink)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if acc.Balance != int64(1)<<40 {
+				t.Errorf("receiver balance = %d, want unchanged", acc.Balance)
+			}
+		})
+	}
+}
+
+// Resize is refused while Autoscale runs, so its next tick cannot undo a
+// manual size, and accepted again once it stops.
+func TestResizeWhileAutoscaling(t *testing.T) {
+	pool := newTestPool(t, memstore.New(), 10, DispatchShared)
+	pool.Start(2)
+	defer pool.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		pool.Autoscale(ctx, AutoscaleConfig{Min: 1, Max: 4, Interval: time.Hour})
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := pool.Resize(3)
+		if errors.Is(err, ErrAutoscaling) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("resize still accepted while autoscaling")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	<-stopped
+	if err := pool.Resize(3); err != nil {
+		t.Fatalf("resize after autoscaling stopped: %v", err)
+	}
+	if n := pool.Workers(); n != 3 {
+		t.Errorf("workers = %d, want 3", n)
+	}
+}
+
+// BenchmarkDispatch runs b.N transfers through a pool of 8 workers or lanes
+// on memstore, with every sender equally likely or with 80% of transfers
+// from one hot account. The same comparison against MySQL is `cmd/bench
+// dispatch`.
+func BenchmarkDispatch(b *testing.B) {
+	for _, mode := range []struct {
+		name     string
+		dispatch Dispatch
+	}{
+		{"shared", DispatchShared},
+		{"account", DispatchByAccount},
+	} {
+		for _, load := range []struct {
+			name string
+			hot  float64
+		}{
+			{"uniform", 0},
+			{"hot", 0.8},
+		} {
+			b.Run(mode.name+"/"+load.name, func(b *testing.B) {
+				benchmarkDispatch(b, mode.dispatch, load.hot)
+			})
+		}
+	}
+}
+
+func benchmarkDispatch(b *testing.B, dispatch Dispatch, hot float64) {
+	store := memstore.New()
+	accounts := createAccounts(b, store, 50)
+
+	reqs := make([]billing.TransferRequest, b.N)
+	for i := range reqs {
+		from := accounts[1+rand.IntN(len(accounts)-1)]
+		if rand.Float64() < hot {
+			from = accounts[0]
+		}
+		to := from
+		for to == from {
+			to = accounts[rand.IntN(len(accounts))]
+		}
+		reqs[i] = billing.TransferRequest{RequestID: fmt.Sprintf("bench-%d", i), FromID: from, ToID: to, Amount: 1}
+	}
+
+	pool := newTestPool(b, store, b.N, dispatch)
+
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	pool.OnComplete(func(job TransferJob, err error) {
+		if err != nil {
+			b.Error(err)
+		}
+		wg.Done()
+	})
+
+	b.ResetTimer()
+	pool.Start(8)
+	for _, req := range reqs {
+		if err := pool.Submit(context.Background(), TransferJob{Request: req}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	wg.Wait()
+	b.StopTimer()
+
+	pool.Shutdown(context.Background())
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+
+	"gopherpay/internal/billing"
+)
+
+// Priority selects the queue lane a transfer waits in. The zero value is
+// PriorityNormal.
+type Priority int
+
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+	PriorityLow
+)
+
+// Priorities lists every priority, most urgent first.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+var (
+	ErrInvalidPriority    = errors.New("priority must be one of high, normal, low")
+	ErrPriorityNotAllowed = errors.New("high priority requires a treasury or payroll source account")
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	}
+	return "normal"
+}
+
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "high":
+		return PriorityHigh, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	}
+	return 0, fmt.Errorf("%w, got %q", ErrInvalidPriority, s)
+}
+
+// ResolvePriority picks the priority of a transfer. Treasury and payroll
+// accounts default to high, everything else to normal. An explicit
+// requested priority may lower that; only operators may raise it.
+func ResolvePriority(requested string, class billing.AccountClass, operator bool) (Priority, error) {
+	def := PriorityNormal
+	if class == billing.ClassTreasury || class == billing.ClassPayroll {
+		def = PriorityHigh
+	}
+	if requested == "" {
+		return def, nil
+	}
+
+	p, err := ParsePriority(requested)
+	if err != nil {
+		return 0, err
+	}
+	if p == PriorityHigh && def != PriorityHigh && !operator {
+		return 0, ErrPriorityNotAllowed
+	}
+	return p, nil
+}
@@ -0,0 +1,204 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Lane is one priority's queue inside a PriorityQueue. Each lane has its
+// own capacity, so a flood of low priority transfers fills (and gets 429s
+// from) its own lane without touching the others.
+type Lane struct {
+	Priority Priority
+	Queue    Queue
+	Weight   int // share of claims when every lane has work
+}
+
+// PriorityQueue routes jobs to a lane by TransferJob.Priority and hands
+// them out with smooth weighted round robin: with weights 6/3/1, ten
+// consecutive claims take six high, three normal and one low job when all
+// three lanes are busy, while an idle lane gives its share to the others.
+type PriorityQueue struct {
+	lanes        []*weightedLane
+	pollInterval time.Duration
+	wake         chan struct{}
+
+	mu sync.Mutex // guards current weights
+}
+
+type weightedLane struct {
+	Lane
+	current int
+}
+
+func NewPriorityQueue(lanes ...Lane) *PriorityQueue {
+	q := &PriorityQueue{
+		pollInterval: 500 * time.Millisecond,
+		wake:         make(chan struct{}, 1),
+	}
+	for _, l := range lanes {
+		if l.Weight < 1 {
+			l.Weight = 1
+		}
+		q.lanes = append(q.lanes, &weightedLane{Lane: l})
+	}
+	return q
+}
+
+// lane returns the lane for p, falling back to the normal lane.
+func (q *PriorityQueue) lane(p Priority) *weightedLane {
+	var fallback *weightedLane
+	for _, l := range q.lanes {
+		if l.Priority == p {
+			return l
+		}
+		if l.Priority == PriorityNormal {
+			fallback = l
+		}
+	}
+	if fallback == nil {
+		fallback = q.lanes[0]
+	}
+	return fallback
+}
+
+func (q *PriorityQueue) Enqueue(ctx context.Context, job TransferJob) error {
+	l := q.lane(job.Priority)
+	if err := l.Queue.Enqueue(ctx, job); err != nil {
+		return fmt.Errorf("%s lane: %w", l.Priority, err)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *PriorityQueue) Claim(ctx context.Context) (TransferJob, error) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, ok, err := q.TryClaim(ctx)
+		if err != nil || ok {
+			return job, err
+		}
+
+		select {
+		case <-q.wake:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return TransferJob{}, ctx.Err()
+		}
+	}
+}
+
+// TryClaim offers the claim to lanes in weighted order. A lane that turns
+// out to be empty loses its accumulated credit, so an idle lane cannot
+// build up a burst that starves the others later, and its weight is left
+// out of the round, so the busy lanes split its share in proportion to
+// their own weights.
+func (q *PriorityQueue) TryClaim(ctx context.Context) (TransferJob, bool, error) {
+	q.mu.Lock()
+	total := 0
+	order := make([]*weightedLane, len(q.lanes))
+	for i, l := range q.lanes {
+		l.current += l.Weight
+		total += l.Weight
+		order[i] = l
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].current > order[j].current })
+	q.mu.Unlock()
+
+	closed := 0
+	for _, l := range order {
+		job, ok, err := l.Queue.TryClaim(ctx)
+		if errors.Is(err, ErrQueueClosed) {
+			closed++
+			total -= l.Weight
+			continue
+		}
+		if err != nil {
+			return TransferJob{}, false, err
+		}
+
+		q.mu.Lock()
+		if ok {
+			l.current -= total
+		} else {
+			l.current = 0
+			total -= l.Weight
+		}
+		q.mu.Unlock()
+
+		if ok {
+			return job, true, nil
+		}
+	}
+
+	if closed == len(order) {
+		return TransferJob{}, false, ErrQueueClosed
+	}
+	return TransferJob{}, false, nil
+}
+
+func (q *PriorityQueue) Release(ctx context.Context, job TransferJob) error {
+	return q.lane(job.Priority).Queue.Release(ctx, job)
+}
+
+func (q *PriorityQueue) Complete(ctx context.Context, job TransferJob) error {
+	return q.lane(job.Priority).Queue.Complete(ctx, job)
+}
+
+func (q *PriorityQueue) Depth(ctx context.Context) (int, error) {
+	total := 0
+	for _, l := range q.lanes {
+		n, err := l.Queue.Depth(ctx)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (q *PriorityQueue) Capacity() int {
+	total := 0
+	for _, l := range q.lanes {
+		total += l.Queue.Capacity()
+	}
+	return total
+}
+
+// LaneDepths reports the depth of each lane by priority.
+func (q *PriorityQueue) LaneDepths(ctx context.Context) (map[Priority]int, error) {
+	depths := make(map[Priority]int, len(q.lanes))
+	for _, l := range q.lanes {
+		n, err := l.Queue.Depth(ctx)
+		if err != nil {
+			return nil, err
+		}
+		depths[l.Priority] = n
+	}
+	return depths, nil
+}
+
+// LaneCapacities reports the capacity of each lane by priority.
+func (q *PriorityQueue) LaneCapacities() map[Priority]int {
+	capacities := make(map[Priority]int, len(q.lanes))
+	for _, l := range q.lanes {
+		capacities[l.Priority] = l.Queue.Capacity()
+	}
+	return capacities
+}
+
+func (q *PriorityQueue) Close() {
+	for _, l := range q.lanes {
+		l.Queue.Close()
+	}
+}
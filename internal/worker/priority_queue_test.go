@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gopherpay/internal/billing"
+)
+
+// newTestPriorityQueue returns a 6/3/1 weighted queue with n jobs queued
+// in each lane of fill.
+func newTestPriorityQueue(t *testing.T, n int, fill ...Priority) *PriorityQueue {
+	t.Helper()
+
+	q := NewPriorityQueue(
+		Lane{Priority: PriorityHigh, Queue: NewMemoryQueue(n), Weight: 6},
+		Lane{Priority: PriorityNormal, Queue: NewMemoryQueue(n), Weight: 3},
+		Lane{Priority: PriorityLow, Queue: NewMemoryQueue(n), Weight: 1},
+	)
+	for _, p := range fill {
+		for i := range n {
+			req := billing.TransferRequest{RequestID: fmt.Sprintf("%s-%d", p, i), FromID: 1, ToID: 2, Amount: 1}
+			if err := q.Enqueue(context.Background(), TransferJob{Request: req, Priority: p}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return q
+}
+
+// claimCounts claims n jobs without blocking and counts them by priority.
+func claimCounts(t *testing.T, q *PriorityQueue, n int) map[Priority]int {
+	t.Helper()
+
+	counts := make(map[Priority]int)
+	for i := range n {
+		job, ok, err := q.TryClaim(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatalf("claim %d found nothing while jobs were queued", i)
+		}
+		counts[job.Priority]++
+	}
+	return counts
+}
+
+func TestPriorityQueueWeightedShares(t *testing.T) {
+	q := newTestPriorityQueue(t, 100, PriorityHigh, PriorityNormal, PriorityLow)
+
+	// Smooth round robin hands out exact shares in every window of ten.
+	for window := range 10 {
+		counts := claimCounts(t, q, 10)
+		if counts[PriorityHigh] != 6 || counts[PriorityNormal] != 3 || counts[PriorityLow] != 1 {
+			t.Fatalf("window %d: claimed %v, want high 6, normal 3, low 1", window, counts)
+		}
+	}
+}
+
+func TestPriorityQueueSkipsEmptyLane(t *testing.T) {
+	tests := []struct {
+		name string
+		fill []Priority
+		want map[Priority]int // claims per lane out of 70, give or take the first round
+	}{
+		{"normal empty", []Priority{PriorityHigh, PriorityLow}, map[Priority]int{PriorityHigh: 60, PriorityLow: 10}},
+		{"high empty", []Priority{PriorityNormal, PriorityLow}, map[Priority]int{PriorityNormal: 52, PriorityLow: 18}},
+		{"only low", []Priority{PriorityLow}, map[Priority]int{PriorityLow: 70}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestPriorityQueue(t, 100, tt.fill...)
+
+			// Every claim succeeds, so an empty lane never stalls the
+			// others, and they split its share by their own weights.
+			counts := claimCounts(t, q, 70)
+			for p, want := range tt.want {
+				if counts[p] < want-1 || counts[p] > want+1 {
+					t.Errorf("%s lane got %d of 70 claims, want %d±1 (all: %v)", p, counts[p], want, counts)
+				}
+			}
+		})
+	}
+}
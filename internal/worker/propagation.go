@@ -0,0 +1,15 @@
+package worker
+
+import "context"
+
+// Propagator carries request-scoped context values across the queue, which
+// may be a database table, so they reach the worker that runs the job. The
+// shape matches OpenTelemetry's TextMapPropagator so trace context can be
+// plugged in directly.
+type Propagator interface {
+	// Inject copies values from ctx into carrier when a job is submitted.
+	Inject(ctx context.Context, carrier map[string]string)
+
+	// Extract returns ctx with the values in carrier restored.
+	Extract(ctx context.Context, carrier map[string]string) context.Context
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"errors"
+
+	"gopherpay/internal/billing"
+)
+
+var (
+	ErrQueueFull   = errors.New("queue is full")
+	ErrQueueClosed = errors.New("queue is closed")
+)
+
+// Queue holds accepted transfer jobs until a worker claims them.
+type Queue interface {
+	// Enqueue records job and returns once it is safe to acknowledge to the
+	// client. It returns ErrQueueFull when the queue is at capacity.
+	Enqueue(ctx context.Context, job TransferJob) error
+
+	// Claim blocks until a job is available. After Close it returns any
+	// remaining jobs that must still be processed, then ErrQueueClosed.
+	Claim(ctx context.Context) (TransferJob, error)
+
+	// TryClaim is the non-blocking form of Claim: ok is false when no job
+	// is available right now.
+	TryClaim(ctx context.Context) (job TransferJob, ok bool, err error)
+
+	// Release returns a claimed job to the queue unprocessed. Queues that
+	// cannot keep it (an in-memory queue after Close) return an error.
+	Release(ctx context.Context, job TransferJob) error
+
+	// Complete removes a claimed job once the service has processed it.
+	Complete(ctx context.Context, job TransferJob) error
+
+	// Depth reports how many jobs are waiting. Durable queues also count
+	// jobs that are claimed but not yet completed.
+	Depth(ctx context.Context) (int, error)
+
+	// Capacity is the depth at which Enqueue starts returning ErrQueueFull.
+	Capacity() int
+
+	// Close stops accepting new jobs.
+	Close()
+}
+
+// checkRequeue compares job with queued, the job already stored under its
+// request ID. A client retrying the same transfer is accepted as is; a
+// different transfer reusing the ID is refused with
+// billing.ErrRequestConflict instead of being dropped.
+func checkRequeue(queued billing.TransferRequest, job TransferJob) error {
+	if queued.FromID != job.Request.FromID || queued.ToID != job.Request.ToID || queued.Amount != job.Request.Amount {
+		return billing.ErrRequestConflict
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/dberr"
+)
+
+// SQLiteQueue is MySQLQueue for SQLite storage: jobs are kept in the
+// transfer_jobs table of the database file, so accepted transfers survive
+// a crash. SQLite has no row locks; a job is claimed by a single UPDATE
+// ... RETURNING, which the database write lock makes atomic, so several
+// workers never claim the same row.
+type SQLiteQueue struct {
+	db           *sql.DB
+	priority     Priority
+	capacity     int
+	lease        time.Duration
+	pollInterval time.Duration
+	wake         chan struct{}
+	closed       atomic.Bool
+}
+
+func NewSQLiteQueue(db *sql.DB, capacity int, lease time.Duration) *SQLiteQueue {
+	return &SQLiteQueue{
+		db:           db,
+		capacity:     capacity,
+		lease:        lease,
+		pollInterval: 500 * time.Millisecond,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Lane returns a queue over the same table holding only jobs of priority p,
+// with its own capacity.
+func (q *SQLiteQueue) Lane(p Priority, capacity int) *SQLiteQueue {
+	return &SQLiteQueue{
+		db:           q.db,
+		priority:     p,
+		capacity:     capacity,
+		lease:        q.lease,
+		pollInterval: q.pollInterval,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+func (q *SQLiteQueue) Enqueue(ctx context.Context, job TransferJob) error {
+	if q.closed.Load() {
+		return ErrQueueClosed
+	}
+
+	// As on MySQL the capacity check is advisory.
+	depth, err := q.Depth(ctx)
+	if err != nil {
+		return err
+	}
+	if depth >= q.capacity {
+		return ErrQueueFull
+	}
+
+	query := `
+        INSERT INTO transfer_jobs (request_id, subject, from_account_id, to_account_id,
+        amount, deadline, priority, metadata, status)
+        VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, 'QUEUED')
+    `
+
+	var deadline sql.NullTime
+	if !job.Deadline.IsZero() {
+		deadline = sql.NullTime{Time: job.Deadline.UTC(), Valid: true}
+	}
+
+	var metadata sql.NullString
+	if len(job.Metadata) > 0 {
+		b, err := json.Marshal(job.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to encode job metadata: %w", err)
+		}
+		metadata = sql.NullString{String: string(b), Valid: true}
+	}
+
+	_, err = q.db.ExecContext(ctx, query,
+		job.Request.RequestID,
+		job.Request.Subject,
+		job.Request.FromID,
+		job.Request.ToID,
+		job.Request.Amount,
+		deadline,
+		q.priority.String(),
+		metadata,
+	)
+	if dberr.IsDuplicate(err) {
+		return q.requeue(ctx, job)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// requeue handles an Enqueue whose request ID is already in the table, as
+// on MySQL.
+func (q *SQLiteQueue) requeue(ctx context.Context, job TransferJob) error {
+	var queued billing.TransferRequest
+	err := q.db.QueryRowContext(ctx,
+		`SELECT from_account_id, to_account_id, amount FROM transfer_jobs WHERE request_id = ?1`,
+		job.Request.RequestID,
+	).Scan(&queued.FromID, &queued.ToID, &queued.Amount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return q.Enqueue(ctx, job)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read queued job %s: %w", job.Request.RequestID, err)
+	}
+	return checkRequeue(queued, job)
+}
+
+func (q *SQLiteQueue) Claim(ctx context.Context) (TransferJob, error) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, ok, err := q.TryClaim(ctx)
+		if err != nil || ok {
+			return job, err
+		}
+
+		select {
+		case <-q.wake:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return TransferJob{}, ctx.Err()
+		}
+	}
+}
+
+func (q *SQLiteQueue) TryClaim(ctx context.Context) (TransferJob, bool, error) {
+	// Unclaimed rows stay in the table for the next start, so there is
+	// nothing to drain after Close.
+	if q.closed.Load() {
+		return TransferJob{}, false, ErrQueueClosed
+	}
+
+	job, err := q.claimOne(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TransferJob{}, false, nil
+	}
+	if err != nil {
+		return TransferJob{}, false, err
+	}
+	return job, true, nil
+}
+
+func (q *SQLiteQueue) claimOne(ctx context.Context) (TransferJob, error) {
+
+	// claimed_at is kept as millisecond text so it compares in order.
+	query := `
+        UPDATE transfer_jobs
+        SET status = 'CLAIMED', claimed_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), attempts = attempts + 1
+        WHERE id = (
+            SELECT id FROM transfer_jobs
+            WHERE priority = ?1
+              AND (status = 'QUEUED'
+                   OR (status = 'CLAIMED' AND claimed_at < strftime('%Y-%m-%d %H:%M:%f', 'now', ?2)))
+            ORDER BY id ASC
+            LIMIT 1
+        )
+        RETURNING request_id, subject, from_account_id, to_account_id, amount, deadline, metadata,
+                  created_at
+    `
+
+	var (
+		job      TransferJob
+		deadline sql.NullTime
+		metadata []byte
+	)
+	lease := fmt.Sprintf("-%d seconds", int(q.lease.Seconds()))
+	err := q.db.QueryRowContext(ctx, query, q.priority.String(), lease).Scan(
+		&job.Request.RequestID,
+		&job.Request.Subject,
+		&job.Request.FromID,
+		&job.Request.ToID,
+		&job.Request.Amount,
+		&deadline,
+		&metadata,
+		&job.EnqueuedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TransferJob{}, err
+	}
+	if err != nil {
+		return TransferJob{}, fmt.Errorf("failed to claim job: %w", err)
+	}
+	if deadline.Valid {
+		job.Deadline = deadline.Time
+	}
+	job.Priority = q.priority
+	// Metadata is best effort: a job is still processed without it.
+	_ = json.Unmarshal(metadata, &job.Metadata)
+
+	return job, nil
+}
+
+// Release puts a claimed job back to QUEUED without counting the attempt.
+// It works after Close, so jobs can be handed back during shutdown.
+func (q *SQLiteQueue) Release(ctx context.Context, job TransferJob) error {
+	_, err := q.db.ExecContext(ctx, `
+        UPDATE transfer_jobs
+        SET status = 'QUEUED', claimed_at = NULL, attempts = MAX(attempts, 1) - 1
+        WHERE request_id = ?1
+    `, job.Request.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to release job %s: %w", job.Request.RequestID, err)
+	}
+	return nil
+}
+
+func (q *SQLiteQueue) Complete(ctx context.Context, job TransferJob) error {
+	_, err := q.db.ExecContext(ctx, `DELETE FROM transfer_jobs WHERE request_id = ?1`, job.Request.RequestID)
+	if err != nil {
+		return fmt.Errorf("failed to complete job %s: %w", job.Request.RequestID, err)
+	}
+	return nil
+}
+
+func (q *SQLiteQueue) Depth(ctx context.Context) (int, error) {
+	var depth int
+	if err := q.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transfer_jobs WHERE priority = ?1`, q.priority.String()).Scan(&depth); err != nil {
+		return 0, fmt.Errorf("failed to read queue depth: %w", err)
+	}
+	return depth, nil
+}
+
+func (q *SQLiteQueue) Capacity() int {
+	return q.capacity
+}
+
+func (q *SQLiteQueue) Close() {
+	q.closed.Store(true)
+}
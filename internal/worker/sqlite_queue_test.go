@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopherpay/internal/billing"
+	"gopherpay/internal/config"
+	"gopherpay/migrations"
+)
+
+func newSQLiteQueue(t *testing.T) *SQLiteQueue {
+	t.Helper()
+
+	db, err := config.OpenSQLite(config.StorageConfig{Path: filepath.Join(t.TempDir(), "queue.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := migrations.NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLiteQueue(db, 10, time.Minute)
+}
+
+// A retry of a queued transfer is accepted once; a different transfer
+// under the same request ID is refused rather than silently dropped.
+func TestSQLiteQueueReusedRequestID(t *testing.T) {
+	ctx := context.Background()
+	q := newSQLiteQueue(t)
+
+	job := TransferJob{Request: billing.TransferRequest{RequestID: "r1", FromID: 1, ToID: 2, Amount: 100}}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("retry of the same transfer: %v", err)
+	}
+
+	other := job
+	other.Request.Amount = 999
+	if err := q.Enqueue(ctx, other); !errors.Is(err, billing.ErrRequestConflict) {
+		t.Fatalf("err = %v, want ErrRequestConflict", err)
+	}
+
+	if depth, _ := q.Depth(ctx); depth != 1 {
+		t.Fatalf("depth = %d, want 1", depth)
+	}
+
+	// Once the first job is done the ID can be queued again; the service
+	// then compares it against the recorded transfer.
+	claimed, err := q.Claim(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Complete(ctx, claimed); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue(ctx, other); err != nil {
+		t.Fatalf("enqueue after completion: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// readyFillRatio is the queue fill level above which the pool reports
+// itself saturated, leaving headroom for requests already in flight.
+const readyFillRatio = 0.9
+
+// Stats is a point-in-time view of the pool.
+type Stats struct {
+	Workers       int
+	QueueDepth    int
+	QueueCapacity int
+	LaneDepths    map[Priority]int // nil unless the queue has priority lanes
+	LaneCapacity  map[Priority]int // capacity of each lane in LaneDepths
+	InFlight      int64
+	Processed     uint64 // jobs finished, successful or not
+	Failed        uint64
+	Abandoned     uint64        // expired in the queue or dropped at shutdown
+	AvgProcessing time.Duration // mean service time since start
+}
+
+// Saturated reports whether the queue, or any one of its lanes, is nearly
+// full, meaning new transfers are likely to be rejected with ErrQueueFull.
+func (s Stats) Saturated() bool {
+	return nearlyFull(s.QueueDepth, s.QueueCapacity) || len(s.SaturatedLanes()) > 0
+}
+
+// SaturatedLanes returns the priority lanes that are nearly full, highest
+// priority first. A full lane rejects its transfers even while the queue
+// as a whole has room.
+func (s Stats) SaturatedLanes() []Priority {
+	var lanes []Priority
+	for _, p := range Priorities {
+		if depth, ok := s.LaneDepths[p]; ok && nearlyFull(depth, s.LaneCapacity[p]) {
+			lanes = append(lanes, p)
+		}
+	}
+	return lanes
+}
+
+func nearlyFull(depth, capacity int) bool {
+	return capacity > 0 && float64(depth) >= readyFillRatio*float64(capacity)
+}
+
+type counters struct {
+	inFlight   atomic.Int64
+	processed  atomic.Uint64
+	failed     atomic.Uint64
+	abandoned  atomic.Uint64
+	totalNanos atomic.Int64
+}
+
+func (c *counters) record(d time.Duration, err error) {
+	c.inFlight.Add(-1)
+	c.totalNanos.Add(int64(d))
+	c.processed.Add(1)
+	if err != nil {
+		c.failed.Add(1)
+	}
+}
+
+// Stats samples the pool counters and the queue depth.
+func (p *Pool) Stats(ctx context.Context) (Stats, error) {
+	depth, err := p.queue.Depth(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	s := Stats{
+		Workers:       p.Workers(),
+		QueueDepth:    depth,
+		QueueCapacity: p.queue.Capacity(),
+		InFlight:      p.counters.inFlight.Load(),
+		Processed:     p.counters.processed.Load(),
+		Failed:        p.counters.failed.Load(),
+		Abandoned:     p.counters.abandoned.Load(),
+	}
+	if pq, ok := p.queue.(*PriorityQueue); ok {
+		if s.LaneDepths, err = pq.LaneDepths(ctx); err != nil {
+			return Stats{}, err
+		}
+		s.LaneCapacity = pq.LaneCapacities()
+	}
+	if s.Processed > 0 {
+		s.AvgProcessing = time.Duration(p.counters.totalNanos.Load() / int64(s.Processed))
+	}
+	return s, nil
+}
@@ -1,7 +1,3 @@
-CREATE DATABASE gopherpay;
-USE gopherpay;
-
- 
 CREATE TABLE accounts (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     balance BIGINT NOT NULL DEFAULT 0,
@@ -9,7 +5,7 @@ CREATE TABLE accounts (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
 
- 
+
 CREATE TABLE transactions (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     request_id VARCHAR(64) NOT NULL UNIQUE,
@@ -31,7 +27,9 @@ CREATE INDEX idx_to_account ON transactions(to_account_id);
 CREATE INDEX idx_request_id ON transactions(request_id);
 CREATE INDEX idx_created_at ON transactions(created_at);
 
- 
+
+-- No foreign key to transactions: rejected transfers are audited before
+-- (or without) a transactions row existing.
 CREATE TABLE audit_logs (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     request_id VARCHAR(64) NOT NULL,
@@ -39,10 +37,6 @@ CREATE TABLE audit_logs (
     status VARCHAR(20) NOT NULL,
     message VARCHAR(255) NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    CONSTRAINT fk_audit_request_id FOREIGN KEY (request_id) REFERENCES transactions(request_id) ON DELETE CASCADE,
     INDEX idx_request_id (request_id),
     INDEX idx_created_at (created_at)
 );
-
-ALTER TABLE audit_logs
-DROP FOREIGN KEY fk_audit_request_id;
\ No newline at end of file
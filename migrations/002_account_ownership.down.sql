@@ -0,0 +1,8 @@
+DROP TABLE credentials;
+
+ALTER TABLE accounts
+    DROP FOREIGN KEY fk_account_customer,
+    DROP INDEX idx_customer_id,
+    DROP COLUMN customer_id;
+
+DROP TABLE customers;
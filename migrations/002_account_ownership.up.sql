@@ -0,0 +1,26 @@
+CREATE TABLE customers (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+
+ALTER TABLE accounts
+    ADD COLUMN customer_id BIGINT UNSIGNED NULL AFTER id,
+    ADD CONSTRAINT fk_account_customer FOREIGN KEY (customer_id) REFERENCES customers(id),
+    ADD INDEX idx_customer_id (customer_id);
+
+
+-- API keys are stored as sha256 hex digests; JWT subjects are stored verbatim.
+-- Operator credentials may have no customer.
+CREATE TABLE credentials (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    customer_id BIGINT UNSIGNED NULL,
+    kind ENUM('API_KEY','JWT_SUBJECT') NOT NULL,
+    identifier VARCHAR(255) NOT NULL,
+    role ENUM('CUSTOMER','OPERATOR') NOT NULL DEFAULT 'CUSTOMER',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP NULL,
+    CONSTRAINT fk_credential_customer FOREIGN KEY (customer_id) REFERENCES customers(id),
+    UNIQUE INDEX uq_credential (kind, identifier)
+);
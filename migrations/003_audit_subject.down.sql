@@ -0,0 +1,2 @@
+ALTER TABLE audit_logs
+    DROP COLUMN subject;
@@ -0,0 +1,3 @@
+-- Authenticated caller (API key id or JWT subject) recorded with each audit entry.
+ALTER TABLE audit_logs
+    ADD COLUMN subject VARCHAR(255) NOT NULL DEFAULT '' AFTER request_id;
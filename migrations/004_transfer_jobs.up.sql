@@ -0,0 +1,16 @@
+-- Durable queue of accepted transfers. Rows are deleted once processed;
+-- CLAIMED rows whose claim is older than the worker lease are retried.
+CREATE TABLE transfer_jobs (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    request_id VARCHAR(64) NOT NULL UNIQUE,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    from_account_id BIGINT UNSIGNED NOT NULL,
+    to_account_id BIGINT UNSIGNED NOT NULL,
+    amount BIGINT NOT NULL,
+    deadline DATETIME(3) NULL,
+    status ENUM('QUEUED','CLAIMED') NOT NULL DEFAULT 'QUEUED',
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    claimed_at DATETIME(3) NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_status_claimed (status, claimed_at)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE transfer_jobs
+    DROP INDEX idx_priority_status_claimed,
+    DROP COLUMN priority;
+
+ALTER TABLE accounts
+    DROP COLUMN class;
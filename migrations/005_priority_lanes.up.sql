@@ -0,0 +1,8 @@
+-- Transfers out of treasury and payroll accounts default to the high
+-- priority lane.
+ALTER TABLE accounts
+    ADD COLUMN class ENUM('standard','treasury','payroll') NOT NULL DEFAULT 'standard' AFTER customer_id;
+
+ALTER TABLE transfer_jobs
+    ADD COLUMN priority ENUM('high','normal','low') NOT NULL DEFAULT 'normal' AFTER deadline,
+    ADD INDEX idx_priority_status_claimed (priority, status, claimed_at);
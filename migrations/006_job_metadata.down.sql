@@ -0,0 +1,2 @@
+ALTER TABLE transfer_jobs
+    DROP COLUMN metadata;
@@ -0,0 +1,4 @@
+-- Request-scoped context (request ID, trace context) carried from the
+-- accepting request to the worker.
+ALTER TABLE transfer_jobs
+    ADD COLUMN metadata JSON NULL AFTER priority;
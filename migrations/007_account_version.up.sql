@@ -0,0 +1,4 @@
+-- Optimistic transfers read accounts without locking them and write back
+-- only if the version they read is still current.
+ALTER TABLE accounts
+    ADD COLUMN version BIGINT UNSIGNED NOT NULL DEFAULT 0 AFTER balance;
@@ -0,0 +1,53 @@
+// Package migrations embeds the schema migrations. Each version has a
+// NNN_name.up.sql and a NNN_name.down.sql file; apply them with
+// `admin migrate` rather than by hand. The MySQL set is at the top level;
+// the Postgres and SQLite sets, each numbered independently, are under
+// postgres/ and sqlite/.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"gopherpay/internal/migrate"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed postgres/*.sql
+var postgresFS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+// NewMigrator returns a migrator for db with the migration set and
+// dialect of the storage backend: "mysql", "postgres" or "sqlite".
+func NewMigrator(db *sql.DB, backend string) (*migrate.Migrator, error) {
+	switch backend {
+	case "mysql":
+		return migrate.New(db, FS)
+
+	case "postgres":
+		return newMigrator(db, postgresFS, "postgres", migrate.Postgres)
+
+	case "sqlite":
+		return newMigrator(db, sqliteFS, "sqlite", migrate.SQLite)
+	}
+	return nil, fmt.Errorf("storage backend %q has no migrations", backend)
+}
+
+func newMigrator(db *sql.DB, fsys embed.FS, dir string, dialect migrate.Dialect) (*migrate.Migrator, error) {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.New(db, sub)
+	if err != nil {
+		return nil, err
+	}
+	m.SetDialect(dialect)
+	return m, nil
+}
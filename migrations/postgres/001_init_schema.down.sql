@@ -0,0 +1,5 @@
+DROP TABLE credentials;
+DROP TABLE audit_logs;
+DROP TABLE transactions;
+DROP TABLE accounts;
+DROP TABLE customers;
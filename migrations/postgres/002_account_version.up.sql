@@ -0,0 +1,3 @@
+-- Equivalent to MySQL migration 007.
+ALTER TABLE accounts
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
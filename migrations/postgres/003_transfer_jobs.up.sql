@@ -0,0 +1,21 @@
+-- Durable queue of accepted transfers, equivalent to MySQL migrations 004,
+-- 005 (the priority column) and 006. Rows are deleted once processed;
+-- CLAIMED rows whose claim is older than the worker lease are retried.
+CREATE TABLE transfer_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    request_id VARCHAR(64) NOT NULL UNIQUE,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    from_account_id BIGINT NOT NULL,
+    to_account_id BIGINT NOT NULL,
+    amount BIGINT NOT NULL,
+    deadline TIMESTAMPTZ NULL,
+    priority VARCHAR(8) NOT NULL DEFAULT 'normal'
+        CHECK (priority IN ('high','normal','low')),
+    metadata JSONB NULL,
+    status VARCHAR(8) NOT NULL DEFAULT 'QUEUED' CHECK (status IN ('QUEUED','CLAIMED')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    claimed_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_transfer_jobs_priority_status_claimed ON transfer_jobs(priority, status, claimed_at);
@@ -0,0 +1,74 @@
+-- SQLite schema, equivalent to MySQL migrations 001-005 without the
+-- transfer_jobs queue, which is MySQL only. Versions here are numbered
+-- independently of the MySQL set.
+--
+-- AUTOINCREMENT keeps ids from being reused after the newest row is
+-- deleted. Foreign keys are enforced because the connection turns on
+-- PRAGMA foreign_keys.
+CREATE TABLE customers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+CREATE TABLE accounts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NULL REFERENCES customers(id),
+    class VARCHAR(16) NOT NULL DEFAULT 'standard'
+        CHECK (class IN ('standard','treasury','payroll')),
+    balance INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_accounts_customer_id ON accounts(customer_id);
+
+
+CREATE TABLE transactions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id VARCHAR(64) NOT NULL UNIQUE,
+    from_account_id INTEGER NOT NULL REFERENCES accounts(id),
+    to_account_id INTEGER NOT NULL REFERENCES accounts(id),
+    amount INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('PENDING','SUCCESS','FAILED')),
+    error_message VARCHAR(255) NULL,
+    from_balance INTEGER NULL,
+    to_balance INTEGER NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_transactions_from_account ON transactions(from_account_id);
+CREATE INDEX idx_transactions_to_account ON transactions(to_account_id);
+CREATE INDEX idx_transactions_created_at ON transactions(created_at);
+
+
+-- No foreign key to transactions: rejected transfers are audited before
+-- (or without) a transactions row existing.
+CREATE TABLE audit_logs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    message VARCHAR(255) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_logs_request_id ON audit_logs(request_id);
+CREATE INDEX idx_audit_logs_created_at ON audit_logs(created_at);
+
+
+-- API keys are stored as sha256 hex digests; JWT subjects are stored verbatim.
+-- Operator credentials may have no customer.
+CREATE TABLE credentials (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NULL REFERENCES customers(id),
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('API_KEY','JWT_SUBJECT')),
+    identifier VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'CUSTOMER' CHECK (role IN ('CUSTOMER','OPERATOR')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP NULL,
+    CONSTRAINT uq_credential UNIQUE (kind, identifier)
+);
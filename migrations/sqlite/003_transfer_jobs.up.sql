@@ -0,0 +1,22 @@
+-- Durable queue of accepted transfers, equivalent to MySQL migrations 004,
+-- 005 (the priority column) and 006. Rows are deleted once processed;
+-- CLAIMED rows whose claim is older than the worker lease are retried.
+-- claimed_at is millisecond UTC text written by the queue itself.
+CREATE TABLE transfer_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    request_id VARCHAR(64) NOT NULL UNIQUE,
+    subject VARCHAR(255) NOT NULL DEFAULT '',
+    from_account_id INTEGER NOT NULL,
+    to_account_id INTEGER NOT NULL,
+    amount INTEGER NOT NULL,
+    deadline TIMESTAMP NULL,
+    priority VARCHAR(8) NOT NULL DEFAULT 'normal'
+        CHECK (priority IN ('high','normal','low')),
+    metadata TEXT NULL,
+    status VARCHAR(8) NOT NULL DEFAULT 'QUEUED' CHECK (status IN ('QUEUED','CLAIMED')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    claimed_at TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_transfer_jobs_priority_status_claimed ON transfer_jobs(priority, status, claimed_at);